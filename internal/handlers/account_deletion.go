@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+// deletedUsername is of the account standing in as author of anonymized content of deleted users
+const deletedUsername = "deleted"
+
+// errUndeletableUser is of users who can not be deleted, since content of others depends on them
+var errUndeletableUser = errors.New("user can not be deleted")
+
+// deletedUser returns the account standing in as author of anonymized content, adding it on first use
+func (h *handler) deletedUser() (*models.User, error) {
+	for i := 0; i < 2; i++ {
+		user, err := h.userRepo.GetByUsername(deletedUsername)
+		if err == nil {
+			// a user registered with the name before it was reserved does not get content of others
+			if user.Role != models.RoleDeleted {
+				return nil, fmt.Errorf("username '%s' is taken by another user", deletedUsername)
+			}
+
+			return user, nil
+		}
+
+		if !errors.As(err, &models.UserByUsernameNotFoundError{}) {
+			return nil, fmt.Errorf("error on get user by username: %w", err)
+		}
+
+		// the password is random and the email can not receive mail, so nobody can log in
+		secret, err := randomString(32)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := h.hasher.Hash(secret)
+		if err != nil {
+			return nil, fmt.Errorf("error on hash password: %w", err)
+		}
+
+		id, err := h.userRepo.NewID()
+		if err != nil {
+			return nil, fmt.Errorf("error on generate user id: %w", err)
+		}
+
+		user = &models.User{
+			ID:        id,
+			Email:     deletedUsername + "@invalid",
+			Username:  deletedUsername,
+			Password:  hash,
+			Role:      models.RoleDeleted,
+			Followers: map[int]bool{},
+		}
+
+		err = h.userRepo.Add(*user)
+		if err == nil {
+			return user, nil
+		}
+
+		// it may be added concurrently, so it is looked up again
+	}
+
+	return nil, fmt.Errorf("error on add deleted user")
+}
+
+// deleteUser deletes user with their credentials, follows and favorites,
+// and deletes, anonymizes or transfers their articles and comments by the content policy.
+// Each step can be repeated, so a failed deletion can be retried.
+func (h *handler) deleteUser(user models.User) error {
+	if user.Role == models.RoleDeleted {
+		return fmt.Errorf("%w: it is the author of content of deleted users", errUndeletableUser)
+	}
+
+	// handle content
+	switch h.deletedContentPolicy {
+	case ContentPolicyAnonymize, ContentPolicyTransfer:
+		var (
+			author *models.User
+			err    error
+		)
+
+		if h.deletedContentPolicy == ContentPolicyAnonymize {
+			author, err = h.deletedUser()
+		} else {
+			author, err = h.userRepo.GetByUsername(h.deletedContentTransferTo)
+			// not wrapped, since it is a misconfiguration and not that the user being deleted is not found
+			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
+				return fmt.Errorf("user '%s' getting content of deleted users is not found", h.deletedContentTransferTo)
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("error on get new author of content: %w", err)
+		}
+
+		if author.ID == user.ID {
+			return fmt.Errorf("%w: it gets content of deleted users", errUndeletableUser)
+		}
+
+		err = h.articleRepo.TransferByAuthorID(user.ID, author.ID)
+		if err != nil {
+			return fmt.Errorf("error on transfer content: %w", err)
+		}
+	case ContentPolicyDelete:
+		err := h.articleRepo.DeleteByAuthorID(user.ID)
+		if err != nil {
+			return fmt.Errorf("error on delete content: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown content policy '%s'", h.deletedContentPolicy)
+	}
+
+	err := h.articleRepo.RemoveFavoritesByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("error on remove favorites: %w", err)
+	}
+
+	// delete credentials, which storages with foreign keys delete by cascade too
+	err = h.sessionRepo.DeleteByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("error on delete sessions: %w", err)
+	}
+
+	tokens, err := h.personalAccessTokenRepo.ListByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("error on list personal access tokens: %w", err)
+	}
+
+	for _, token := range tokens {
+		err = h.personalAccessTokenRepo.DeleteByID(token.ID)
+		if err != nil && !errors.As(err, &models.PersonalAccessTokenByIDNotFoundError{}) {
+			return fmt.Errorf("error on delete personal access token: %w", err)
+		}
+	}
+
+	for _, purpose := range []models.TokenPurpose{models.TokenPurposePasswordReset, models.TokenPurposeTwoFactorChallenge} {
+		err = h.oneTimeTokenRepo.DeleteByUserID(user.ID, purpose)
+		if err != nil {
+			return fmt.Errorf("error on delete one time tokens: %w", err)
+		}
+	}
+
+	err = h.twoFactorRepo.DeleteByUserID(user.ID)
+	if err != nil && !errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+		return fmt.Errorf("error on delete two factor: %w", err)
+	}
+
+	err = h.identityRepo.DeleteByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("error on delete identities: %w", err)
+	}
+
+	// delete user with follows
+	err = h.userRepo.DeleteByID(user.ID)
+	if err != nil {
+		return fmt.Errorf("error on delete user: %w", err)
+	}
+
+	return nil
+}
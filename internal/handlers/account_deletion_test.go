@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/pkg/totp"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// deleteAccount is the body of DELETE /user confirming it with password and code
+func deleteAccount(password, code string) map[string]interface{} {
+	return map[string]interface{}{"user": map[string]interface{}{"password": password, "code": code}}
+}
+
+func TestDeleteUser(t *testing.T) {
+	tt := map[string]struct {
+		opts []Option
+		// author is username of who gets content of the deleted user, or empty if it is deleted
+		author string
+	}{
+		"Delete":    {opts: []Option{WithDeletedContentPolicy(ContentPolicyDelete, "")}},
+		"Anonymize": {opts: []Option{WithDeletedContentPolicy(ContentPolicyAnonymize, "")}, author: deletedUsername},
+		"Transfer":  {opts: []Option{WithDeletedContentPolicy(ContentPolicyTransfer, "heir")}, author: "heir"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			api := newTestAPI(t, tc.opts...)
+			api.register("heir")
+			alice := api.register("alice")
+			bob := api.register("bob")
+
+			own := api.createArticle(alice.Token, "Own Article")
+			api.addComment(bob.Token, own)
+			others := api.createArticle(bob.Token, "Other Article")
+			api.addComment(alice.Token, others)
+			api.expect(api.request(http.MethodPost, "/articles/"+others+"/favorite", alice.Token, nil), http.StatusOK)
+
+			api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount(testPassword, "")), http.StatusNoContent)
+
+			// content
+			w := api.request(http.MethodGet, "/articles/"+own, "", nil)
+			if tc.author == "" {
+				api.expect(w, http.StatusNotFound)
+			} else {
+				api.expect(w, http.StatusOK)
+
+				var res SingleArticleResponse
+				api.decode(w, &res)
+
+				if res.Article.Author.Username != tc.author {
+					t.Errorf("expected author '%s', but got '%s'", tc.author, res.Article.Author.Username)
+				}
+			}
+
+			w = api.request(http.MethodGet, "/articles/"+others+"/comments", "", nil)
+			api.expect(w, http.StatusOK)
+
+			var comments MultipleCommentsResponse
+			api.decode(w, &comments)
+
+			if tc.author == "" && len(comments.Comments) != 0 {
+				t.Errorf("expected comments of deleted user deleted, but got %d", len(comments.Comments))
+			}
+
+			if tc.author != "" && (len(comments.Comments) != 1 || comments.Comments[0].Author.Username != tc.author) {
+				t.Errorf("expected comment of '%s', but got %+v", tc.author, comments.Comments)
+			}
+
+			w = api.request(http.MethodGet, "/articles/"+others, "", nil)
+			api.expect(w, http.StatusOK)
+
+			var article SingleArticleResponse
+			api.decode(w, &article)
+
+			if article.Article.FavoritesCount != 0 {
+				t.Errorf("expected favorites of deleted user removed, but got %d", article.Article.FavoritesCount)
+			}
+
+			// credentials
+			api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusUnauthorized)
+
+			login := map[string]interface{}{"user": map[string]interface{}{"email": alice.Email, "password": testPassword}}
+			api.expect(api.request(http.MethodPost, "/users/login", "", login), http.StatusUnauthorized)
+			api.expect(api.request(http.MethodGet, "/profiles/alice", "", nil), http.StatusNotFound)
+		})
+	}
+}
+
+func TestDeleteUserConfirmation(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount("wrong-password", "")), http.StatusUnprocessableEntity)
+	})
+
+	// enable two factor authentication
+	w := api.request(http.MethodPost, "/user/2fa/totp", alice.Token, nil)
+	api.expect(w, http.StatusOK)
+
+	var enrollment TOTPEnrollmentResponse
+	api.decode(w, &enrollment)
+
+	code, err := totp.Code(enrollment.TOTP.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("error on generate totp code: %s", err.Error())
+	}
+
+	w = api.request(http.MethodPost, "/user/2fa/totp/confirm", alice.Token, map[string]interface{}{"totp": map[string]interface{}{"code": code}})
+	api.expect(w, http.StatusOK)
+
+	var recovery RecoveryCodesResponse
+	api.decode(w, &recovery)
+
+	t.Run("Missing Code", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount(testPassword, "")), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Wrong Code", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount(testPassword, "000000")), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Wrong Password With Code", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount("wrong-password", recovery.RecoveryCodes[0])), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		user, err := api.h.userRepo.GetByUsername("alice")
+		if err != nil {
+			t.Fatalf("error on get user: %s", err.Error())
+		}
+
+		api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount(testPassword, recovery.RecoveryCodes[0])), http.StatusNoContent)
+
+		if _, err := api.h.twoFactorRepo.GetByUserID(user.ID); err == nil {
+			t.Error("expected two factor of deleted user deleted")
+		}
+	})
+}
+
+func TestDeleteUserTransferTarget(t *testing.T) {
+	api := newTestAPI(t, WithDeletedContentPolicy(ContentPolicyTransfer, "heir"))
+	heir := api.register("heir")
+	admin := api.register("admin")
+	api.setRole("admin", models.RoleAdmin)
+
+	api.expect(api.request(http.MethodDelete, "/user", heir.Token, deleteAccount(testPassword, "")), http.StatusConflict)
+	api.expect(api.request(http.MethodDelete, "/admin/users/heir", admin.Token, nil), http.StatusConflict)
+	api.expect(api.request(http.MethodGet, "/profiles/heir", "", nil), http.StatusOK)
+}
+
+func TestDeleteUserMissingTransferTarget(t *testing.T) {
+	api := newTestAPI(t, WithDeletedContentPolicy(ContentPolicyTransfer, "nobody"))
+	alice := api.register("alice")
+
+	// the user being deleted exists, so a missing transfer user is a misconfiguration and not 404
+	api.expect(api.request(http.MethodDelete, "/user", alice.Token, deleteAccount(testPassword, "")), http.StatusInternalServerError)
+	api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusOK)
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.register("admin")
+	api.setRole("admin", models.RoleAdmin)
+	alice := api.register("alice")
+	bob := api.register("bob")
+
+	t.Run("Not Admin", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/users/bob", alice.Token, nil), http.StatusForbidden)
+	})
+
+	t.Run("Own Account", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/users/admin", admin.Token, nil), http.StatusForbidden)
+	})
+
+	t.Run("Unknown User", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/users/nobody", admin.Token, nil), http.StatusNotFound)
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/users/bob", admin.Token, nil), http.StatusNoContent)
+		api.expect(api.request(http.MethodGet, "/user", bob.Token, nil), http.StatusUnauthorized)
+		api.expect(api.request(http.MethodGet, "/profiles/bob", "", nil), http.StatusNotFound)
+	})
+}
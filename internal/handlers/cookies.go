@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tokenCookie, refreshTokenCookie and csrfCookie keep tokens of a session in cookie mode.
+// The first two are not readable by scripts, and the refresh token is sent to the refresh endpoint only.
+const (
+	tokenCookie        = "token"
+	refreshTokenCookie = "refresh_token"
+	csrfCookie         = "csrf_token"
+)
+
+// csrfHeader is the header of the csrf token on unsafe requests authenticated by the token cookie
+const csrfHeader = "X-CSRF-Token"
+
+// audienceCSRF is the audience of csrf tokens, so they are not mistaken for other tokens
+const audienceCSRF = "csrf"
+
+type csrfClaims struct {
+	SessionID string `json:"sid"`
+}
+
+// setAuthCookies sets the token, refresh token and csrf token cookies of session on w, if cookie mode is enabled
+func (h *handler) setAuthCookies(w http.ResponseWriter, session *models.Session, token, refreshToken string) error {
+	if !h.authCookie {
+		return nil
+	}
+
+	// csrf tokens are signed for the session, so one set by another site, e.g. a sibling domain, does not pass
+	csrfToken := jwt.New()
+	csrfToken.SetIssuedAt(time.Now())
+	csrfToken.SetExpirationTime(session.ExpiresAt)
+	csrfToken.SetAudience(audienceCSRF)
+
+	err := csrfToken.SetClaims(csrfClaims{SessionID: session.ID})
+	if err != nil {
+		return err
+	}
+
+	csrf, err := h.keys.Sign(csrfToken)
+	if err != nil {
+		return fmt.Errorf("error on sign csrf token: %w", err)
+	}
+
+	maxAge := int(time.Until(session.ExpiresAt).Seconds())
+
+	for _, cookie := range []http.Cookie{
+		{Name: tokenCookie, Value: token, Path: "/", MaxAge: int(h.lifetime.Seconds()), HttpOnly: true},
+		{Name: refreshTokenCookie, Value: refreshToken, Path: "/users/refresh", MaxAge: maxAge, HttpOnly: true},
+		{Name: csrfCookie, Value: csrf, Path: "/", MaxAge: maxAge},
+	} {
+		cookie.Secure = h.authCookieSecure
+		cookie.SameSite = http.SameSiteLaxMode
+		http.SetCookie(w, &cookie)
+	}
+
+	return nil
+}
+
+// clearAuthCookies clears the cookies set by setAuthCookies, if cookie mode is enabled
+func (h *handler) clearAuthCookies(w http.ResponseWriter) {
+	if !h.authCookie {
+		return
+	}
+
+	for _, cookie := range []http.Cookie{
+		{Name: tokenCookie, Path: "/", HttpOnly: true},
+		{Name: refreshTokenCookie, Path: "/users/refresh", HttpOnly: true},
+		{Name: csrfCookie, Path: "/"},
+	} {
+		cookie.MaxAge = -1
+		cookie.Secure = h.authCookieSecure
+		cookie.SameSite = http.SameSiteLaxMode
+		http.SetCookie(w, &cookie)
+	}
+}
+
+// verifyCSRFToken checks that the csrf header of r is a csrf token of session with sessionID
+func (h *handler) verifyCSRFToken(r *http.Request, sessionID string) error {
+	header := r.Header.Get(csrfHeader)
+	if header == "" {
+		return errors.New("missing csrf token")
+	}
+
+	token, err := h.keys.ParseAndVerify(
+		header,
+		jwt.WithAudience(audienceCSRF),
+		jwt.WithRequiredClaims(jwt.ClaimExpirationTime),
+	)
+	if err != nil {
+		return err
+	}
+
+	var claims csrfClaims
+
+	err = token.Claims(&claims)
+	if err != nil {
+		return err
+	}
+
+	if claims.SessionID == "" || subtle.ConstantTimeCompare([]byte(claims.SessionID), []byte(sessionID)) != 1 {
+		return errors.New("csrf token is of another session")
+	}
+
+	return nil
+}
+
+// authorizationSchemes are the schemes of Authorization header, which are matched case-insensitively
+var authorizationSchemes = []string{"Token", "Bearer"}
+
+// requestToken returns the token of r from its Authorization header, or from the token cookie if enabled and the header is not set
+func (h *handler) requestToken(r *http.Request) (tokenStr string, fromCookie bool, err error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if h.authCookie {
+			if cookie, err := r.Cookie(tokenCookie); err == nil && cookie.Value != "" {
+				return cookie.Value, true, nil
+			}
+		}
+
+		return "", false, errors.New("missing authorization header")
+	}
+
+	scheme, tokenStr, _ := strings.Cut(authHeader, " ")
+	tokenStr = strings.TrimSpace(tokenStr)
+
+	for _, v := range authorizationSchemes {
+		if strings.EqualFold(scheme, v) && tokenStr != "" {
+			return tokenStr, false, nil
+		}
+	}
+
+	return "", false, errors.New("invalid authorization header")
+}
+
+// safeMethod reports whether method is one which should not change anything
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
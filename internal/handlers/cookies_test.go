@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cookieRequest sends a request authenticated by cookies only, with csrf token in its header if not empty
+func (api *testAPI) cookieRequest(method, path string, cookies map[string]string, csrf string, body interface{}) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	r := httptest.NewRequest(method, path, bytes.NewReader(data))
+	for name, value := range cookies {
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	if csrf != "" {
+		r.Header.Set(csrfHeader, csrf)
+	}
+
+	w := httptest.NewRecorder()
+	api.h.ServeHTTP(w, r)
+
+	return w
+}
+
+// cookiesOf returns cookies w sets by name
+func cookiesOf(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	res := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		res[cookie.Name] = cookie
+	}
+
+	return res
+}
+
+// cookieLogin logs in user with username in cookie mode, returning the cookies set
+func (api *testAPI) cookieLogin(username string) map[string]*http.Cookie {
+	api.t.Helper()
+
+	body := map[string]interface{}{"user": map[string]interface{}{"email": username + "@example.com", "password": testPassword}}
+
+	w := api.request(http.MethodPost, "/users/login", "", body)
+	api.expect(w, http.StatusOK)
+
+	cookies := cookiesOf(w)
+	for _, name := range []string{tokenCookie, refreshTokenCookie, csrfCookie} {
+		if cookies[name] == nil || cookies[name].Value == "" {
+			api.t.Fatalf("expected cookie '%s' set on login", name)
+		}
+	}
+
+	return cookies
+}
+
+func TestAuthCookie(t *testing.T) {
+	api := newTestAPI(t, WithAuthCookie(true))
+	alice := api.register("alice")
+	bob := api.register("bob")
+	cookies := api.cookieLogin("alice")
+	token := map[string]string{tokenCookie: cookies[tokenCookie].Value}
+	csrf := cookies[csrfCookie].Value
+
+	article := map[string]interface{}{"article": map[string]interface{}{"title": "How To", "description": "description", "body": "body"}}
+
+	t.Run("Cookies", func(t *testing.T) {
+		if !cookies[tokenCookie].HttpOnly || !cookies[refreshTokenCookie].HttpOnly || cookies[csrfCookie].HttpOnly {
+			t.Error("expected token cookies not readable by scripts, and csrf cookie readable")
+		}
+
+		if cookies[refreshTokenCookie].Path != "/users/refresh" {
+			t.Errorf("expected refresh token cookie path '/users/refresh', but got '%s'", cookies[refreshTokenCookie].Path)
+		}
+
+		if !cookies[tokenCookie].Secure {
+			t.Error("expected secure cookies")
+		}
+	})
+
+	t.Run("Safe Request", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodGet, "/user", token, "", nil), http.StatusOK)
+	})
+
+	t.Run("Missing CSRF Token", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodPost, "/articles", token, "", article), http.StatusForbidden)
+	})
+
+	t.Run("CSRF Token Of Another Session", func(t *testing.T) {
+		other := api.cookieLogin("alice")
+
+		api.expect(api.cookieRequest(http.MethodPost, "/articles", token, other[csrfCookie].Value, article), http.StatusForbidden)
+	})
+
+	t.Run("Invalid CSRF Token", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodPost, "/articles", token, alice.Token, article), http.StatusForbidden)
+	})
+
+	t.Run("CSRF Token", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodPost, "/articles", token, csrf, article), http.StatusCreated)
+	})
+
+	t.Run("CSRF Token As Access Token", func(t *testing.T) {
+		api.expect(api.request(http.MethodGet, "/user", csrf, nil), http.StatusUnauthorized)
+		api.expect(api.cookieRequest(http.MethodGet, "/user", map[string]string{tokenCookie: csrf}, "", nil), http.StatusUnauthorized)
+	})
+
+	t.Run("Authorization Header First", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/user", nil)
+		r.AddCookie(&http.Cookie{Name: tokenCookie, Value: token[tokenCookie]})
+		r.Header.Set("Authorization", "Bearer "+bob.Token)
+
+		w := httptest.NewRecorder()
+		api.h.ServeHTTP(w, r)
+		api.expect(w, http.StatusOK)
+
+		var res UserResponse
+		api.decode(w, &res)
+
+		if res.User.Username != "bob" {
+			t.Errorf("expected user of Authorization header 'bob', but got '%s'", res.User.Username)
+		}
+
+		// an invalid header is not overridden by the cookie
+		r = httptest.NewRequest(http.MethodGet, "/user", nil)
+		r.AddCookie(&http.Cookie{Name: tokenCookie, Value: token[tokenCookie]})
+		r.Header.Set("Authorization", "Token invalid")
+
+		w = httptest.NewRecorder()
+		api.h.ServeHTTP(w, r)
+		api.expect(w, http.StatusUnauthorized)
+
+		// requests authenticated by header do not need csrf tokens, since browsers do not send it on their own
+		r = httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(`{"article":{"title":"By Header","description":"description","body":"body"}}`))
+		r.AddCookie(&http.Cookie{Name: tokenCookie, Value: token[tokenCookie]})
+		r.Header.Set("Authorization", "Token "+bob.Token)
+
+		w = httptest.NewRecorder()
+		api.h.ServeHTTP(w, r)
+		api.expect(w, http.StatusCreated)
+	})
+}
+
+func TestAuthCookieRefreshAndLogout(t *testing.T) {
+	api := newTestAPI(t, WithAuthCookie(false))
+	api.register("alice")
+	cookies := api.cookieLogin("alice")
+	refreshCookie := map[string]string{refreshTokenCookie: cookies[refreshTokenCookie].Value}
+
+	if cookies[tokenCookie].Secure {
+		t.Error("expected cookies not secure")
+	}
+
+	t.Run("Refresh Without CSRF Token", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodPost, "/users/refresh", refreshCookie, "", nil), http.StatusForbidden)
+	})
+
+	// refresh by cookie with an empty body
+	w := api.cookieRequest(http.MethodPost, "/users/refresh", refreshCookie, cookies[csrfCookie].Value, nil)
+	api.expect(w, http.StatusOK)
+
+	rotated := cookiesOf(w)
+	for _, name := range []string{tokenCookie, refreshTokenCookie} {
+		if rotated[name] == nil || rotated[name].Value == "" || rotated[name].Value == cookies[name].Value {
+			t.Fatalf("expected cookie '%s' rotated on refresh", name)
+		}
+	}
+
+	// the csrf token is of the same session, so it is only extended
+	if rotated[csrfCookie] == nil || rotated[csrfCookie].Value == "" {
+		t.Fatal("expected csrf cookie set on refresh")
+	}
+
+	t.Run("Old Refresh Token", func(t *testing.T) {
+		api.expect(api.cookieRequest(http.MethodPost, "/users/refresh", refreshCookie, rotated[csrfCookie].Value, nil), http.StatusUnauthorized)
+
+		// reusing a refresh token ends its session, so the rotated tokens stop working too
+		api.expect(api.cookieRequest(http.MethodGet, "/user", map[string]string{tokenCookie: rotated[tokenCookie].Value}, "", nil), http.StatusUnauthorized)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		cookies := api.cookieLogin("alice")
+		token := map[string]string{tokenCookie: cookies[tokenCookie].Value}
+
+		api.expect(api.cookieRequest(http.MethodPost, "/users/logout", token, "", nil), http.StatusForbidden)
+
+		w := api.cookieRequest(http.MethodPost, "/users/logout", token, cookies[csrfCookie].Value, nil)
+		api.expect(w, http.StatusNoContent)
+
+		cleared := cookiesOf(w)
+		for _, name := range []string{tokenCookie, refreshTokenCookie, csrfCookie} {
+			if cleared[name] == nil || cleared[name].Value != "" || cleared[name].MaxAge >= 0 {
+				t.Errorf("expected cookie '%s' cleared on logout, but got %v", name, cleared[name])
+			}
+		}
+
+		api.expect(api.cookieRequest(http.MethodGet, "/user", token, "", nil), http.StatusUnauthorized)
+	})
+}
+
+func TestAuthCookieDisabled(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+
+	body := map[string]interface{}{"user": map[string]interface{}{"email": "alice@example.com", "password": testPassword}}
+
+	w := api.request(http.MethodPost, "/users/login", "", body)
+	api.expect(w, http.StatusOK)
+
+	if len(w.Result().Cookies()) != 0 {
+		t.Errorf("expected no cookies without cookie mode, but got %v", w.Result().Cookies())
+	}
+
+	var res UserResponse
+	api.decode(w, &res)
+
+	api.expect(api.cookieRequest(http.MethodGet, "/user", map[string]string{tokenCookie: res.User.Token}, "", nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy is which cross-origin requests browsers let scripts send and read responses of
+type CORSPolicy struct {
+	// AllowedOrigins are origins like https://example.com, or patterns like https://*.example.com, or * for any origin.
+	// It defaults to any origin if nil.
+	AllowedOrigins []string
+	// AllowedMethods are methods of preflight responses, which default to methods of the route if nil
+	AllowedMethods []string
+	// AllowedHeaders are request headers of preflight responses, or * for any request header.
+	// It defaults to Authorization, Content-Type and X-CSRF-Token if nil.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts can read, other than safelisted ones like Content-Type
+	ExposedHeaders []string
+	// AllowCredentials lets requests have cookies, like in cookie mode, and lets scripts read their responses.
+	// It needs AllowedOrigins without *, since any site could then send requests with cookies of users.
+	AllowCredentials bool
+	// MaxAge is how long browsers cache preflight responses, which is up to browsers if zero
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSAllowedOrigins = []string{"*"}
+	defaultCORSAllowedHeaders = []string{"Authorization", "Content-Type", csrfHeader}
+)
+
+// Validate returns error if p allows credentials of any origin, where nil AllowedOrigins are any origin
+func (p CORSPolicy) Validate() error {
+	if !p.AllowCredentials {
+		return nil
+	}
+
+	origins := p.AllowedOrigins
+	if origins == nil {
+		origins = defaultCORSAllowedOrigins
+	}
+
+	for _, v := range origins {
+		if v == "*" {
+			return errors.New("allowed origins should not have * while credentials are allowed")
+		}
+	}
+
+	return nil
+}
+
+// allowsOrigin reports whether origin matches any of allowed origins, where * of patterns matches any characters but /.
+// * as an allowed origin is ignored if credentials are allowed, even though Validate rejects it.
+func (p *CORSPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	for _, v := range p.AllowedOrigins {
+		if v == "*" {
+			if p.AllowCredentials {
+				continue
+			}
+
+			return true
+		}
+
+		if ok, _ := path.Match(strings.ToLower(v), origin); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyOrigin reports whether responses are the same for all origins, so they are responded * as allowed origin
+func (p *CORSPolicy) anyOrigin() bool {
+	if p.AllowCredentials {
+		return false
+	}
+
+	for _, v := range p.AllowedOrigins {
+		if v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedHeaders returns allowed headers of preflight r, which are its requested headers if any header is allowed
+func (p *CORSPolicy) allowedHeaders(r *http.Request) string {
+	for _, v := range p.AllowedHeaders {
+		if v == "*" {
+			return r.Header.Get("Access-Control-Request-Headers")
+		}
+	}
+
+	return strings.Join(p.AllowedHeaders, ", ")
+}
+
+// maxAge returns MaxAge in seconds
+func (p *CORSPolicy) maxAge() string {
+	return strconv.Itoa(int(p.MaxAge / time.Second))
+}
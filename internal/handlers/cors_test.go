@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCORSHandler(policy CORSPolicy) *handler {
+	h := &handler{cors: policy}
+	h.router.options = h.handleCORS()
+	h.router.handle(http.MethodGet, "/articles", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusOK)
+	})
+	h.router.handle(http.MethodPost, "/articles", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	return h
+}
+
+func TestCORS(t *testing.T) {
+	policy := CORSPolicy{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowedHeaders:   defaultCORSAllowedHeaders,
+		ExposedHeaders:   []string{"Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	tt := map[string]struct {
+		policy  CORSPolicy
+		method  string
+		headers map[string]string
+		status  int
+		// expected are response headers, where empty ones are expected to be missing
+		expected map[string]string
+	}{
+		"Preflight": {
+			policy:  policy,
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type"},
+			status:  http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, HEAD, OPTIONS, POST",
+				"Access-Control-Allow-Headers":     "Authorization, Content-Type, X-CSRF-Token",
+				"Access-Control-Max-Age":           "600",
+			},
+		},
+		"Preflight Of Pattern": {
+			policy:   policy,
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://api.example.org", "Access-Control-Request-Method": "POST"},
+			status:   http.StatusNoContent,
+			expected: map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+		},
+		"Preflight Of Disallowed Origin": {
+			policy:  policy,
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://example.org.evil.com", "Access-Control-Request-Method": "POST"},
+			status:  http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+				"Access-Control-Allow-Headers": "",
+			},
+		},
+		"Actual Request": {
+			policy:  policy,
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusOK,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Retry-After",
+				"Access-Control-Allow-Methods":     "",
+				"Vary":                             "Origin",
+			},
+		},
+		"Actual Request Of Disallowed Origin": {
+			policy:  policy,
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusOK,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":   "",
+				"Access-Control-Expose-Headers": "",
+			},
+		},
+		"Any Origin": {
+			policy:  CORSPolicy{AllowedOrigins: defaultCORSAllowedOrigins, AllowedHeaders: []string{"*"}, AllowedMethods: []string{"GET", "POST"}},
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type, x-custom"},
+			status:  http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "content-type, x-custom",
+				"Access-Control-Max-Age":           "",
+			},
+		},
+		"Any Origin With Credentials": {
+			policy:  CORSPolicy{AllowedOrigins: defaultCORSAllowedOrigins, AllowCredentials: true},
+			method:  http.MethodPost,
+			headers: map[string]string{"Origin": "https://example.com", "Cookie": "token=value"},
+			status:  http.StatusCreated,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		"Credentialed Request Of Disallowed Origin": {
+			policy:  CORSPolicy{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true},
+			method:  http.MethodPost,
+			headers: map[string]string{"Origin": "https://evil.com", "Cookie": "token=value"},
+			status:  http.StatusCreated,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+				"Vary":                             "Origin",
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/articles", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			newTestCORSHandler(tc.policy).ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status %d, but got %d", tc.status, w.Code)
+			}
+
+			for header, expected := range tc.expected {
+				if actual := w.Header().Get(header); actual != expected {
+					t.Errorf("expected %s '%s', but got '%s'", header, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSPolicyValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy CORSPolicy
+		valid  bool
+	}{
+		"Default":                     {policy: CORSPolicy{}, valid: true},
+		"Default With Credentials":    {policy: CORSPolicy{AllowCredentials: true}, valid: false},
+		"Any Origin With Credentials": {policy: CORSPolicy{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true}, valid: false},
+		"Origins With Credentials":    {policy: CORSPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, valid: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.policy.Validate(); (err == nil) != tc.valid {
+				t.Errorf("expected valid %v, but got error %v", tc.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/mail"
+	netMail "net/mail"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// audienceEmailVerification is the audience of email verification tokens, so they are not mistaken for other tokens
+const audienceEmailVerification = "email-verification"
+
+// claimEmail is the private claim of email verification tokens, holding the email they verify,
+// so they stop working if user changes it
+const claimEmail = "email"
+
+type emailVerificationClaims struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// sendVerificationEmail emails user a link with a signed token proving they own their email
+func (h *handler) sendVerificationEmail(user models.User) error {
+	now := time.Now()
+
+	token := jwt.New()
+	token.SetIssuedAt(now)
+	token.SetExpirationTime(now.Add(h.emailVerificationLifetime))
+	token.SetAudience(audienceEmailVerification)
+
+	err := token.SetClaims(emailVerificationClaims{
+		Subject: strconv.Itoa(user.ID),
+		Email:   user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	signed, err := h.keys.Sign(token)
+	if err != nil {
+		return fmt.Errorf("error on sign email verification token: %w", err)
+	}
+
+	link, err := url.Parse(h.emailVerificationURL)
+	if err != nil {
+		return fmt.Errorf("error on parse email verification url: %w", err)
+	}
+
+	query := link.Query()
+	query.Set("token", signed)
+	link.RawQuery = query.Encode()
+
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen this link to verify your email in %s:\n\n%s\n\nIf you did not sign up, you can ignore this email.\n",
+			user.Username, h.emailVerificationLifetime, link,
+		),
+	})
+}
+
+// validEmail reports whether email is a bare address, like alice@example.com
+func validEmail(email string) bool {
+	addr, err := netMail.ParseAddress(email)
+
+	return err == nil && addr.Name == "" && addr.Address == email
+}
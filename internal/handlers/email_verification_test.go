@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// verificationToken returns the token of the last verification link sent to email
+func (api *testAPI) verificationToken(email string) string {
+	api.t.Helper()
+
+	link, err := url.Parse(api.mailed(email))
+	if err != nil {
+		api.t.Fatalf("error on parse verification link: %s", err.Error())
+	}
+
+	return link.Query().Get("token")
+}
+
+// verifyEmail verifies email by token, expecting status
+func (api *testAPI) verifyEmail(token string, status int) {
+	api.t.Helper()
+
+	api.expect(api.request(http.MethodGet, "/users/verify-email?token="+url.QueryEscape(token), "", nil), status)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	token := api.verificationToken(alice.Email)
+
+	if alice.Verified {
+		t.Fatal("expected user not verified on registration")
+	}
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		api.verifyEmail(alice.Token, http.StatusUnauthorized)
+	})
+
+	api.verifyEmail(token, http.StatusNoContent)
+
+	if user := api.login("alice"); !user.Verified {
+		t.Error("expected user verified")
+	}
+
+	t.Run("Used Token", func(t *testing.T) {
+		api.verifyEmail(token, http.StatusUnprocessableEntity)
+		api.expect(api.request(http.MethodPost, "/user/verification-email", alice.Token, nil), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		bob := api.register("bob")
+
+		user, err := api.h.userRepo.GetByUsername("bob")
+		if err != nil {
+			t.Fatalf("error on get user: %s", err.Error())
+		}
+
+		expired := jwt.New()
+		expired.SetExpirationTime(time.Now().Add(-time.Minute))
+		expired.SetAudience(audienceEmailVerification)
+
+		err = expired.SetClaims(emailVerificationClaims{Subject: strconv.Itoa(user.ID), Email: bob.Email})
+		if err != nil {
+			t.Fatalf("error on set claims: %s", err.Error())
+		}
+
+		signed, err := api.h.keys.Sign(expired)
+		if err != nil {
+			t.Fatalf("error on sign token: %s", err.Error())
+		}
+
+		api.verifyEmail(signed, http.StatusUnauthorized)
+
+		if user := api.login("bob"); user.Verified {
+			t.Error("expected user not verified by expired token")
+		}
+	})
+
+	t.Run("Email Change", func(t *testing.T) {
+		body := map[string]interface{}{"user": map[string]interface{}{"email": "alice@example.org"}}
+
+		w := api.request(http.MethodPut, "/user", alice.Token, body)
+		api.expect(w, http.StatusOK)
+
+		var res UserResponse
+		api.decode(w, &res)
+
+		if res.User.Verified {
+			t.Error("expected user not verified after email change")
+		}
+
+		// tokens of the previous email do not verify the new one
+		api.verifyEmail(token, http.StatusUnauthorized)
+		api.verifyEmail(api.verificationToken("alice@example.org"), http.StatusNoContent)
+	})
+}
+
+func TestEmailVerificationRequired(t *testing.T) {
+	api := newTestAPI(t, WithEmailVerificationRequired(true))
+	alice := api.register("alice")
+	bob := api.register("bob")
+	api.verifyEmail(api.verificationToken(bob.Email), http.StatusNoContent)
+	slug := api.createArticle(bob.Token, "How To")
+
+	article := map[string]interface{}{"article": map[string]interface{}{"title": "Unverified", "description": "description", "body": "body"}}
+	comment := map[string]interface{}{"comment": map[string]interface{}{"body": "comment"}}
+
+	api.expect(api.request(http.MethodPost, "/articles", alice.Token, article), http.StatusForbidden)
+	api.expect(api.request(http.MethodPost, "/articles/"+slug+"/comments", alice.Token, comment), http.StatusForbidden)
+
+	// other actions are allowed
+	api.expect(api.request(http.MethodPost, "/articles/"+slug+"/favorite", alice.Token, nil), http.StatusOK)
+
+	api.verifyEmail(api.verificationToken(alice.Email), http.StatusNoContent)
+
+	api.createArticle(alice.Token, "Verified")
+	api.addComment(alice.Token, slug)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	uniqueID "github.com/nasermirzaei89/realworld-go/pkg/id"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// problemContentType is of RFC 7807 problem details, responded to clients accepting it
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response, with errors of request fields and id of server errors as extensions
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	ErrorID  string              `json:"errorId,omitempty"`
+	Errors   map[string][]string `json:"errors,omitempty"`
+}
+
+// statusOf returns the status of responding err by its domain error, or 500 if it has none
+func statusOf(err error) int {
+	switch {
+	case errors.As(err, &models.ArticleBySlugNotFoundError{}),
+		errors.As(err, &models.CommentByIDNotFoundError{}),
+		errors.As(err, &models.UserByUsernameNotFoundError{}),
+		errors.As(err, &models.UserByIDNotFoundError{}),
+		errors.As(err, &models.UserByEmailNotFoundError{}),
+		errors.As(err, &models.PersonalAccessTokenByIDNotFoundError{}),
+		errors.As(err, &models.SessionByIDNotFoundError{}),
+		errors.As(err, &models.IdentityByIssuerAndSubjectNotFoundError{}),
+		errors.As(err, &models.TwoFactorByUserIDNotFoundError{}),
+		errors.As(err, &models.LockoutByIDNotFoundError{}):
+		return http.StatusNotFound
+	case errors.As(err, &models.RefreshTokenMismatchError{}):
+		return http.StatusUnauthorized
+	case errors.Is(err, errUndeletableUser):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError responds err by the status of its domain error, with the domain error as message.
+// Other errors are responded 500 with message, and logged with an error id the response has, so they can be found.
+func (h *handler) respondError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	status := statusOf(err)
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	h.respondStatus(w, r, status, message, err)
+}
+
+// respondStatus responds status with message. For server errors, err is logged with an error id the response has,
+// and it is never responded, since it may have internal details.
+func (h *handler) respondStatus(w http.ResponseWriter, r *http.Request, status int, message string, err error) {
+	var errorID string
+
+	if status >= http.StatusInternalServerError {
+		errorID = uniqueID.New(12)
+
+		h.errorLog.Printf("error %s on %s %s: %s: %v", errorID, r.Method, r.URL.Path, message, err)
+	}
+
+	if acceptsProblem(r) {
+		h.respondProblem(w, r, Problem{Status: status, Detail: message, ErrorID: errorID})
+
+		return
+	}
+
+	res := ErrorResponse{
+		Errors: map[string]interface{}{
+			"message": message,
+		},
+	}
+
+	if errorID != "" {
+		res.Errors["id"] = errorID
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// respondValidationErrors responds 422 with errs of request fields, like {"errors":{"email":["can't be blank"]}}
+func (h *handler) respondValidationErrors(w http.ResponseWriter, r *http.Request, errs validationErrors) {
+	if acceptsProblem(r) {
+		h.respondProblem(w, r, Problem{Status: http.StatusUnprocessableEntity, Detail: "request is invalid", Errors: errs})
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{
+		Errors: errs.response(),
+	})
+}
+
+func (h *handler) respondProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// acceptsProblem reports whether Accept header of r has problem details, unless with zero quality
+func acceptsProblem(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err == nil && mediaType == problemContentType {
+			q, _ := strconv.ParseFloat(params["q"], 64)
+
+			return params["q"] == "" || q > 0
+		}
+	}
+
+	return false
+}
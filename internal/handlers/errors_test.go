@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusOf(t *testing.T) {
+	tt := map[string]struct {
+		err    error
+		status int
+	}{
+		"Not Found":         {err: models.ArticleBySlugNotFoundError{Slug: "how-to"}, status: http.StatusNotFound},
+		"Wrapped Not Found": {err: fmt.Errorf("error on get user: %w", models.UserByIDNotFoundError{ID: 1}), status: http.StatusNotFound},
+		"Mismatch":          {err: models.RefreshTokenMismatchError{}, status: http.StatusUnauthorized},
+		"Undeletable":       {err: fmt.Errorf("%w: last admin", errUndeletableUser), status: http.StatusConflict},
+		"Internal":          {err: errors.New("connection refused"), status: http.StatusInternalServerError},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if actual := statusOf(tc.err); actual != tc.status {
+				t.Errorf("expected status %d, but got %d", tc.status, actual)
+			}
+		})
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	var logs bytes.Buffer
+
+	h := &handler{errorLog: log.New(&logs, "", 0)}
+
+	t.Run("Domain Error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.respondError(w, httptest.NewRequest(http.MethodGet, "/articles/how-to", nil), "get article failed", models.ArticleBySlugNotFoundError{Slug: "how-to"})
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, but got %d", http.StatusNotFound, w.Code)
+		}
+
+		var res ErrorResponse
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		if expected := (models.ArticleBySlugNotFoundError{Slug: "how-to"}).Error(); res.Errors["message"] != expected {
+			t.Errorf("expected message '%s', but got '%v'", expected, res.Errors["message"])
+		}
+
+		if _, ok := res.Errors["id"]; ok {
+			t.Error("expected no error id")
+		}
+	})
+
+	t.Run("Internal Error", func(t *testing.T) {
+		logs.Reset()
+
+		w := httptest.NewRecorder()
+		h.respondError(w, httptest.NewRequest(http.MethodGet, "/articles/how-to", nil), "get article failed", errors.New("connection refused"))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, but got %d", http.StatusInternalServerError, w.Code)
+		}
+
+		if strings.Contains(w.Body.String(), "connection refused") {
+			t.Errorf("expected internal error not to be responded, but got '%s'", w.Body.String())
+		}
+
+		var res ErrorResponse
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		id, _ := res.Errors["id"].(string)
+		if id == "" {
+			t.Fatal("expected error id")
+		}
+
+		if !strings.Contains(logs.String(), id) || !strings.Contains(logs.String(), "connection refused") {
+			t.Errorf("expected error logged with id '%s', but got '%s'", id, logs.String())
+		}
+	})
+
+	t.Run("Problem", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/articles/how-to", nil)
+		r.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+
+		w := httptest.NewRecorder()
+		h.respondError(w, r, "get article failed", errors.New("connection refused"))
+
+		if actual := w.Header().Get("Content-Type"); actual != problemContentType {
+			t.Errorf("expected content type '%s', but got '%s'", problemContentType, actual)
+		}
+
+		var problem Problem
+		_ = json.NewDecoder(w.Body).Decode(&problem)
+
+		if problem.Status != http.StatusInternalServerError || problem.Title != "Internal Server Error" ||
+			problem.Detail != "get article failed" || problem.Instance != "/articles/how-to" || problem.ErrorID == "" {
+			t.Errorf("unexpected problem %+v", problem)
+		}
+	})
+
+	t.Run("Validation Problem", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/users", nil)
+		r.Header.Set("Accept", "application/problem+json")
+
+		w := httptest.NewRecorder()
+		h.respondValidationErrors(w, r, validationErrors{"email": {"can't be blank"}})
+
+		var problem Problem
+		_ = json.NewDecoder(w.Body).Decode(&problem)
+
+		if w.Code != http.StatusUnprocessableEntity || len(problem.Errors["email"]) != 1 {
+			t.Errorf("unexpected problem %+v with status %d", problem, w.Code)
+		}
+	})
+}
+
+func TestAcceptsProblem(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                         false,
+		"application/json":         false,
+		"application/problem+json": true,
+		"application/json, application/problem+json;q=0.5": true,
+		"application/problem+json;q=0":                     false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+
+		if actual := acceptsProblem(r); actual != expected {
+			t.Errorf("Accept '%s': expected %v, but got %v", accept, expected, actual)
+		}
+	}
+}
+
+func TestRespondRouteErrors(t *testing.T) {
+	api := newTestAPI(t)
+
+	t.Run("Not Found", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+		r.Header.Set("Accept", "application/problem+json")
+
+		w := httptest.NewRecorder()
+		api.h.ServeHTTP(w, r)
+		api.expect(w, http.StatusNotFound)
+
+		if actual := w.Header().Get("Content-Type"); actual != problemContentType {
+			t.Errorf("expected content type '%s', but got '%s'", problemContentType, actual)
+		}
+
+		var problem Problem
+		api.decode(w, &problem)
+
+		if problem.Status != http.StatusNotFound || problem.Instance != "/unknown" {
+			t.Errorf("unexpected problem %+v", problem)
+		}
+	})
+
+	t.Run("Method Not Allowed", func(t *testing.T) {
+		w := api.request(http.MethodDelete, "/tags", "", nil)
+		api.expect(w, http.StatusMethodNotAllowed)
+
+		if expected, actual := "GET, HEAD, OPTIONS", w.Header().Get("Allow"); actual != expected {
+			t.Errorf("expected Allow '%s', but got '%s'", expected, actual)
+		}
+
+		var res ErrorResponse
+		api.decode(w, &res)
+
+		if res.Errors["message"] == nil {
+			t.Errorf("expected error message, but got %+v", res)
+		}
+	})
+}
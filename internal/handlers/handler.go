@@ -1,10 +1,16 @@
 package handlers
 
 import (
-	"context"
 	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/mail"
+	"github.com/nasermirzaei89/realworld-go/pkg/oidc"
+	"github.com/nasermirzaei89/realworld-go/pkg/password"
+	"log"
 	"net/http"
-	"regexp"
+	"os"
+	"sync"
+	"time"
 )
 
 type Handler interface {
@@ -12,23 +18,309 @@ type Handler interface {
 }
 
 type handler struct {
-	userRepo    models.UserRepository
-	articleRepo models.ArticleRepository
-	routes      []route
-	secret      []byte
+	userRepo                models.UserRepository
+	articleRepo             models.ArticleRepository
+	sessionRepo             models.SessionRepository
+	personalAccessTokenRepo models.PersonalAccessTokenRepository
+	oneTimeTokenRepo        models.OneTimeTokenRepository
+	twoFactorRepo           models.TwoFactorRepository
+	loginThrottleRepo       models.LoginThrottleRepository
+	identityRepo            models.IdentityRepository
+	router                  router
+	keys                    *jwt.KeySet
+	hasher                  password.Hasher
+	mailer                  mail.Mailer
+	lifetime                time.Duration
+	// refreshLifetime is how long a session lasts without refreshing it
+	refreshLifetime time.Duration
+	// passwordResetLifetime is how long a password reset token is valid
+	passwordResetLifetime time.Duration
+	// emailVerificationURL is the link in verification emails, which gets the token as query parameter
+	emailVerificationURL      string
+	emailVerificationLifetime time.Duration
+	// emailVerificationRequired blocks users from creating articles and comments until they verify their email
+	emailVerificationRequired bool
+	// totpIssuer names the service in authenticator apps
+	totpIssuer string
+	// adminEmails are of users who are admins from registration
+	adminEmails map[string]bool
+	// loginMaxFailures and loginMaxIPFailures are how many failed logins of an account and an ip lock them out
+	loginMaxFailures   int
+	loginMaxIPFailures int
+	// loginLockout is how long the first lockout lasts, doubling for each failure after it up to loginMaxLockout
+	loginLockout    time.Duration
+	loginMaxLockout time.Duration
+	// clientIPHeader is the header a trusted proxy sets to the client ip, which is the remote address if empty
+	clientIPHeader string
+	// dummyPasswordHash is verified on logins with unknown emails, so they take as long as others
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+	// deletedContentPolicy is what happens to articles and comments of deleted users,
+	// and deletedContentTransferTo is the username of who gets them by ContentPolicyTransfer
+	deletedContentPolicy     ContentPolicy
+	deletedContentTransferTo string
+	// oidcProvider is the OpenID provider users can log in with, which is disabled if nil
+	oidcProvider *oidc.Provider
+	// authCookie enables setting session tokens in cookies for browsers, and authenticating by them,
+	// and authCookieSecure sends them on https only
+	authCookie       bool
+	authCookieSecure bool
+	// errorLog logs server errors with their error ids
+	errorLog *log.Logger
+	// cors is the policy of cross-origin requests
+	cors CORSPolicy
 }
 
-type route struct {
-	Method      string
-	Pattern     regexp.Regexp
-	HandlerFunc http.HandlerFunc
+// ContentPolicy is what happens to articles and comments of deleted users
+type ContentPolicy string
+
+const (
+	ContentPolicyDelete ContentPolicy = "delete"
+	// ContentPolicyAnonymize keeps content with a "deleted" user as its author
+	ContentPolicyAnonymize ContentPolicy = "anonymize"
+	// ContentPolicyTransfer keeps content with another user as its author
+	ContentPolicyTransfer ContentPolicy = "transfer"
+)
+
+// Option configures optional dependencies of handler
+type Option func(h *handler)
+
+// WithPasswordHasher sets the hasher of user passwords, which defaults to bcrypt with default cost
+func WithPasswordHasher(hasher password.Hasher) Option {
+	return func(h *handler) {
+		h.hasher = hasher
+	}
+}
+
+// WithTokenLifetime sets how long issued access tokens are valid, which defaults to 15 minutes
+func WithTokenLifetime(lifetime time.Duration) Option {
+	return func(h *handler) {
+		h.lifetime = lifetime
+	}
+}
+
+// WithRefreshTokenLifetime sets how long refresh tokens are valid, which defaults to 30 days.
+// Each refresh issues a new refresh token, so active sessions last longer.
+func WithRefreshTokenLifetime(lifetime time.Duration) Option {
+	return func(h *handler) {
+		h.refreshLifetime = lifetime
+	}
+}
+
+// WithMailer sets the mailer of emails to users, which defaults to writing them to stdout
+func WithMailer(mailer mail.Mailer) Option {
+	return func(h *handler) {
+		h.mailer = mailer
+	}
+}
+
+// WithPasswordResetLifetime sets how long password reset tokens are valid, which defaults to 1 hour
+func WithPasswordResetLifetime(lifetime time.Duration) Option {
+	return func(h *handler) {
+		h.passwordResetLifetime = lifetime
+	}
+}
+
+// WithEmailVerificationURL sets the link in verification emails, which gets the token as query parameter.
+// It defaults to the verification endpoint on localhost, and can point to a frontend page calling the endpoint instead.
+func WithEmailVerificationURL(url string) Option {
+	return func(h *handler) {
+		h.emailVerificationURL = url
+	}
+}
+
+// WithEmailVerificationLifetime sets how long email verification links are valid, which defaults to 24 hours
+func WithEmailVerificationLifetime(lifetime time.Duration) Option {
+	return func(h *handler) {
+		h.emailVerificationLifetime = lifetime
+	}
+}
+
+// WithEmailVerificationRequired blocks users from creating articles and comments until they verify their email
+func WithEmailVerificationRequired(required bool) Option {
+	return func(h *handler) {
+		h.emailVerificationRequired = required
+	}
+}
+
+// WithTOTPIssuer sets the name of the service in authenticator apps, which defaults to RealWorld
+func WithTOTPIssuer(issuer string) Option {
+	return func(h *handler) {
+		h.totpIssuer = issuer
+	}
+}
+
+// WithAdminEmails makes users with emails admins once they verify them, so the first admin can be set up
+func WithAdminEmails(emails ...string) Option {
+	return func(h *handler) {
+		for _, email := range emails {
+			h.adminEmails[email] = true
+		}
+	}
+}
+
+// WithLoginMaxFailures sets how many consecutive failed logins lock out an account and an ip,
+// which default to 5 and 20, since many users may share an ip
+func WithLoginMaxFailures(account, ip int) Option {
+	return func(h *handler) {
+		h.loginMaxFailures = account
+		h.loginMaxIPFailures = ip
+	}
+}
+
+// WithLoginLockout sets how long the first lockout lasts, which defaults to 1 minute,
+// and how long lockouts can get by doubling for each further failure, which defaults to 1 hour
+func WithLoginLockout(lockout, maxLockout time.Duration) Option {
+	return func(h *handler) {
+		h.loginLockout = lockout
+		h.loginMaxLockout = maxLockout
+	}
+}
+
+// WithClientIPHeader sets the header of client ip, e.g. X-Forwarded-For, which should be set only behind a proxy setting it.
+// For headers listing many ips, the last one is used, which is the one added by the proxy.
+func WithClientIPHeader(header string) Option {
+	return func(h *handler) {
+		h.clientIPHeader = header
+	}
+}
+
+// WithDeletedContentPolicy sets what happens to articles and comments of deleted users, which defaults to deleting them.
+// By ContentPolicyTransfer, they are transferred to the user with username transferTo.
+func WithDeletedContentPolicy(policy ContentPolicy, transferTo string) Option {
+	return func(h *handler) {
+		h.deletedContentPolicy = policy
+		h.deletedContentTransferTo = transferTo
+	}
+}
+
+// WithOIDC enables logging in with provider, linking its users to local ones, or creating them on first login
+func WithOIDC(provider *oidc.Provider) Option {
+	return func(h *handler) {
+		h.oidcProvider = provider
+	}
 }
 
-func NewHandler(userRepo models.UserRepository, articleRepo models.ArticleRepository, secret []byte) Handler {
+// WithAuthCookie sets session tokens in HttpOnly cookies on login and refresh, and accepts them instead of Authorization header.
+// Unsafe requests authenticated by cookie need the csrf token of the csrf_token cookie in X-CSRF-Token header.
+// Cookies are sent on https only if secure.
+func WithAuthCookie(secure bool) Option {
+	return func(h *handler) {
+		h.authCookie = true
+		h.authCookieSecure = secure
+	}
+}
+
+// WithErrorLog sets the logger of server errors with their error ids, which defaults to the standard logger
+func WithErrorLog(logger *log.Logger) Option {
+	return func(h *handler) {
+		h.errorLog = logger
+	}
+}
+
+// WithCORS sets the policy of cross-origin requests, which defaults to any origin with Authorization, Content-Type
+// and X-CSRF-Token headers, and without credentials
+func WithCORS(policy CORSPolicy) Option {
+	return func(h *handler) {
+		h.cors = policy
+	}
+}
+
+func NewHandler(
+	userRepo models.UserRepository,
+	articleRepo models.ArticleRepository,
+	sessionRepo models.SessionRepository,
+	personalAccessTokenRepo models.PersonalAccessTokenRepository,
+	oneTimeTokenRepo models.OneTimeTokenRepository,
+	twoFactorRepo models.TwoFactorRepository,
+	loginThrottleRepo models.LoginThrottleRepository,
+	identityRepo models.IdentityRepository,
+	keys *jwt.KeySet,
+	opts ...Option,
+) Handler {
 	h := handler{
-		userRepo:    userRepo,
-		articleRepo: articleRepo,
-		secret:      secret,
+		userRepo:                userRepo,
+		articleRepo:             articleRepo,
+		sessionRepo:             sessionRepo,
+		personalAccessTokenRepo: personalAccessTokenRepo,
+		oneTimeTokenRepo:        oneTimeTokenRepo,
+		twoFactorRepo:           twoFactorRepo,
+		loginThrottleRepo:       loginThrottleRepo,
+		identityRepo:            identityRepo,
+		keys:                    keys,
+		adminEmails:             map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	if h.hasher == nil {
+		h.hasher, _ = password.Bcrypt(password.BcryptDefaultCost)
+	}
+
+	if h.mailer == nil {
+		h.mailer = mail.Writer(os.Stdout, "noreply@localhost")
+	}
+
+	if h.lifetime <= 0 {
+		h.lifetime = 15 * time.Minute
+	}
+
+	if h.refreshLifetime <= 0 {
+		h.refreshLifetime = 30 * 24 * time.Hour
+	}
+
+	if h.passwordResetLifetime <= 0 {
+		h.passwordResetLifetime = time.Hour
+	}
+
+	if h.emailVerificationURL == "" {
+		h.emailVerificationURL = "http://localhost:8080/users/verify-email"
+	}
+
+	if h.totpIssuer == "" {
+		h.totpIssuer = "RealWorld"
+	}
+
+	if h.emailVerificationLifetime <= 0 {
+		h.emailVerificationLifetime = 24 * time.Hour
+	}
+
+	if h.loginMaxFailures <= 0 {
+		h.loginMaxFailures = 5
+	}
+
+	if h.loginMaxIPFailures <= 0 {
+		h.loginMaxIPFailures = 20
+	}
+
+	if h.loginLockout <= 0 {
+		h.loginLockout = time.Minute
+	}
+
+	if h.loginMaxLockout <= 0 {
+		h.loginMaxLockout = time.Hour
+	}
+
+	if h.loginMaxLockout < h.loginLockout {
+		h.loginMaxLockout = h.loginLockout
+	}
+
+	if h.errorLog == nil {
+		h.errorLog = log.Default()
+	}
+
+	if h.cors.AllowedOrigins == nil {
+		h.cors.AllowedOrigins = defaultCORSAllowedOrigins
+	}
+
+	if h.cors.AllowedHeaders == nil {
+		h.cors.AllowedHeaders = defaultCORSAllowedHeaders
+	}
+
+	if h.deletedContentPolicy == "" {
+		h.deletedContentPolicy = ContentPolicyDelete
 	}
 
 	h.registerRoutes()
@@ -37,29 +329,9 @@ func NewHandler(userRepo models.UserRepository, articleRepo models.ArticleReposi
 }
 
 func (h *handler) registerRoute(method, pattern string, handler http.HandlerFunc) {
-	h.routes = append(h.routes, route{
-		Method:      method,
-		Pattern:     *regexp.MustCompile(pattern),
-		HandlerFunc: handler,
-	})
+	h.router.handle(method, pattern, handler)
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	for _, route := range h.routes {
-		if r.Method == route.Method && route.Pattern.MatchString(r.URL.Path) {
-			names := route.Pattern.SubexpNames()
-			values := route.Pattern.FindAllStringSubmatch(r.URL.Path, -1)
-			if len(values) > 0 {
-				for i, v := range values[0] {
-					if names[i] != "" {
-						r = r.WithContext(context.WithValue(r.Context(), names[i], v))
-					}
-				}
-			}
-			route.HandlerFunc(w, r)
-			return
-		}
-	}
-
-	http.NotFoundHandler().ServeHTTP(w, r)
+	h.middlewareCORS(h.router.ServeHTTP)(w, r)
 }
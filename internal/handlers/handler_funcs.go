@@ -1,27 +1,64 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/nasermirzaei89/realworld-go/internal/models"
 	uniqueID "github.com/nasermirzaei89/realworld-go/pkg/id"
 	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/mail"
+	"github.com/nasermirzaei89/realworld-go/pkg/oidc"
+	"github.com/nasermirzaei89/realworld-go/pkg/password"
 	slugify "github.com/nasermirzaei89/realworld-go/pkg/slug"
+	"github.com/nasermirzaei89/realworld-go/pkg/totp"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func (h *handler) handleCORS() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		// answer preflight of allowed origins only, whose allowed origin is set by middlewareCORS
+		if r.Header.Get("Access-Control-Request-Method") != "" && w.Header().Get("Access-Control-Allow-Origin") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			methods := w.Header().Get("Allow")
+			if h.cors.AllowedMethods != nil {
+				methods = strings.Join(h.cors.AllowedMethods, ", ")
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+
+			if headers := h.cors.allowedHeaders(r); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+
+			if h.cors.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", h.cors.maxAge())
+			}
+		}
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+func (h *handler) handleNotFound() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.respondStatus(w, r, http.StatusNotFound, fmt.Sprintf("route '%s' not found", r.URL.Path), nil)
+	}
+}
+
+func (h *handler) handleMethodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.respondStatus(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed on route '%s'", r.Method, r.URL.Path), nil)
+	}
+}
+
 func (h *handler) handleAuthentication() http.HandlerFunc {
 	type Request struct {
 		User struct {
@@ -37,341 +74,1755 @@ func (h *handler) handleAuthentication() http.HandlerFunc {
 		var req Request
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
 			return
 		}
 
-		// find user by email
+		// check lockout of account and ip
+		ip := h.clientIP(r)
+
+		lockedUntil, err := h.loginLockedUntil(req.User.Email, ip)
+		if err != nil {
+			h.respondError(w, r, "check login lockout failed", err)
+			return
+		}
+
+		if time.Now().Before(lockedUntil) {
+			w.Header().Set("Retry-After", retryAfter(lockedUntil))
+			h.respondStatus(w, r, http.StatusTooManyRequests, "too many failed login attempts", nil)
+			return
+		}
+
+		// find user by email, and check password
+		// unknown emails and wrong passwords fail the same way and take as long, so they can not be told apart
+		ok := false
+
 		user, err := h.userRepo.GetByEmail(req.User.Email)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by email failed",
-					"error":   err.Error(),
-				},
-			})
+			if !errors.As(err, &models.UserByEmailNotFoundError{}) {
+				h.respondError(w, r, "get user by email failed", err)
+				return
+			}
+
+			h.verifyDummyPassword(req.User.Password)
+		} else {
+			ok, err = h.hasher.Verify(user.Password, req.User.Password)
+			if err != nil {
+				h.respondError(w, r, "verify password failed", err)
+				return
+			}
+		}
+
+		if !ok {
+			err = h.recordLoginFailure(req.User.Email, ip)
+			if err != nil {
+				h.respondError(w, r, "record login failure failed", err)
+				return
+			}
+
+			h.respondStatus(w, r, http.StatusUnauthorized, errInvalidCredentials.Error(), nil)
+			return
+		}
+
+		// rehash password of old algorithm or cost
+		// it is best effort, since the old hash keeps working if it fails
+		if h.hasher.NeedsRehash(user.Password) {
+			hash, err := h.hasher.Hash(req.User.Password)
+			if err == nil {
+				user.Password = hash
+				_ = h.userRepo.UpdateByID(user.ID, *user)
+			}
+		}
+
+		// promote configured admins who verified before being configured, which is best effort too
+		if role := h.configuredRole(*user); !user.Role.AtLeast(role) {
+			promoted := *user
+			promoted.Role = role
+			if h.userRepo.UpdateByID(user.ID, promoted) == nil {
+				user = &promoted
+			}
+		}
+
+		// ask for the second factor if enabled, instead of logging in
+		twoFactor, err := h.twoFactorRepo.GetByUserID(user.ID)
+		if err != nil && !errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+			h.respondError(w, r, "get two factor failed", err)
 			return
 		}
 
-		// check password
-		// TODO: should hash password
-		if req.User.Password != user.Password {
+		if err == nil && twoFactor.Enabled {
+			challenge, err := h.issueOneTimeToken(user.ID, models.TokenPurposeTwoFactorChallenge, twoFactorChallengeLifetime)
+			if err != nil {
+				h.respondError(w, r, "issue two factor challenge failed", err)
+				return
+			}
+
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "invalid password received",
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(TwoFactorChallengeResponse{
+				Challenge: TwoFactorChallenge{
+					Token:     challenge,
+					ExpiresAt: time.Now().Add(twoFactorChallengeLifetime).UTC().Format(dateLayout),
 				},
 			})
 			return
 		}
 
+		// forget failed logins of account, which is best effort too
+		_ = h.resetLoginFailures(user.Email)
+
+		// start session
+		session, refreshToken, err := h.startSession(r, user.ID)
+		if err != nil {
+			h.respondError(w, r, "start session failed", err)
+			return
+		}
+
+		// issue token
+		token, err := h.issueToken(user.ID, session.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// set session cookies, in cookie mode
+		err = h.setAuthCookies(w, session, token, refreshToken)
+		if err != nil {
+			h.respondError(w, r, "set session cookies failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:        user.Email,
+				Token:        token,
+				RefreshToken: refreshToken,
+				Username:     user.Username,
+				Bio:          user.Bio,
+				Image:        user.Image,
+				Role:         string(user.Role),
+				Verified:     user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleRegistration() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Email    string `json:"email"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"user"`
+	}
+
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate request
+		errs := validationErrors{}
+		errs.email("email", req.User.Email)
+		errs.username("username", req.User.Username)
+		errs.password("password", req.User.Password)
+
+		// find user by email
+		if len(errs["email"]) == 0 {
+			_, err = h.userRepo.GetByEmail(req.User.Email)
+			if err != nil && !errors.As(err, &models.UserByEmailNotFoundError{}) {
+				h.respondError(w, r, "get user by email failed", err)
+				return
+			}
+
+			if err == nil {
+				errs.add("email", "has already been taken")
+			}
+		}
+
+		// find user by username
+		if len(errs["username"]) == 0 {
+			_, err = h.userRepo.GetByUsername(req.User.Username)
+			if err != nil && !errors.As(err, &models.UserByUsernameNotFoundError{}) {
+				h.respondError(w, r, "get user by username failed", err)
+				return
+			}
+
+			// the username of the deleted user is taken even before it is added
+			if err == nil || req.User.Username == deletedUsername {
+				errs.add("username", "has already been taken")
+			}
+		}
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
+			return
+		}
+
+		// hash password
+		hash, err := h.hasher.Hash(req.User.Password)
+		if err != nil {
+			if errors.Is(err, password.ErrTooLong) {
+				h.respondValidationErrors(w, r, validationErrors{"password": {"is too long"}})
+				return
+			}
+
+			h.respondError(w, r, "hash password failed", err)
+			return
+		}
+
+		// generate user id
+		userID, err := h.userRepo.NewID()
+		if err != nil {
+			h.respondError(w, r, "error on generate user id", err)
+			return
+		}
+
+		// create user
+		user := models.User{
+			ID:        userID,
+			Email:     req.User.Email,
+			Username:  req.User.Username,
+			Password:  hash,
+			Bio:       "",
+			Image:     "",
+			Role:      models.RoleUser,
+			Verified:  false,
+			Followers: map[int]bool{},
+		}
+
+		err = h.userRepo.Add(user)
+		if err != nil {
+			h.respondError(w, r, "create user failed", err)
+			return
+		}
+
+		// send verification email, which is best effort since user can ask for another one
+		_ = h.sendVerificationEmail(user)
+
+		// start session
+		session, refreshToken, err := h.startSession(r, user.ID)
+		if err != nil {
+			h.respondError(w, r, "start session failed", err)
+			return
+		}
+
+		// issue token
+		token, err := h.issueToken(user.ID, session.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// set session cookies, in cookie mode
+		err = h.setAuthCookies(w, session, token, refreshToken)
+		if err != nil {
+			h.respondError(w, r, "set session cookies failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:        user.Email,
+				Token:        token,
+				RefreshToken: refreshToken,
+				Username:     user.Username,
+				Bio:          user.Bio,
+				Image:        user.Image,
+				Role:         string(user.Role),
+				Verified:     user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleGetCurrentUser() http.HandlerFunc {
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// issue token, unless authenticated by a personal access token
+		var token string
+		if currentSession, ok := r.Context().Value(currentSessionCtx).(*models.Session); ok {
+			var err error
+			token, err = h.issueToken(currentUser.ID, currentSession.ID)
+			if err != nil {
+				h.respondError(w, r, "error on sign jwt token", err)
+				return
+			}
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:    currentUser.Email,
+				Token:    token,
+				Username: currentUser.Username,
+				Bio:      currentUser.Bio,
+				Image:    currentUser.Image,
+				Role:     string(currentUser.Role),
+				Verified: currentUser.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleUpdateUser() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Email    *string `json:"email"`
+			Username *string `json:"username"`
+			Password *string `json:"password"`
+			Image    *string `json:"image"`
+			Bio      *string `json:"bio"`
+		} `json:"user"`
+	}
+
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+		currentSession := r.Context().Value(currentSessionCtx).(*models.Session)
+
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate request, for given fields
+		errs := validationErrors{}
+
+		if req.User.Email != nil {
+			errs.email("email", *req.User.Email)
+		}
+
+		if req.User.Username != nil {
+			errs.username("username", *req.User.Username)
+		}
+
+		if req.User.Password != nil {
+			errs.password("password", *req.User.Password)
+		}
+
+		if req.User.Image != nil {
+			errs.image("image", *req.User.Image)
+		}
+
+		if req.User.Bio != nil {
+			errs.text("bio", *req.User.Bio, false, maxBioLength)
+		}
+
+		emailChanged := false
+
+		if req.User.Email != nil && *req.User.Email != currentUser.Email && len(errs["email"]) == 0 {
+			// check email
+			exists, err := h.userRepo.GetByEmail(*req.User.Email)
+			if err != nil && !errors.As(err, &models.UserByEmailNotFoundError{}) {
+				h.respondError(w, r, "get user by email failed", err)
+				return
+			}
+
+			if err == nil && exists.ID != currentUser.ID {
+				errs.add("email", "has already been taken")
+			}
+
+			// new email is not verified yet
+			currentUser.Email = *req.User.Email
+			currentUser.Verified = false
+			emailChanged = true
+		}
+
+		if req.User.Username != nil && *req.User.Username != currentUser.Username && len(errs["username"]) == 0 {
+			// check username
+			exists, err := h.userRepo.GetByUsername(*req.User.Username)
+			if err != nil && !errors.As(err, &models.UserByUsernameNotFoundError{}) {
+				h.respondError(w, r, "get user by username failed", err)
+				return
+			}
+
+			// the username of the deleted user is taken even before it is added
+			if (err == nil && exists.ID != currentUser.ID) || *req.User.Username == deletedUsername {
+				errs.add("username", "has already been taken")
+			}
+
+			currentUser.Username = *req.User.Username
+		}
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
+			return
+		}
+
+		if req.User.Password != nil {
+			hash, err := h.hasher.Hash(*req.User.Password)
+			if err != nil {
+				if errors.Is(err, password.ErrTooLong) {
+					h.respondValidationErrors(w, r, validationErrors{"password": {"is too long"}})
+					return
+				}
+
+				h.respondError(w, r, "hash password failed", err)
+				return
+			}
+
+			currentUser.Password = hash
+		}
+
+		if req.User.Image != nil {
+			currentUser.Image = *req.User.Image
+		}
+
+		if req.User.Bio != nil {
+			currentUser.Bio = *req.User.Bio
+		}
+
+		// update user
+		err = h.userRepo.UpdateByID(currentUser.ID, *currentUser)
+		if err != nil {
+			h.respondError(w, r, "update user failed", err)
+			return
+		}
+
+		// send verification email of new email, which is best effort since user can ask for another one
+		if emailChanged {
+			_ = h.sendVerificationEmail(*currentUser)
+		}
+
+		// issue token
+		token, err := h.issueToken(currentUser.ID, currentSession.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:    currentUser.Email,
+				Token:    token,
+				Username: currentUser.Username,
+				Bio:      currentUser.Bio,
+				Image:    currentUser.Image,
+				Role:     string(currentUser.Role),
+				Verified: currentUser.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleDeleteUser() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		} `json:"user"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// check lockout, since confirmations are counted as logins so a stolen session can not guess the password
+		ip := h.clientIP(r)
+
+		lockedUntil, err := h.loginLockedUntil(currentUser.Email, ip)
+		if err != nil {
+			h.respondError(w, r, "check login lockout failed", err)
+			return
+		}
+
+		if time.Now().Before(lockedUntil) {
+			w.Header().Set("Retry-After", retryAfter(lockedUntil))
+			h.respondStatus(w, r, http.StatusTooManyRequests, "too many failed login attempts", nil)
+			return
+		}
+
+		// confirm password
+		ok, err := h.hasher.Verify(currentUser.Password, req.User.Password)
+		if err != nil {
+			h.respondError(w, r, "verify password failed", err)
+			return
+		}
+
+		if !ok {
+			_ = h.recordLoginFailure(currentUser.Email, ip)
+
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "password is wrong", nil)
+			return
+		}
+
+		// confirm second factor if enabled
+		twoFactor, err := h.twoFactorRepo.GetByUserID(currentUser.ID)
+		if err != nil && !errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		if err == nil && twoFactor.Enabled {
+			err = h.verifySecondFactor(*twoFactor, req.User.Code)
+			if err != nil {
+				if errors.Is(err, errInvalidSecondFactor) {
+					_ = h.recordLoginFailure(currentUser.Email, ip)
+
+					h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid two factor code", err)
+					return
+				}
+
+				h.respondError(w, r, "verify two factor code failed", err)
+				return
+			}
+		}
+
+		// delete user
+		err = h.deleteUser(*currentUser)
+		if err != nil {
+			h.respondError(w, r, "delete user failed", err)
+			return
+		}
+
+		// forget failed logins of account, which is best effort
+		_ = h.resetLoginFailures(currentUser.Email)
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleRefreshToken() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			RefreshToken string `json:"refreshToken"`
+		} `json:"user"`
+	}
+
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get request body, which can be empty in cookie mode
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && !(h.authCookie && errors.Is(err, io.EOF)) {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// get refresh token from body, or from its cookie in cookie mode
+		refreshTokenStr, fromCookie := req.User.RefreshToken, false
+		if refreshTokenStr == "" && h.authCookie {
+			if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+				refreshTokenStr, fromCookie = cookie.Value, true
+			}
+		}
+
+		// parse refresh token
+		sessionID, secret, err := parseRefreshToken(refreshTokenStr)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid refresh token", err)
+			return
+		}
+
+		// verify csrf token, since the cookie is sent on requests of other sites too
+		if fromCookie {
+			err = h.verifyCSRFToken(r, sessionID)
+			if err != nil {
+				h.respondStatus(w, r, http.StatusForbidden, "invalid csrf token", err)
+				return
+			}
+		}
+
+		// find session
+		session, err := h.sessionRepo.GetByID(sessionID)
+		if err != nil {
+			if errors.As(err, &models.SessionByIDNotFoundError{}) {
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid refresh token", err)
+				return
+			}
+
+			h.respondError(w, r, "get session failed", err)
+			return
+		}
+
+		now := time.Now()
+
+		if session.ExpiresAt.Before(now) {
+			_ = h.sessionRepo.DeleteByID(session.ID)
+
+			h.respondStatus(w, r, http.StatusUnauthorized, "refresh token is expired", nil)
+			return
+		}
+
+		// rotate refresh token
+		newSecret, err := randomString(32)
+		if err != nil {
+			h.respondError(w, r, "generate refresh token failed", err)
+			return
+		}
+
+		err = h.sessionRepo.RefreshByID(session.ID, hashSecret(secret), models.Session{
+			RefreshTokenHash: hashSecret(newSecret),
+			RefreshedAt:      now,
+			ExpiresAt:        now.Add(h.refreshLifetime),
+		})
+		if err != nil {
+			// a used refresh token means it is stolen, or the client is, so the whole session is ended
+			if errors.As(err, &models.RefreshTokenMismatchError{}) {
+				_ = h.sessionRepo.DeleteByID(session.ID)
+
+				h.respondStatus(w, r, http.StatusUnauthorized, "refresh token is already used", err)
+				return
+			}
+
+			if errors.As(err, &models.SessionByIDNotFoundError{}) {
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid refresh token", err)
+				return
+			}
+
+			h.respondError(w, r, "refresh session failed", err)
+			return
+		}
+
+		session.RefreshedAt = now
+		session.ExpiresAt = now.Add(h.refreshLifetime)
+
+		h.seeSession(r, session, now)
+
+		// find user
+		user, err := h.userRepo.GetByID(session.UserID)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "get user by id failed", err)
+			return
+		}
+
+		// issue token
+		token, err := h.issueToken(user.ID, session.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// set session cookies, in cookie mode
+		err = h.setAuthCookies(w, session, token, refreshToken(session.ID, newSecret))
+		if err != nil {
+			h.respondError(w, r, "set session cookies failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:        user.Email,
+				Token:        token,
+				RefreshToken: refreshToken(session.ID, newSecret),
+				Username:     user.Username,
+				Bio:          user.Bio,
+				Image:        user.Image,
+				Role:         string(user.Role),
+				Verified:     user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleLogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current token and session
+		currentToken := r.Context().Value(currentTokenCtx).(jwt.Token)
+		currentSession := r.Context().Value(currentSessionCtx).(*models.Session)
+
+		// revoke current token, which stays valid otherwise until it expires
+		jti, _ := currentToken.GetJWTID()
+		exp, _ := currentToken.GetExpirationTime()
+
+		err := h.sessionRepo.RevokeTokenID(jti, exp)
+		if err != nil {
+			h.respondError(w, r, "revoke token failed", err)
+			return
+		}
+
+		// end session, so its refresh token and other access tokens stop working
+		err = h.sessionRepo.DeleteByID(currentSession.ID)
+		if err != nil && !errors.As(err, &models.SessionByIDNotFoundError{}) {
+			h.respondError(w, r, "delete session failed", err)
+			return
+		}
+
+		// clear session cookies, in cookie mode
+		h.clearAuthCookies(w)
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleRequestPasswordReset() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// find user by email, responding the same if there is none, so emails of users are not revealed
+		user, err := h.userRepo.GetByEmail(req.User.Email)
+		if err != nil {
+			if errors.As(err, &models.UserByEmailNotFoundError{}) {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			h.respondError(w, r, "get user by email failed", err)
+			return
+		}
+
+		// issue reset token
+		token, err := h.issueOneTimeToken(user.ID, models.TokenPurposePasswordReset, h.passwordResetLifetime)
+		if err != nil {
+			h.respondError(w, r, "issue password reset token failed", err)
+			return
+		}
+
+		// send reset token
+		err = h.mailer.Send(mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nUse this token to reset your password in %s:\n\n%s\n\nIf you did not ask for it, you can ignore this email.\n",
+				user.Username, h.passwordResetLifetime, token,
+			),
+		})
+		if err != nil {
+			// it is logged only, since responding it for existing emails would reveal them
+			h.errorLog.Printf("error on %s %s: send password reset email failed: %v", r.Method, r.URL.Path, err)
+		}
+
+		// success response
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (h *handler) handleConfirmPasswordReset() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		} `json:"user"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate request
+		errs := validationErrors{}
+		errs.required("token", req.User.Token)
+		errs.password("password", req.User.Password)
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
+			return
+		}
+
+		// hash password before using up the token, so a rejected password does not waste it
+		hash, err := h.hasher.Hash(req.User.Password)
+		if err != nil {
+			if errors.Is(err, password.ErrTooLong) {
+				h.respondValidationErrors(w, r, validationErrors{"password": {"is too long"}})
+				return
+			}
+
+			h.respondError(w, r, "hash password failed", err)
+			return
+		}
+
+		// use reset token
+		token, err := h.useOneTimeToken(req.User.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			if errors.Is(err, errInvalidOneTimeToken) {
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid password reset token", err)
+				return
+			}
+
+			h.respondError(w, r, "use password reset token failed", err)
+			return
+		}
+
+		// find user
+		user, err := h.userRepo.GetByID(token.UserID)
+		if err != nil {
+			h.respondError(w, r, "get user by id failed", err)
+			return
+		}
+
+		// update password
+		user.Password = hash
+
+		err = h.userRepo.UpdateByID(user.ID, *user)
+		if err != nil {
+			h.respondError(w, r, "update user failed", err)
+			return
+		}
+
+		// end sessions, which may have been started by someone who knew the old password
+		err = h.sessionRepo.DeleteByUserID(user.ID)
+		if err != nil {
+			h.respondError(w, r, "delete sessions failed", err)
+			return
+		}
+
+		// other reset tokens are of no use anymore, deleting them is best effort
+		_ = h.oneTimeTokenRepo.DeleteByUserID(user.ID, models.TokenPurposePasswordReset)
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleVerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// verify token of the link
+		token, err := h.keys.ParseAndVerify(
+			r.URL.Query().Get("token"),
+			jwt.WithAudience(audienceEmailVerification),
+			jwt.WithRequiredClaims(jwt.ClaimExpirationTime, jwt.ClaimSubject, claimEmail),
+		)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid email verification token", err)
+			return
+		}
+
+		var claims emailVerificationClaims
+		err = token.Claims(&claims)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid email verification token", err)
+			return
+		}
+
+		// find user
+		userID, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid email verification token", err)
+			return
+		}
+
+		user, err := h.userRepo.GetByID(userID)
+		if err != nil {
+			if errors.As(err, &models.UserByIDNotFoundError{}) {
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid email verification token", err)
+				return
+			}
+
+			h.respondError(w, r, "get user by id failed", err)
+			return
+		}
+
+		// tokens of a previous email do not verify the current one
+		if user.Email != claims.Email {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid email verification token: email is changed since the token is issued", nil)
+			return
+		}
+
+		// tokens are stateless, so a used one is told by the email being verified already
+		if user.Verified {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "email is already verified", nil)
+			return
+		}
+
+		// verify user, promoting configured admins
+		user.Verified = true
+		if role := h.configuredRole(*user); !user.Role.AtLeast(role) {
+			user.Role = role
+		}
+
+		err = h.userRepo.UpdateByID(user.ID, *user)
+		if err != nil {
+			h.respondError(w, r, "update user failed", err)
+			return
+		}
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleSendVerificationEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		if currentUser.Verified {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "email is already verified", nil)
+			return
+		}
+
+		// send verification email
+		err := h.sendVerificationEmail(*currentUser)
+		if err != nil {
+			h.respondError(w, r, "send verification email failed", err)
+			return
+		}
+
+		// success response
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (h *handler) handleTwoFactorAuthentication() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Challenge string `json:"challenge"`
+			Code      string `json:"code"`
+		} `json:"user"`
+	}
+
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// use challenge, so each one allows one try and guessing codes needs the password again
+		challenge, err := h.useOneTimeToken(req.User.Challenge, models.TokenPurposeTwoFactorChallenge)
+		if err != nil {
+			if errors.Is(err, errInvalidOneTimeToken) {
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid two factor challenge", err)
+				return
+			}
+
+			h.respondError(w, r, "use two factor challenge failed", err)
+			return
+		}
+
+		// find user and its two factor
+		user, err := h.userRepo.GetByID(challenge.UserID)
+		if err != nil {
+			h.respondError(w, r, "get user by id failed", err)
+			return
+		}
+
+		// check lockout of account and ip, since codes are guessed through challenges
+		ip := h.clientIP(r)
+
+		lockedUntil, err := h.loginLockedUntil(user.Email, ip)
+		if err != nil {
+			h.respondError(w, r, "check login lockout failed", err)
+			return
+		}
+
+		if time.Now().Before(lockedUntil) {
+			w.Header().Set("Retry-After", retryAfter(lockedUntil))
+			h.respondStatus(w, r, http.StatusTooManyRequests, "too many failed login attempts", nil)
+			return
+		}
+
+		twoFactor, err := h.twoFactorRepo.GetByUserID(user.ID)
+		if err != nil {
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		// check code
+		err = h.verifySecondFactor(*twoFactor, req.User.Code)
+		if err != nil {
+			if errors.Is(err, errInvalidSecondFactor) {
+				recordErr := h.recordLoginFailure(user.Email, ip)
+				if recordErr != nil {
+					h.respondError(w, r, "record login failure failed", recordErr)
+					return
+				}
+
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid two factor code", err)
+				return
+			}
+
+			h.respondError(w, r, "verify two factor code failed", err)
+			return
+		}
+
+		// forget failed logins of account, which is best effort
+		_ = h.resetLoginFailures(user.Email)
+
+		// start session
+		session, refreshToken, err := h.startSession(r, user.ID)
+		if err != nil {
+			h.respondError(w, r, "start session failed", err)
+			return
+		}
+
+		// issue token
+		token, err := h.issueToken(user.ID, session.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// set session cookies, in cookie mode
+		err = h.setAuthCookies(w, session, token, refreshToken)
+		if err != nil {
+			h.respondError(w, r, "set session cookies failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:        user.Email,
+				Token:        token,
+				RefreshToken: refreshToken,
+				Username:     user.Username,
+				Bio:          user.Bio,
+				Image:        user.Image,
+				Role:         string(user.Role),
+				Verified:     user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleOIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// generate state, nonce and code verifier
+		login, err := newOIDCLogin()
+		if err != nil {
+			h.respondError(w, r, "generate oidc login failed", err)
+			return
+		}
+
+		// keep them in a cookie until the callback
+		cookie, err := h.oidcLoginCookie(login)
+		if err != nil {
+			h.respondError(w, r, "error on sign oidc login cookie", err)
+			return
+		}
+
+		http.SetCookie(w, cookie)
+
+		// success response
+		http.Redirect(w, r, h.oidcProvider.AuthCodeURL(login.State, login.Nonce, login.Verifier), http.StatusFound)
+	}
+}
+
+func (h *handler) handleOIDCCallback() http.HandlerFunc {
+	type Response UserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// check login cookie, which is used up either way
+		login, err := h.parseOIDCLoginCookie(r)
+
+		if cookie, err := h.oidcLoginCookie(nil); err == nil {
+			http.SetCookie(w, cookie)
+		}
+
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid oidc login", err)
+			return
+		}
+
+		query := r.URL.Query()
+
+		if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+			h.respondStatus(w, r, http.StatusUnauthorized, "invalid oidc login: state does not match", nil)
+			return
+		}
+
+		// the provider redirects with an error if user denies login, or it fails there
+		if query.Get("error") != "" {
+			h.respondStatus(w, r, http.StatusUnauthorized, "oidc login failed: "+strings.TrimSpace(query.Get("error")+": "+query.Get("error_description")), nil)
+			return
+		}
+
+		// redeem code for a verified id token
+		claims, err := h.oidcProvider.Exchange(r.Context(), query.Get("code"), login.Verifier, login.Nonce)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, oidc.ErrInvalidIDToken) || errors.Is(err, oidc.ErrInvalidGrant) {
+				status = http.StatusUnauthorized
+			}
+
+			h.respondStatus(w, r, status, "oidc login failed", err)
+			return
+		}
+
+		// find user linked to identity
+		var user *models.User
+
+		identity, err := h.identityRepo.GetByIssuerAndSubject(claims.Issuer, claims.Subject)
+		if err != nil && !errors.As(err, &models.IdentityByIssuerAndSubjectNotFoundError{}) {
+			h.respondError(w, r, "get identity failed", err)
+			return
+		}
+
+		if err == nil {
+			user, err = h.userRepo.GetByID(identity.UserID)
+			if err != nil {
+				h.respondError(w, r, "get user by id failed", err)
+				return
+			}
+		}
+
+		// link identity on first login, to the user with its email or a new one
+		if user == nil {
+			if !validEmail(claims.Email) {
+				h.respondStatus(w, r, http.StatusUnprocessableEntity, "email of identity is missing or invalid", nil)
+				return
+			}
+
+			user, err = h.userRepo.GetByEmail(claims.Email)
+			if err != nil && !errors.As(err, &models.UserByEmailNotFoundError{}) {
+				h.respondError(w, r, "get user by email failed", err)
+				return
+			}
+
+			// an existing account is linked only if both sides proved owning the email,
+			// otherwise whoever registers an email first at either side could take over the other account
+			if err == nil && !(claims.EmailVerified && user.Verified) {
+				h.respondStatus(w, r, http.StatusConflict, "email is already registered", nil)
+				return
+			}
+
+			if err != nil {
+				// generate username
+				username, err := h.oidcUsername(*claims)
+				if err != nil {
+					h.respondError(w, r, "generate username failed", err)
+					return
+				}
+
+				// hash a random password, since users of the provider have none here until they reset it
+				secret, err := randomString(32)
+				if err != nil {
+					h.respondError(w, r, "generate password failed", err)
+					return
+				}
+
+				hash, err := h.hasher.Hash(secret)
+				if err != nil {
+					h.respondError(w, r, "hash password failed", err)
+					return
+				}
+
+				// generate user id
+				userID, err := h.userRepo.NewID()
+				if err != nil {
+					h.respondError(w, r, "error on generate user id", err)
+					return
+				}
+
+				// create user, verified if the provider verified the email
+				user = &models.User{
+					ID:        userID,
+					Email:     claims.Email,
+					Username:  username,
+					Password:  hash,
+					Bio:       "",
+					Image:     "",
+					Role:      models.RoleUser,
+					Verified:  claims.EmailVerified,
+					Followers: map[int]bool{},
+				}
+				user.Role = h.configuredRole(*user)
+
+				err = h.userRepo.Add(*user)
+				if err != nil {
+					h.respondError(w, r, "create user failed", err)
+					return
+				}
+
+				// send verification email, which is best effort since user can ask for another one
+				if !user.Verified {
+					_ = h.sendVerificationEmail(*user)
+				}
+			}
+
+			err = h.identityRepo.Add(models.Identity{
+				Issuer:    claims.Issuer,
+				Subject:   claims.Subject,
+				UserID:    user.ID,
+				Email:     claims.Email,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				h.respondError(w, r, "add identity failed", err)
+				return
+			}
+		}
+
+		// promote configured admins who verified before being configured, which is best effort
+		if role := h.configuredRole(*user); !user.Role.AtLeast(role) {
+			promoted := *user
+			promoted.Role = role
+			if h.userRepo.UpdateByID(user.ID, promoted) == nil {
+				user = &promoted
+			}
+		}
+
+		// ask for the second factor if enabled, since the provider may not check one
+		twoFactor, err := h.twoFactorRepo.GetByUserID(user.ID)
+		if err != nil && !errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		if err == nil && twoFactor.Enabled {
+			challenge, err := h.issueOneTimeToken(user.ID, models.TokenPurposeTwoFactorChallenge, twoFactorChallengeLifetime)
+			if err != nil {
+				h.respondError(w, r, "issue two factor challenge failed", err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(TwoFactorChallengeResponse{
+				Challenge: TwoFactorChallenge{
+					Token:     challenge,
+					ExpiresAt: time.Now().Add(twoFactorChallengeLifetime).UTC().Format(dateLayout),
+				},
+			})
+			return
+		}
+
+		// start session
+		session, refreshToken, err := h.startSession(r, user.ID)
+		if err != nil {
+			h.respondError(w, r, "start session failed", err)
+			return
+		}
+
+		// issue token
+		token, err := h.issueToken(user.ID, session.ID)
+		if err != nil {
+			h.respondError(w, r, "error on sign jwt token", err)
+			return
+		}
+
+		// set session cookies, in cookie mode
+		err = h.setAuthCookies(w, session, token, refreshToken)
+		if err != nil {
+			h.respondError(w, r, "set session cookies failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: User{
+				Email:        user.Email,
+				Token:        token,
+				RefreshToken: refreshToken,
+				Username:     user.Username,
+				Bio:          user.Bio,
+				Image:        user.Image,
+				Role:         string(user.Role),
+				Verified:     user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleEnrollTOTP() http.HandlerFunc {
+	type Response TOTPEnrollmentResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// check two factor is not enabled, replacing a pending enrollment otherwise
+		twoFactor, err := h.twoFactorRepo.GetByUserID(currentUser.ID)
+		if err != nil && !errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		if err == nil && twoFactor.Enabled {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "two factor authentication is already enabled", nil)
+			return
+		}
+
+		// generate secret
+		secret, err := totp.NewSecret()
+		if err != nil {
+			h.respondError(w, r, "generate totp secret failed", err)
+			return
+		}
+
+		err = h.twoFactorRepo.Put(models.TwoFactor{
+			UserID:  currentUser.ID,
+			Secret:  secret,
+			Enabled: false,
+		})
+		if err != nil {
+			h.respondError(w, r, "put two factor failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			TOTP: TOTPEnrollment{
+				Secret: secret,
+				URI:    totp.URI(secret, h.totpIssuer, currentUser.Email),
+			},
+		})
+	}
+}
+
+func (h *handler) handleConfirmTOTP() http.HandlerFunc {
+	type Request struct {
+		TOTP struct {
+			Code string `json:"code"`
+		} `json:"totp"`
+	}
+
+	type Response RecoveryCodesResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// find pending enrollment
+		twoFactor, err := h.twoFactorRepo.GetByUserID(currentUser.ID)
+		if err != nil {
+			if errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+				h.respondStatus(w, r, http.StatusUnprocessableEntity, "two factor authentication is not enrolled", nil)
+				return
+			}
+
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		if twoFactor.Enabled {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "two factor authentication is already enabled", nil)
+			return
+		}
+
+		// check code, proving the authenticator app has the secret
+		step, ok, err := totp.Verify(twoFactor.Secret, req.TOTP.Code, time.Now())
+		if err != nil {
+			h.respondError(w, r, "verify totp code failed", err)
+			return
+		}
+
+		if !ok {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid two factor code", nil)
+			return
+		}
+
+		// enable with recovery codes
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			h.respondError(w, r, "generate recovery codes failed", err)
+			return
+		}
+
+		twoFactor.Enabled = true
+		twoFactor.LastUsedStep = step
+		twoFactor.RecoveryCodeHashes = hashes
+
+		err = h.twoFactorRepo.Put(*twoFactor)
+		if err != nil {
+			h.respondError(w, r, "put two factor failed", err)
+			return
+		}
+
+		// success response, which is the only time recovery codes are shown
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			RecoveryCodes: codes,
+		})
+	}
+}
+
+func (h *handler) handleDisableTOTP() http.HandlerFunc {
+	type Request struct {
+		TOTP struct {
+			Code string `json:"code"`
+		} `json:"totp"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// find two factor
+		twoFactor, err := h.twoFactorRepo.GetByUserID(currentUser.ID)
+		if err != nil {
+			if errors.As(err, &models.TwoFactorByUserIDNotFoundError{}) {
+				h.respondStatus(w, r, http.StatusUnprocessableEntity, "two factor authentication is not enabled", nil)
+				return
+			}
+
+			h.respondError(w, r, "get two factor failed", err)
+			return
+		}
+
+		// check code if enabled, so a stolen session can not turn it off
+		if twoFactor.Enabled {
+			// check lockout, since codes are counted as logins so a stolen session can not guess them
+			ip := h.clientIP(r)
+
+			lockedUntil, err := h.loginLockedUntil(currentUser.Email, ip)
+			if err != nil {
+				h.respondError(w, r, "check login lockout failed", err)
+				return
+			}
+
+			if time.Now().Before(lockedUntil) {
+				w.Header().Set("Retry-After", retryAfter(lockedUntil))
+				h.respondStatus(w, r, http.StatusTooManyRequests, "too many failed login attempts", nil)
+				return
+			}
+
+			err = h.verifySecondFactor(*twoFactor, req.TOTP.Code)
+			if err != nil {
+				if errors.Is(err, errInvalidSecondFactor) {
+					_ = h.recordLoginFailure(currentUser.Email, ip)
+
+					h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid two factor code", err)
+					return
+				}
+
+				h.respondError(w, r, "verify two factor code failed", err)
+				return
+			}
+		}
+
+		// disable
+		err = h.twoFactorRepo.DeleteByUserID(currentUser.ID)
+		if err != nil {
+			h.respondError(w, r, "delete two factor failed", err)
+			return
+		}
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleListPersonalAccessTokens() http.HandlerFunc {
+	type Response MultiplePersonalAccessTokensResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// list tokens
+		tokens, err := h.personalAccessTokenRepo.ListByUserID(currentUser.ID)
+		if err != nil {
+			h.respondError(w, r, "list personal access tokens failed", err)
+			return
+		}
+
+		res := make([]PersonalAccessToken, len(tokens))
+		for i := range tokens {
+			res[i] = personalAccessTokenResponse(tokens[i])
+		}
+
 		// success response
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(Response{
-			User: User{
-				Email:    user.Email,
-				Token:    user.Token,
-				Username: user.Username,
-				Bio:      user.Bio,
-				Image:    user.Image,
-			},
+			PersonalAccessTokens: res,
 		})
 	}
 }
 
-func (h *handler) handleRegistration() http.HandlerFunc {
+func (h *handler) handleCreatePersonalAccessToken() http.HandlerFunc {
 	type Request struct {
-		User struct {
-			Email    string `json:"email"`
-			Username string `json:"username"`
-			Password string `json:"password"`
-		} `json:"user"`
+		PersonalAccessToken struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		} `json:"personalAccessToken"`
 	}
 
-	type Response UserResponse
+	type Response SinglePersonalAccessTokenResponse
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
 		// get request body
 		var req Request
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
 			return
 		}
 
-		// find user by email
-		_, err = h.userRepo.GetByEmail(req.User.Email)
-		if err != nil && !errors.As(err, &models.UserByEmailNotFoundError{}) {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by email failed",
-					"error":   err.Error(),
-				},
-			})
-			return
+		// validate request
+		errs := validationErrors{}
+
+		name := strings.TrimSpace(req.PersonalAccessToken.Name)
+		if errs.required("name", name) {
+			tokens, err := h.personalAccessTokenRepo.ListByUserID(currentUser.ID)
+			if err != nil {
+				h.respondError(w, r, "list personal access tokens failed", err)
+				return
+			}
+
+			for i := range tokens {
+				if tokens[i].Name == name {
+					errs.add("name", "has already been taken")
+				}
+			}
 		}
 
-		if err == nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "email already taken",
-				},
-			})
-			return
+		if len(req.PersonalAccessToken.Scopes) == 0 {
+			errs.add("scopes", "can't be blank")
 		}
 
-		// find user by username
-		_, err = h.userRepo.GetByUsername(req.User.Username)
-		if err != nil && !errors.As(err, &models.UserByUsernameNotFoundError{}) {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by username failed",
-					"error":   err.Error(),
-				},
-			})
+		tokenScopes := make([]string, 0, len(req.PersonalAccessToken.Scopes))
+		for _, scope := range req.PersonalAccessToken.Scopes {
+			if !hasScope(scopes, scope) {
+				errs.add("scopes", fmt.Sprintf("has unknown scope '%s'", scope))
+
+				continue
+			}
+
+			if !hasScope(tokenScopes, scope) {
+				tokenScopes = append(tokenScopes, scope)
+			}
+		}
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
 			return
 		}
 
-		if err == nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "username already taken",
-				},
-			})
+		// generate token
+		id, err := randomString(16)
+		if err != nil {
+			h.respondError(w, r, "generate personal access token failed", err)
 			return
 		}
 
-		// generate token with no expiration
-		userID := h.userRepo.NewID()
-		token := jwt.New()
-		token.SetSubject(strconv.Itoa(userID))
-		tokenStr, err := jwt.Sign(token, h.secret)
+		secret, err := randomString(32)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on sign jwt token",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "generate personal access token failed", err)
 			return
 		}
 
-		// create user
-		user := models.User{
-			ID:        userID,
-			Email:     req.User.Email, // TODO: validate email
-			Token:     tokenStr,
-			Username:  req.User.Username,
-			Password:  req.User.Password, // TODO: should hash password
-			Bio:       "",
-			Image:     "",
-			Followers: map[int]bool{},
+		// create token
+		token := models.PersonalAccessToken{
+			ID:        id,
+			UserID:    currentUser.ID,
+			Name:      name,
+			Scopes:    tokenScopes,
+			TokenHash: hashSecret(secret),
+			CreatedAt: time.Now(),
 		}
 
-		err = h.userRepo.Add(user)
+		err = h.personalAccessTokenRepo.Add(token)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "create user failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "create personal access token failed", err)
 			return
 		}
 
-		// success response
+		// success response, which is the only time the token is shown
+		res := personalAccessTokenResponse(token)
+		res.Token = personalAccessToken(id, secret)
+
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
+		w.WriteHeader(http.StatusCreated)
 		_ = json.NewEncoder(w).Encode(Response{
-			User: User{
-				Email:    user.Email,
-				Token:    user.Token,
-				Username: user.Username,
-				Bio:      user.Bio,
-				Image:    user.Image,
-			},
+			PersonalAccessToken: res,
 		})
 	}
 }
 
-func (h *handler) handleGetCurrentUser() http.HandlerFunc {
-	type Response UserResponse
-
+func (h *handler) handleDeletePersonalAccessToken() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// get current user
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
+		// get token id
+		id := PathParam(r, "id")
+
+		// find token, which should be of current user
+		token, err := h.personalAccessTokenRepo.GetByID(id)
+		if err == nil && token.UserID != currentUser.ID {
+			err = models.PersonalAccessTokenByIDNotFoundError{ID: id}
+		}
+
+		if err != nil {
+			h.respondError(w, r, "get personal access token failed", err)
+			return
+		}
+
+		// delete token
+		err = h.personalAccessTokenRepo.DeleteByID(token.ID)
+		if err != nil {
+			h.respondError(w, r, "delete personal access token failed", err)
+			return
+		}
+
 		// success response
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(Response{
-			User: User{
-				Email:    currentUser.Email,
-				Token:    currentUser.Token,
-				Username: currentUser.Username,
-				Bio:      currentUser.Bio,
-				Image:    currentUser.Image,
-			},
-		})
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func (h *handler) handleUpdateUser() http.HandlerFunc {
-	type Request struct {
-		User struct {
-			Email    *string `json:"email"`
-			Username *string `json:"username"`
-			Password *string `json:"password"`
-			Image    *string `json:"image"`
-			Bio      *string `json:"bio"`
-		} `json:"user"`
+func personalAccessTokenResponse(token models.PersonalAccessToken) PersonalAccessToken {
+	res := PersonalAccessToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt.UTC().Format(dateLayout),
 	}
 
-	type Response UserResponse
+	if !token.LastUsedAt.IsZero() {
+		lastUsedAt := token.LastUsedAt.UTC().Format(dateLayout)
+		res.LastUsedAt = &lastUsedAt
+	}
+
+	return res
+}
+
+func (h *handler) handleListSessions() http.HandlerFunc {
+	type Response MultipleSessionsResponse
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// get current user
+		// get current user and session
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+		currentSession := r.Context().Value(currentSessionCtx).(*models.Session)
 
-		// get request body
-		var req Request
-		err := json.NewDecoder(r.Body).Decode(&req)
+		// list sessions
+		sessions, err := h.sessionRepo.ListByUserID(currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondError(w, r, "list sessions failed", err)
 			return
 		}
 
-		if req.User.Email != nil {
-			// check email
-			exists, err := h.userRepo.GetByEmail(*req.User.Email)
-			if err != nil && !errors.As(err, &models.UserByEmailNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "get user by email failed",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
+		// expired sessions are left for cleanup, but are not shown
+		now := time.Now()
 
-			if err == nil && exists.ID != currentUser.ID {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "email already taken",
-					},
-				})
-				return
+		res := make([]Session, 0, len(sessions))
+		for i := range sessions {
+			if sessions[i].ExpiresAt.Before(now) {
+				continue
 			}
 
-			// TODO: validate email
-			currentUser.Email = *req.User.Email
+			res = append(res, sessionResponse(sessions[i], sessions[i].ID == currentSession.ID))
 		}
 
-		if req.User.Username != nil {
-			// check username
-			exists, err := h.userRepo.GetByUsername(*req.User.Username)
-			if err != nil && !errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "get user by username failed",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			if err == nil && exists.ID != currentUser.ID {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "username already taken",
-					},
-				})
-				return
-			}
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			Sessions: res,
+		})
+	}
+}
 
-			currentUser.Username = *req.User.Username
-		}
+func (h *handler) handleDeleteSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
-		if req.User.Password != nil {
-			currentUser.Password = *req.User.Password
-		}
+		// get session id
+		id := PathParam(r, "id")
 
-		if req.User.Image != nil {
-			currentUser.Image = *req.User.Image
+		// find session, which should be of current user
+		session, err := h.sessionRepo.GetByID(id)
+		if err == nil && session.UserID != currentUser.ID {
+			err = models.SessionByIDNotFoundError{ID: id}
 		}
 
-		if req.User.Bio != nil {
-			currentUser.Bio = *req.User.Bio
+		if err != nil {
+			h.respondError(w, r, "get session failed", err)
+			return
 		}
 
-		// update user
-		err = h.userRepo.UpdateByID(currentUser.ID, *currentUser)
+		// delete session, which kills its refresh token and access tokens too
+		err = h.sessionRepo.DeleteByID(session.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "update user failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "delete session failed", err)
 			return
 		}
 
 		// success response
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(Response{
-			User: User{
-				Email:    currentUser.Email,
-				Token:    currentUser.Token,
-				Username: currentUser.Username,
-				Bio:      currentUser.Bio,
-				Image:    currentUser.Image,
-			},
-		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func sessionResponse(session models.Session, current bool) Session {
+	return Session{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt.UTC().Format(dateLayout),
+		LastSeenAt: session.LastSeenAt.UTC().Format(dateLayout),
+		ExpiresAt:  session.ExpiresAt.UTC().Format(dateLayout),
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		Current:    current,
 	}
 }
 
@@ -386,28 +1837,9 @@ func (h *handler) handleGetProfile() http.HandlerFunc {
 		}
 
 		// get user by username
-		user, err := h.userRepo.GetByUsername(r.Context().Value("username").(string))
-		if err != nil {
-			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "user not found",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by username failed",
-					"error":   err.Error(),
-				},
-			})
+		user, err := h.userRepo.GetByUsername(PathParam(r, "username"))
+		if err != nil {
+			h.respondError(w, r, "get user by username failed", err)
 			return
 		}
 
@@ -440,44 +1872,16 @@ func (h *handler) handleFollowUser() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get user by username
-		user, err := h.userRepo.GetByUsername(r.Context().Value("username").(string))
-		if err != nil {
-			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "user not found",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by username failed",
-					"error":   err.Error(),
-				},
-			})
+		user, err := h.userRepo.GetByUsername(PathParam(r, "username"))
+		if err != nil {
+			h.respondError(w, r, "get user by username failed", err)
 			return
 		}
 
-		user.Followers[currentUser.ID] = true
-
-		// update user
-		err = h.userRepo.UpdateByID(currentUser.ID, *currentUser)
+		// follow user
+		err = h.userRepo.AddFollowerByID(user.ID, currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "update user failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "follow user failed", err)
 			return
 		}
 
@@ -503,44 +1907,16 @@ func (h *handler) handleUnfollowUser() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get user by username
-		user, err := h.userRepo.GetByUsername(r.Context().Value("username").(string))
-		if err != nil {
-			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "user not found",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get user by username failed",
-					"error":   err.Error(),
-				},
-			})
+		user, err := h.userRepo.GetByUsername(PathParam(r, "username"))
+		if err != nil {
+			h.respondError(w, r, "get user by username failed", err)
 			return
 		}
 
-		delete(user.Followers, currentUser.ID)
-
-		// update user
-		err = h.userRepo.UpdateByID(currentUser.ID, *currentUser)
+		// unfollow user
+		err = h.userRepo.RemoveFollowerByID(user.ID, currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "update user failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "unfollow user failed", err)
 			return
 		}
 
@@ -587,14 +1963,7 @@ func (h *handler) handleListArticles() http.HandlerFunc {
 						if errors.As(err, &models.UserByUsernameNotFoundError{}) {
 							user = &models.User{Username: v}
 						} else {
-							w.Header().Set("Content-Type", "application/json; charset=utf-8")
-							w.WriteHeader(http.StatusInternalServerError)
-							_ = json.NewEncoder(w).Encode(ErrorResponse{
-								Errors: map[string]interface{}{
-									"message": "get user by username failed",
-									"error":   err.Error(),
-								},
-							})
+							h.respondError(w, r, "get user by username failed", err)
 							return
 						}
 					}
@@ -605,14 +1974,7 @@ func (h *handler) handleListArticles() http.HandlerFunc {
 						if errors.As(err, &models.UserByUsernameNotFoundError{}) {
 							user = &models.User{Username: v}
 						} else {
-							w.Header().Set("Content-Type", "application/json; charset=utf-8")
-							w.WriteHeader(http.StatusInternalServerError)
-							_ = json.NewEncoder(w).Encode(ErrorResponse{
-								Errors: map[string]interface{}{
-									"message": "get user by username failed",
-									"error":   err.Error(),
-								},
-							})
+							h.respondError(w, r, "get user by username failed", err)
 							return
 						}
 					}
@@ -620,29 +1982,15 @@ func (h *handler) handleListArticles() http.HandlerFunc {
 				case "offset":
 					var err error
 					offset, err = strconv.Atoi(v)
-					if err != nil {
-						w.Header().Set("Content-Type", "application/json; charset=utf-8")
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						_ = json.NewEncoder(w).Encode(ErrorResponse{
-							Errors: map[string]interface{}{
-								"message": "invalid offset received",
-								"error":   err.Error(),
-							},
-						})
+					if err != nil || offset < 0 {
+						h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid offset received", err)
 						return
 					}
 				case "limit":
 					var err error
 					limit, err = strconv.Atoi(v)
-					if err != nil {
-						w.Header().Set("Content-Type", "application/json; charset=utf-8")
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						_ = json.NewEncoder(w).Encode(ErrorResponse{
-							Errors: map[string]interface{}{
-								"message": "invalid limit received",
-								"error":   err.Error(),
-							},
-						})
+					if err != nil || limit < 0 {
+						h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid limit received", err)
 						return
 					}
 				default:
@@ -653,14 +2001,7 @@ func (h *handler) handleListArticles() http.HandlerFunc {
 
 		res, total, err := h.articleRepo.List(offset, limit, filters...)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "list article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "list article failed", err)
 			return
 		}
 
@@ -676,26 +2017,7 @@ func (h *handler) handleListArticles() http.HandlerFunc {
 
 			user, err := h.userRepo.GetByID(res[i].AuthorID)
 			if err != nil {
-				if errors.As(err, &models.UserByIDNotFoundError{}) {
-					w.Header().Set("Content-Type", "application/json; charset=utf-8")
-					w.WriteHeader(http.StatusNotFound)
-					_ = json.NewEncoder(w).Encode(ErrorResponse{
-						Errors: map[string]interface{}{
-							"message": "user not found",
-							"error":   err.Error(),
-						},
-					})
-					return
-				}
-
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "get user by id failed",
-						"error":   err.Error(),
-					},
-				})
+				h.respondError(w, r, "get user by id failed", err)
 				return
 			}
 
@@ -756,29 +2078,15 @@ func (h *handler) handleFeedArticles() http.HandlerFunc {
 				case "offset":
 					var err error
 					offset, err = strconv.Atoi(v)
-					if err != nil {
-						w.Header().Set("Content-Type", "application/json; charset=utf-8")
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						_ = json.NewEncoder(w).Encode(ErrorResponse{
-							Errors: map[string]interface{}{
-								"message": "invalid offset received",
-								"error":   err.Error(),
-							},
-						})
+					if err != nil || offset < 0 {
+						h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid offset received", err)
 						return
 					}
 				case "limit":
 					var err error
 					limit, err = strconv.Atoi(v)
-					if err != nil {
-						w.Header().Set("Content-Type", "application/json; charset=utf-8")
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						_ = json.NewEncoder(w).Encode(ErrorResponse{
-							Errors: map[string]interface{}{
-								"message": "invalid limit received",
-								"error":   err.Error(),
-							},
-						})
+					if err != nil || limit < 0 {
+						h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid limit received", err)
 						return
 					}
 				default:
@@ -790,14 +2098,7 @@ func (h *handler) handleFeedArticles() http.HandlerFunc {
 		// get followee
 		users, err := h.userRepo.ListByFollowedBy(currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on get user followee",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on get user followee", err)
 			return
 		}
 
@@ -805,14 +2106,7 @@ func (h *handler) handleFeedArticles() http.HandlerFunc {
 
 		res, total, err := h.articleRepo.List(offset, limit, filters...)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "list article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "list article failed", err)
 			return
 		}
 
@@ -828,26 +2122,7 @@ func (h *handler) handleFeedArticles() http.HandlerFunc {
 
 			user, err := h.userRepo.GetByID(res[i].AuthorID)
 			if err != nil {
-				if errors.As(err, &models.UserByIDNotFoundError{}) {
-					w.Header().Set("Content-Type", "application/json; charset=utf-8")
-					w.WriteHeader(http.StatusNotFound)
-					_ = json.NewEncoder(w).Encode(ErrorResponse{
-						Errors: map[string]interface{}{
-							"message": "user not found",
-							"error":   err.Error(),
-						},
-					})
-					return
-				}
-
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "get user by id failed",
-						"error":   err.Error(),
-					},
-				})
+				h.respondError(w, r, "get user by id failed", err)
 				return
 			}
 
@@ -898,31 +2173,12 @@ func (h *handler) handleGetArticle() http.HandlerFunc {
 		}
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// find article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
@@ -930,25 +2186,11 @@ func (h *handler) handleGetArticle() http.HandlerFunc {
 		user, err := h.userRepo.GetByID(article.AuthorID)
 		if err != nil {
 			if errors.As(err, &models.UserByIDNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "author of article not found",
-						"error":   err.Error(),
-					},
-				})
+				h.respondStatus(w, r, http.StatusInternalServerError, "author of article not found", err)
 				return
 			}
 
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get author of article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get author of article failed", err)
 			return
 		}
 
@@ -1011,13 +2253,19 @@ func (h *handler) handleCreateArticle() http.HandlerFunc {
 		var req Request
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate request
+		errs := validationErrors{}
+		errs.text("title", req.Article.Title, true, maxTitleLength)
+		errs.text("description", req.Article.Description, true, maxDescriptionLength)
+		errs.text("body", req.Article.Body, true, maxArticleBodyLength)
+		errs.tags("tagList", req.Article.TagList)
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
 			return
 		}
 
@@ -1037,14 +2285,7 @@ func (h *handler) handleCreateArticle() http.HandlerFunc {
 
 		err = h.articleRepo.Add(article)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on create article",
-					"body":    err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on create article", err)
 			return
 		}
 
@@ -1089,43 +2330,18 @@ func (h *handler) handleUpdateArticle() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// find article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
-		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+		if err != nil {
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
-		// check author
-		if article.AuthorID != currentUser.ID {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusForbidden)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "you are not author of this article",
-				},
-			})
+		// check author, staff can moderate any article
+		if article.AuthorID != currentUser.ID && !currentUser.Role.AtLeast(models.RoleModerator) {
+			h.respondStatus(w, r, http.StatusForbidden, "you are not author of this article", nil)
 			return
 		}
 
@@ -1133,17 +2349,31 @@ func (h *handler) handleUpdateArticle() http.HandlerFunc {
 		var req Request
 		err = json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
 			return
 		}
 
 		// update fields
+		// validate request, for given fields
+		errs := validationErrors{}
+
+		if req.Article.Title != nil {
+			errs.text("title", *req.Article.Title, true, maxTitleLength)
+		}
+
+		if req.Article.Description != nil {
+			errs.text("description", *req.Article.Description, true, maxDescriptionLength)
+		}
+
+		if req.Article.Body != nil {
+			errs.text("body", *req.Article.Body, true, maxArticleBodyLength)
+		}
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
+			return
+		}
+
 		if req.Article.Title != nil {
 			article.Title = *req.Article.Title
 			article.Slug = fmt.Sprintf("%s-%s", slugify.Make(*req.Article.Title), uniqueID.New(6))
@@ -1160,17 +2390,20 @@ func (h *handler) handleUpdateArticle() http.HandlerFunc {
 		// update article
 		err = h.articleRepo.UpdateBySlug(slug, *article)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on update article",
-					"body":    err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on update article", err)
 			return
 		}
 
+		// find author, who is not current user if staff updated it
+		author := currentUser
+		if article.AuthorID != currentUser.ID {
+			author, err = h.userRepo.GetByID(article.AuthorID)
+			if err != nil {
+				h.respondError(w, r, "get user by id failed", err)
+				return
+			}
+		}
+
 		favorited := false
 		if currentUser != nil {
 			if f, ok := article.Favorites[currentUser.ID]; f && ok {
@@ -1179,7 +2412,7 @@ func (h *handler) handleUpdateArticle() http.HandlerFunc {
 		}
 
 		following := false
-		if f, ok := currentUser.Followers[currentUser.ID]; f && ok {
+		if f, ok := author.Followers[currentUser.ID]; f && ok {
 			following = true
 		}
 
@@ -1198,9 +2431,9 @@ func (h *handler) handleUpdateArticle() http.HandlerFunc {
 				Favorited:      favorited,
 				FavoritesCount: len(article.Favorites),
 				Author: Author{
-					Username:  currentUser.Username,
-					Bio:       currentUser.Bio,
-					Image:     currentUser.Image,
+					Username:  author.Username,
+					Bio:       author.Bio,
+					Image:     author.Image,
 					Following: following,
 				},
 			},
@@ -1214,57 +2447,25 @@ func (h *handler) handleDeleteArticle() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// find article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
-		// check author
-		if article.AuthorID != currentUser.ID {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusForbidden)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "you are not author of this article",
-				},
-			})
+		// check author, staff can moderate any article
+		if article.AuthorID != currentUser.ID && !currentUser.Role.AtLeast(models.RoleModerator) {
+			h.respondStatus(w, r, http.StatusForbidden, "you are not author of this article", nil)
 			return
 		}
 
 		// delete article
 		err = h.articleRepo.DeleteBySlug(slug)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on update article",
-					"body":    err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on update article", err)
 			return
 		}
 
@@ -1287,31 +2488,12 @@ func (h *handler) handleAddCommentsToAnArticle() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// find article by slug
-		article, err := h.articleRepo.GetBySlug(slug)
+		_, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
@@ -1319,38 +2501,38 @@ func (h *handler) handleAddCommentsToAnArticle() http.HandlerFunc {
 		var req Request
 		err = json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"body": err.Error(),
-				},
-			})
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate request
+		errs := validationErrors{}
+		errs.text("body", req.Comment.Body, true, maxCommentBodyLength)
+
+		if len(errs) > 0 {
+			h.respondValidationErrors(w, r, errs)
 			return
 		}
 
 		// create comment
+		commentID, err := h.articleRepo.NewCommentID()
+		if err != nil {
+			h.respondError(w, r, "error on generate comment id", err)
+			return
+		}
+
 		comment := models.Comment{
-			ID:        h.articleRepo.NewCommentID(),
+			ID:        commentID,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 			Body:      req.Comment.Body,
 			AuthorID:  currentUser.ID,
 		}
 
-		article.Comments = append(article.Comments, comment)
-
-		// update article
-		err = h.articleRepo.UpdateBySlug(slug, *article)
+		// add comment
+		err = h.articleRepo.AddCommentBySlug(slug, comment)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on update article",
-					"body":    err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on add comment", err)
 			return
 		}
 
@@ -1390,31 +2572,12 @@ func (h *handler) handleGetCommentsFromAnArticle() http.HandlerFunc {
 		}
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// find article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
@@ -1423,25 +2586,11 @@ func (h *handler) handleGetCommentsFromAnArticle() http.HandlerFunc {
 			author, err := h.userRepo.GetByID(article.Comments[i].AuthorID)
 			if err != nil {
 				if errors.As(err, &models.UserByIDNotFoundError{}) {
-					w.Header().Set("Content-Type", "application/json; charset=utf-8")
-					w.WriteHeader(http.StatusInternalServerError)
-					_ = json.NewEncoder(w).Encode(ErrorResponse{
-						Errors: map[string]interface{}{
-							"message": "author of comment not found",
-							"error":   err.Error(),
-						},
-					})
+					h.respondStatus(w, r, http.StatusInternalServerError, "author of comment not found", err)
 					return
 				}
 
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "get author of comment failed",
-						"error":   err.Error(),
-					},
-				})
+				h.respondError(w, r, "get author of comment failed", err)
 				return
 			}
 
@@ -1481,90 +2630,39 @@ func (h *handler) handleDeleteComment() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
-		idStr := r.Context().Value("id").(string)
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "invalid comment id received",
-					"error":   err.Error(),
-				},
-			})
-			return
-		}
+		slug := PathParam(r, "slug")
+		id := PathParamInt(r, "id")
 
 		// find article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.ArticleBySlugNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": fmt.Sprintf("article with slug '%s' not found", slug),
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
-		deleted := false
+		found := false
 		for i := range article.Comments {
 			if article.Comments[i].ID == id {
-				// check owner
-				if article.Comments[i].AuthorID != currentUser.ID {
-					w.Header().Set("Content-Type", "application/json; charset=utf-8")
-					w.WriteHeader(http.StatusForbidden)
-					_ = json.NewEncoder(w).Encode(ErrorResponse{
-						Errors: map[string]interface{}{
-							"message": "you are not author of this comment",
-						},
-					})
+				// check owner, staff can moderate any comment
+				if article.Comments[i].AuthorID != currentUser.ID && !currentUser.Role.AtLeast(models.RoleModerator) {
+					h.respondStatus(w, r, http.StatusForbidden, "you are not author of this comment", nil)
 					return
 				}
 
-				article.Comments = append(article.Comments[:i], article.Comments[i+1:]...)
-				deleted = true
+				found = true
 				break
 			}
 		}
 
-		if !deleted {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusNotFound)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": fmt.Sprintf("comment with id '%d' in article with slug '%s' not found", id, slug),
-				},
-			})
+		if !found {
+			h.respondStatus(w, r, http.StatusNotFound, fmt.Sprintf("comment with id '%d' in article with slug '%s' not found", id, slug), nil)
 			return
 		}
 
-		// update article
-		err = h.articleRepo.UpdateBySlug(slug, *article)
+		// delete comment
+		err = h.articleRepo.DeleteCommentBySlug(slug, id)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "error on update article",
-					"body":    err.Error(),
-				},
-			})
+			h.respondError(w, r, "error on delete comment", err)
 			return
 		}
 
@@ -1580,73 +2678,33 @@ func (h *handler) handleFavoriteArticle() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// get article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "article not found",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
-		article.Favorites[currentUser.ID] = true
-
-		// update article
-		err = h.articleRepo.UpdateBySlug(slug, *article)
+		// favorite article
+		err = h.articleRepo.AddFavoriteBySlug(slug, currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "update article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "favorite article failed", err)
 			return
 		}
 
+		article.Favorites[currentUser.ID] = true
+
 		// find user by id
 		user, err := h.userRepo.GetByID(article.AuthorID)
 		if err != nil {
 			if errors.As(err, &models.UserByIDNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "author of article not found",
-						"error":   err.Error(),
-					},
-				})
+				h.respondStatus(w, r, http.StatusInternalServerError, "author of article not found", err)
 				return
 			}
 
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get author of article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get author of article failed", err)
 			return
 		}
 
@@ -1697,73 +2755,33 @@ func (h *handler) handleUnfavoriteArticle() http.HandlerFunc {
 		currentUser := r.Context().Value(currentUserCtx).(*models.User)
 
 		// get params
-		slug := r.Context().Value("slug").(string)
+		slug := PathParam(r, "slug")
 
 		// get article by slug
 		article, err := h.articleRepo.GetBySlug(slug)
 		if err != nil {
-			if errors.As(err, &models.UserByUsernameNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "article not found",
-						"error":   err.Error(),
-					},
-				})
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get article by slug failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get article by slug failed", err)
 			return
 		}
 
-		delete(article.Favorites, currentUser.ID)
-
-		// update article
-		err = h.articleRepo.UpdateBySlug(slug, *article)
+		// unfavorite article
+		err = h.articleRepo.RemoveFavoriteBySlug(slug, currentUser.ID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusUnauthorized)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "update article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "unfavorite article failed", err)
 			return
 		}
 
+		delete(article.Favorites, currentUser.ID)
+
 		// find user by id
 		user, err := h.userRepo.GetByID(article.AuthorID)
 		if err != nil {
 			if errors.As(err, &models.UserByIDNotFoundError{}) {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "author of article not found",
-						"error":   err.Error(),
-					},
-				})
+				h.respondStatus(w, r, http.StatusInternalServerError, "author of article not found", err)
 				return
 			}
 
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get author of article failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get author of article failed", err)
 			return
 		}
 
@@ -1813,14 +2831,7 @@ func (h *handler) handleGetTags() http.HandlerFunc {
 		// get tags
 		tags, err := h.articleRepo.GetTags()
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_ = json.NewEncoder(w).Encode(ErrorResponse{
-				Errors: map[string]interface{}{
-					"message": "get tags failed",
-					"error":   err.Error(),
-				},
-			})
+			h.respondError(w, r, "get tags failed", err)
 			return
 		}
 
@@ -1832,3 +2843,187 @@ func (h *handler) handleGetTags() http.HandlerFunc {
 		})
 	}
 }
+
+func (h *handler) handleJWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(h.keys.JWKS())
+	}
+}
+
+func (h *handler) handleUpdateUserRole() http.HandlerFunc {
+	type Request struct {
+		User struct {
+			Role string `json:"role"`
+		} `json:"user"`
+	}
+
+	type Response AdminUserResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// get request body
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			h.respondStatus(w, r, http.StatusUnprocessableEntity, "request body is invalid", err)
+			return
+		}
+
+		// validate role
+		role := models.Role(req.User.Role)
+		if !role.Valid() {
+			h.respondValidationErrors(w, r, validationErrors{"role": {"is invalid"}})
+			return
+		}
+
+		// get user by username
+		user, err := h.userRepo.GetByUsername(PathParam(r, "username"))
+		if err != nil {
+			h.respondError(w, r, "get user by username failed", err)
+			return
+		}
+
+		// admins can not demote themselves by mistake, but they can demote other admins,
+		// so admins of configured emails are the way back in, being promoted again on their login
+		if user.ID == currentUser.ID {
+			h.respondStatus(w, r, http.StatusForbidden, "you can not change your own role", nil)
+			return
+		}
+
+		// the deleted user keeps its role, so it is still told apart from users registered with its name
+		if user.Role == models.RoleDeleted {
+			h.respondStatus(w, r, http.StatusForbidden, "you can not change role of the deleted user", nil)
+			return
+		}
+
+		// update role
+		user.Role = role
+
+		err = h.userRepo.UpdateByID(user.ID, *user)
+		if err != nil {
+			h.respondError(w, r, "update user failed", err)
+			return
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			User: AdminUser{
+				Username: user.Username,
+				Email:    user.Email,
+				Role:     string(user.Role),
+				Verified: user.Verified,
+			},
+		})
+	}
+}
+
+func (h *handler) handleAdminDeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get current user
+		currentUser := r.Context().Value(currentUserCtx).(*models.User)
+
+		// get user by username
+		user, err := h.userRepo.GetByUsername(PathParam(r, "username"))
+		if err != nil {
+			h.respondError(w, r, "get user by username failed", err)
+			return
+		}
+
+		// admins delete their own account by confirming their password, like others
+		if user.ID == currentUser.ID {
+			h.respondStatus(w, r, http.StatusForbidden, "you can not delete your own account here", nil)
+			return
+		}
+
+		// delete user
+		err = h.deleteUser(*user)
+		if err != nil {
+			h.respondError(w, r, "delete user failed", err)
+			return
+		}
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) handleListLockouts() http.HandlerFunc {
+	type Response MultipleLockoutsResponse
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var (
+			offset = 0
+			limit  = 20
+			err    error
+		)
+
+		if v := query.Get("offset"); v != "" {
+			offset, err = strconv.Atoi(v)
+			if err != nil || offset < 0 {
+				h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid offset received", nil)
+				return
+			}
+		}
+
+		if v := query.Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 0 {
+				h.respondStatus(w, r, http.StatusUnprocessableEntity, "invalid limit received", nil)
+				return
+			}
+		}
+
+		res, total, err := h.loginThrottleRepo.ListLockouts(offset, limit)
+		if err != nil {
+			h.respondError(w, r, "list lockouts failed", err)
+			return
+		}
+
+		now := time.Now()
+
+		lockouts := make([]Lockout, len(res))
+		for i := range res {
+			lockouts[i] = Lockout{
+				ID:        res[i].ID,
+				Kind:      string(res[i].Kind),
+				Subject:   res[i].Subject,
+				Failures:  res[i].Failures,
+				CreatedAt: res[i].CreatedAt.UTC().Format(dateLayout),
+				ExpiresAt: res[i].ExpiresAt.UTC().Format(dateLayout),
+				Active:    now.Before(res[i].ExpiresAt),
+			}
+		}
+
+		// success response
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{
+			Lockouts:      lockouts,
+			LockoutsCount: total,
+		})
+	}
+}
+
+func (h *handler) handleUnlockLockout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// end lockout, forgetting failures of its account or ip
+		err := h.loginThrottleRepo.Unlock(PathParam(r, "id"), time.Now())
+		if err != nil {
+			h.respondError(w, r, "unlock lockout failed", err)
+			return
+		}
+
+		// success response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
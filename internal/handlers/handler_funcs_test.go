@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestModeration(t *testing.T) {
+	tt := map[string]struct {
+		role   models.Role
+		status int
+	}{
+		"User":      {role: models.RoleUser, status: http.StatusForbidden},
+		"Moderator": {role: models.RoleModerator, status: http.StatusOK},
+		"Admin":     {role: models.RoleAdmin, status: http.StatusOK},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			api := newTestAPI(t)
+			author := api.register("author")
+			other := api.register("other")
+			api.setRole("other", tc.role)
+
+			slug := api.createArticle(author.Token, "How To")
+			commentID := api.addComment(author.Token, slug)
+
+			deleted := http.StatusNoContent
+			if tc.status == http.StatusForbidden {
+				deleted = http.StatusForbidden
+			}
+
+			update := map[string]interface{}{"article": map[string]interface{}{"body": "moderated"}}
+			api.expect(api.request(http.MethodPut, "/articles/"+slug, other.Token, update), tc.status)
+			api.expect(api.request(http.MethodDelete, fmt.Sprintf("/articles/%s/comments/%d", slug, commentID), other.Token, nil), deleted)
+			api.expect(api.request(http.MethodDelete, "/articles/"+slug, other.Token, nil), deleted)
+		})
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	api := newTestAPI(t, WithDeletedContentPolicy(ContentPolicyAnonymize, ""))
+	admin := api.register("admin")
+	api.setRole("admin", models.RoleAdmin)
+	user := api.register("alice")
+
+	role := func(role models.Role) map[string]interface{} {
+		return map[string]interface{}{"user": map[string]interface{}{"role": role}}
+	}
+
+	t.Run("Promote", func(t *testing.T) {
+		w := api.request(http.MethodPut, "/admin/users/alice/role", admin.Token, role(models.RoleModerator))
+		api.expect(w, http.StatusOK)
+
+		var res AdminUserResponse
+		api.decode(w, &res)
+
+		if res.User.Role != string(models.RoleModerator) {
+			t.Errorf("expected role '%s', but got '%s'", models.RoleModerator, res.User.Role)
+		}
+	})
+
+	t.Run("Not Admin", func(t *testing.T) {
+		api.expect(api.request(http.MethodPut, "/admin/users/admin/role", user.Token, role(models.RoleUser)), http.StatusForbidden)
+	})
+
+	t.Run("Invalid Role", func(t *testing.T) {
+		api.expect(api.request(http.MethodPut, "/admin/users/alice/role", admin.Token, role(models.RoleDeleted)), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Own Role", func(t *testing.T) {
+		api.expect(api.request(http.MethodPut, "/admin/users/admin/role", admin.Token, role(models.RoleUser)), http.StatusForbidden)
+	})
+
+	t.Run("Deleted User", func(t *testing.T) {
+		// anonymizing content of a deleted user adds the deleted user
+		bob := api.register("bob")
+		api.createArticle(bob.Token, "Anonymized")
+		api.expect(api.request(http.MethodDelete, "/admin/users/bob", admin.Token, nil), http.StatusNoContent)
+
+		api.expect(api.request(http.MethodPut, "/admin/users/"+deletedUsername+"/role", admin.Token, role(models.RoleUser)), http.StatusForbidden)
+	})
+}
+
+func TestConfiguredAdmin(t *testing.T) {
+	api := newTestAPI(t, WithAdminEmails("root@example.com"))
+	admin := api.register("admin")
+	api.setRole("admin", models.RoleAdmin)
+	api.register("root")
+
+	user, err := api.h.userRepo.GetByUsername("root")
+	if err != nil {
+		t.Fatalf("error on get user: %s", err.Error())
+	}
+
+	user.Verified = true
+
+	err = api.h.userRepo.UpdateByID(user.ID, *user)
+	if err != nil {
+		t.Fatalf("error on update user: %s", err.Error())
+	}
+
+	if root := api.login("root"); root.Role != string(models.RoleAdmin) {
+		t.Fatalf("expected role '%s', but got '%s'", models.RoleAdmin, root.Role)
+	}
+
+	// there is no last admin check, so configured admins are promoted again on their login
+	body := map[string]interface{}{"user": map[string]interface{}{"role": models.RoleUser}}
+	api.expect(api.request(http.MethodPut, "/admin/users/root/role", admin.Token, body), http.StatusOK)
+
+	if root := api.login("root"); root.Role != string(models.RoleAdmin) {
+		t.Errorf("expected role '%s' again, but got '%s'", models.RoleAdmin, root.Role)
+	}
+}
+
+func TestListArticlesPagination(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	api.createArticle(alice.Token, "How To")
+
+	for _, path := range []string{"/articles", "/articles/feed"} {
+		t.Run(path, func(t *testing.T) {
+			for query, status := range map[string]int{
+				"":                  http.StatusOK,
+				"?offset=1&limit=1": http.StatusOK,
+				"?offset=10":        http.StatusOK,
+				"?offset=-1":        http.StatusUnprocessableEntity,
+				"?limit=-1":         http.StatusUnprocessableEntity,
+				"?offset=one":       http.StatusUnprocessableEntity,
+			} {
+				api.expect(api.request(http.MethodGet, path+query, alice.Token, nil), status)
+			}
+		})
+	}
+}
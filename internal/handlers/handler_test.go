@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/inmem"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/mail"
+	"github.com/nasermirzaei89/realworld-go/pkg/password"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPassword = "password123"
+
+// testAPI is a handler with in-memory repositories, for sending requests to it like clients do
+type testAPI struct {
+	t      *testing.T
+	h      *handler
+	mailer *testMailer
+}
+
+// testMailer keeps sent messages, failing with err if it is set
+type testMailer struct {
+	messages []mail.Message
+	err      error
+}
+
+func (m *testMailer) Send(msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+func newTestAPI(t *testing.T, opts ...Option) *testAPI {
+	t.Helper()
+
+	hasher, err := password.Bcrypt(password.BcryptMinCost)
+	if err != nil {
+		t.Fatalf("error on create password hasher: %s", err.Error())
+	}
+
+	mailer := new(testMailer)
+
+	opts = append([]Option{
+		WithPasswordHasher(hasher),
+		WithMailer(mailer),
+		WithErrorLog(log.New(io.Discard, "", 0)),
+	}, opts...)
+
+	h := NewHandler(
+		inmem.NewUserRepository(),
+		inmem.NewArticleRepository(),
+		inmem.NewSessionRepository(),
+		inmem.NewPersonalAccessTokenRepository(),
+		inmem.NewOneTimeTokenRepository(),
+		inmem.NewTwoFactorRepository(),
+		inmem.NewLoginThrottleRepository(),
+		inmem.NewIdentityRepository(),
+		jwt.NewKeySet("test", jwt.NewHMACKey([]byte("test"))),
+		opts...,
+	)
+
+	return &testAPI{t: t, h: h.(*handler), mailer: mailer}
+}
+
+// request sends a request with body encoded as json if it is not nil, and token in Authorization header if not empty
+func (api *testAPI) request(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatalf("error on marshal request body: %s", err.Error())
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, path, reqBody)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		r.Header.Set("Authorization", "Token "+token)
+	}
+
+	w := httptest.NewRecorder()
+	api.h.ServeHTTP(w, r)
+
+	return w
+}
+
+// expect fails the test if w does not have status
+func (api *testAPI) expect(w *httptest.ResponseRecorder, status int) {
+	api.t.Helper()
+
+	if w.Code != status {
+		api.t.Fatalf("expected status %d, but got %d with body %s", status, w.Code, w.Body.String())
+	}
+}
+
+// decode decodes body of w to res
+func (api *testAPI) decode(w *httptest.ResponseRecorder, res interface{}) {
+	api.t.Helper()
+
+	err := json.NewDecoder(w.Body).Decode(res)
+	if err != nil {
+		api.t.Fatalf("error on decode response body: %s", err.Error())
+	}
+}
+
+// register registers a user with username, returning them with their tokens
+func (api *testAPI) register(username string) User {
+	api.t.Helper()
+
+	body := map[string]interface{}{
+		"user": map[string]interface{}{
+			"username": username,
+			"email":    username + "@example.com",
+			"password": testPassword,
+		},
+	}
+
+	w := api.request(http.MethodPost, "/users", "", body)
+	api.expect(w, http.StatusOK)
+
+	var res UserResponse
+	api.decode(w, &res)
+
+	return res.User
+}
+
+// setRole sets role of user with username in the repository, like admins do
+func (api *testAPI) setRole(username string, role models.Role) {
+	api.t.Helper()
+
+	user, err := api.h.userRepo.GetByUsername(username)
+	if err != nil {
+		api.t.Fatalf("error on get user: %s", err.Error())
+	}
+
+	user.Role = role
+
+	err = api.h.userRepo.UpdateByID(user.ID, *user)
+	if err != nil {
+		api.t.Fatalf("error on update user: %s", err.Error())
+	}
+}
+
+// createArticle creates an article with title by token, returning its slug
+func (api *testAPI) createArticle(token, title string) string {
+	api.t.Helper()
+
+	body := map[string]interface{}{
+		"article": map[string]interface{}{
+			"title":       title,
+			"description": "description",
+			"body":        "body",
+		},
+	}
+
+	w := api.request(http.MethodPost, "/articles", token, body)
+	api.expect(w, http.StatusCreated)
+
+	var res SingleArticleResponse
+	api.decode(w, &res)
+
+	return res.Article.Slug
+}
+
+// addComment comments on article with slug by token, returning id of the comment
+func (api *testAPI) addComment(token, slug string) int {
+	api.t.Helper()
+
+	body := map[string]interface{}{
+		"comment": map[string]interface{}{
+			"body": "comment",
+		},
+	}
+
+	w := api.request(http.MethodPost, "/articles/"+slug+"/comments", token, body)
+	api.expect(w, http.StatusCreated)
+
+	var res SingleCommentResponse
+	api.decode(w, &res)
+
+	return res.Comment.ID
+}
+
+// login logs in user with username, returning them with tokens of a new session
+func (api *testAPI) login(username string) User {
+	api.t.Helper()
+
+	body := map[string]interface{}{
+		"user": map[string]interface{}{
+			"email":    username + "@example.com",
+			"password": testPassword,
+		},
+	}
+
+	w := api.request(http.MethodPost, "/users/login", "", body)
+	api.expect(w, http.StatusOK)
+
+	var res UserResponse
+	api.decode(w, &res)
+
+	return res.User
+}
+
+// mailed returns the token or link of the last message sent to email, which is on its own paragraph
+func (api *testAPI) mailed(email string) string {
+	api.t.Helper()
+
+	for i := len(api.mailer.messages) - 1; i >= 0; i-- {
+		if msg := api.mailer.messages[i]; msg.To == email {
+			if paragraphs := strings.Split(msg.Body, "\n\n"); len(paragraphs) > 2 {
+				return paragraphs[2]
+			}
+		}
+	}
+
+	api.t.Fatalf("expected a message sent to '%s', but got none", email)
+
+	return ""
+}
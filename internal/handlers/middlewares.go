@@ -2,29 +2,28 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
 	"net/http"
-	"strconv"
 	"strings"
 )
 
 type contextKey string
 
-const currentUserCtx contextKey = "current_user"
+const (
+	currentUserCtx    contextKey = "current_user"
+	currentTokenCtx   contextKey = "current_token"
+	currentSessionCtx contextKey = "current_session"
+	// currentPersonalAccessTokenCtx is set instead of token and session when authenticated by a personal access token
+	currentPersonalAccessTokenCtx contextKey = "current_personal_access_token"
+)
 
 func (h *handler) middlewareAuthentication(next http.HandlerFunc, force bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		tokenStr, fromCookie, err := h.requestToken(r)
+		if err != nil {
 			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "missing authorization header",
-					},
-				})
+				h.respondStatus(w, r, http.StatusUnauthorized, err.Error(), nil)
 			} else {
 				next(w, r)
 			}
@@ -32,34 +31,18 @@ func (h *handler) middlewareAuthentication(next http.HandlerFunc, force bool) ht
 			return
 		}
 
-		if !strings.HasPrefix(authHeader, "Token ") {
-			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-					},
-				})
-			} else {
-				next(w, r)
-			}
+		// personal access tokens are told apart by their prefix, other tokens are jwt, which cookies only have
+		var ctx context.Context
 
-			return
+		if !fromCookie && strings.HasPrefix(tokenStr, personalAccessTokenPrefix) {
+			ctx, err = h.authenticatePersonalAccessToken(r.Context(), tokenStr)
+		} else {
+			ctx, err = h.authenticateJWT(r, tokenStr)
 		}
 
-		tokenStr := authHeader[6:]
-		err := jwt.Verify(tokenStr, h.secret)
 		if err != nil {
 			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-						"error":   err.Error(),
-					},
-				})
+				h.respondStatus(w, r, http.StatusUnauthorized, "invalid authorization header", err)
 			} else {
 				next(w, r)
 			}
@@ -67,79 +50,96 @@ func (h *handler) middlewareAuthentication(next http.HandlerFunc, force bool) ht
 			return
 		}
 
-		token, err := jwt.Parse(tokenStr)
-		if err != nil {
-			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-						"error":   err.Error(),
-					},
-				})
-			} else {
-				next(w, r)
+		// cookies are sent by browsers on requests of other sites too, so unsafe ones need the csrf token,
+		// which only pages of allowed origins can read
+		if fromCookie && !safeMethod(r.Method) {
+			err = h.verifyCSRFToken(r, ctx.Value(currentSessionCtx).(*models.Session).ID)
+			if err != nil {
+				if force {
+					h.respondStatus(w, r, http.StatusForbidden, "invalid csrf token", err)
+				} else {
+					next(w, r)
+				}
+
+				return
 			}
+		}
+
+		next(w, r.WithContext(ctx))
+	}
+}
 
+// middlewareAuthorization rejects users without role, or a higher one, so it goes after middlewareAuthentication
+func (h *handler) middlewareAuthorization(next http.HandlerFunc, role models.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentUser, ok := r.Context().Value(currentUserCtx).(*models.User); !ok || !currentUser.Role.AtLeast(role) {
+			h.respondStatus(w, r, http.StatusForbidden, fmt.Sprintf("permission denied: role '%s' is required", role), nil)
 			return
 		}
 
-		sub, err := token.GetSubject()
-		if err != nil {
-			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-						"error":   err.Error(),
-					},
-				})
-			} else {
-				next(w, r)
-			}
+		next(w, r)
+	}
+}
 
+// middlewareVerified rejects users who have not verified their email if it is required, so it goes after middlewareAuthentication
+func (h *handler) middlewareVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentUser, ok := r.Context().Value(currentUserCtx).(*models.User); h.emailVerificationRequired && (!ok || !currentUser.Verified) {
+			h.respondStatus(w, r, http.StatusForbidden, "email is not verified", nil)
 			return
 		}
 
-		userID, err := strconv.Atoi(sub)
-		if err != nil {
-			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-						"error":   err.Error(),
-					},
-				})
-			} else {
-				next(w, r)
-			}
+		next(w, r)
+	}
+}
 
+// middlewareScope rejects personal access tokens without scope, while sessions are allowed everything
+func (h *handler) middlewareScope(next http.HandlerFunc, scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := r.Context().Value(currentPersonalAccessTokenCtx).(*models.PersonalAccessToken); ok && !hasScope(token.Scopes, scope) {
+			h.respondStatus(w, r, http.StatusForbidden, fmt.Sprintf("insufficient scope: scope '%s' is required", scope), nil)
 			return
 		}
 
-		user, err := h.userRepo.GetByID(userID)
-		if err != nil {
-			if force {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(ErrorResponse{
-					Errors: map[string]interface{}{
-						"message": "invalid authorization header",
-						"error":   err.Error(),
-					},
-				})
-			} else {
-				next(w, r)
-			}
+		next(w, r)
+	}
+}
 
+// middlewareSession rejects personal access tokens, so a leaked one can not take over the account,
+// e.g. by changing password or minting more tokens
+func (h *handler) middlewareSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(currentSessionCtx) == nil {
+			h.respondStatus(w, r, http.StatusForbidden, "login required: personal access tokens are not allowed", nil)
 			return
 		}
 
-		r = r.WithContext(context.WithValue(r.Context(), currentUserCtx, user))
+		next(w, r)
+	}
+}
+
+// middlewareCORS sets CORS headers of responses to origins the policy allows, so it goes before routing,
+// and preflight requests are answered by handleCORS
+func (h *handler) middlewareCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.cors.anyOrigin() {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+
+			if origin := r.Header.Get("Origin"); origin != "" && h.cors.allowsOrigin(origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+
+				if h.cors.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+
+		if len(h.cors.ExposedHeaders) > 0 && w.Header().Get("Access-Control-Allow-Origin") != "" {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(h.cors.ExposedHeaders, ", "))
+		}
+
 		next(w, r)
 	}
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	uniqueID "github.com/nasermirzaei89/realworld-go/pkg/id"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/oidc"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// audienceOIDCLogin is the audience of tokens in the OpenID Connect login cookie, so they are not mistaken for other tokens
+const audienceOIDCLogin = "oidc-login"
+
+// oidcLoginCookie keeps state, nonce and code verifier of a login at the OpenID provider until its callback,
+// so the callback is accepted only in the browser that started the login
+const oidcLoginCookie = "oidc_login"
+
+// oidcLoginLifetime is how long users have to log in at the OpenID provider
+const oidcLoginLifetime = 10 * time.Minute
+
+type oidcLoginClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// newOIDCLogin returns random state, nonce and code verifier of a login at the OpenID provider
+func newOIDCLogin() (*oidcLoginClaims, error) {
+	var login oidcLoginClaims
+
+	for _, v := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		s, err := oidc.NewVerifier()
+		if err != nil {
+			return nil, err
+		}
+
+		*v = s
+	}
+
+	return &login, nil
+}
+
+// oidcLoginCookie returns the cookie keeping login signed, or clearing it if login is nil
+func (h *handler) oidcLoginCookie(login *oidcLoginClaims) (*http.Cookie, error) {
+	cookie := http.Cookie{
+		Name:     oidcLoginCookie,
+		Path:     "/users/oidc",
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.oidcProvider.RedirectURL(), "https://"),
+		// the callback is a top level navigation from the provider, which lax cookies are sent on
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if login == nil {
+		cookie.MaxAge = -1
+
+		return &cookie, nil
+	}
+
+	now := time.Now()
+
+	token := jwt.New()
+	token.SetIssuedAt(now)
+	token.SetExpirationTime(now.Add(oidcLoginLifetime))
+	token.SetAudience(audienceOIDCLogin)
+
+	err := token.SetClaims(login)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := h.keys.Sign(token)
+	if err != nil {
+		return nil, fmt.Errorf("error on sign oidc login token: %w", err)
+	}
+
+	cookie.Value = signed
+	cookie.MaxAge = int(oidcLoginLifetime.Seconds())
+
+	return &cookie, nil
+}
+
+// parseOIDCLoginCookie verifies the login cookie of r and returns its login
+func (h *handler) parseOIDCLoginCookie(r *http.Request) (*oidcLoginClaims, error) {
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil {
+		return nil, fmt.Errorf("error on get oidc login cookie: %w", err)
+	}
+
+	token, err := h.keys.ParseAndVerify(
+		cookie.Value,
+		jwt.WithAudience(audienceOIDCLogin),
+		jwt.WithRequiredClaims(jwt.ClaimExpirationTime),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var login oidcLoginClaims
+
+	err = token.Claims(&login)
+	if err != nil {
+		return nil, err
+	}
+
+	return &login, nil
+}
+
+var nonUsernameChars = regexp.MustCompile(`\W+`)
+
+// oidcUsername returns a free username for a user of identity, based on its preferred username or its email
+func (h *handler) oidcUsername(claims oidc.Claims) (string, error) {
+	base := nonUsernameChars.ReplaceAllString(claims.PreferredUsername, "")
+	if base == "" {
+		base = nonUsernameChars.ReplaceAllString(strings.SplitN(claims.Email, "@", 2)[0], "")
+	}
+
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+
+	for i := 0; i < 5; i++ {
+		_, err := h.userRepo.GetByUsername(username)
+		if err != nil && !errors.As(err, &models.UserByUsernameNotFoundError{}) {
+			return "", fmt.Errorf("error on get user by username: %w", err)
+		}
+
+		if err != nil && username != deletedUsername {
+			return username, nil
+		}
+
+		username = base + "_" + uniqueID.New(4)
+	}
+
+	return "", fmt.Errorf("no free username for '%s'", base)
+}
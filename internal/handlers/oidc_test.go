@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/oidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProvider is a local OpenID provider, which logs in its user without asking
+type testProvider struct {
+	*httptest.Server
+	t    *testing.T
+	keys *jwt.KeySet
+
+	mu sync.Mutex
+	// codes are challenges and nonces of authorization codes
+	codes map[string][2]string
+	// claims are of the user logged in, on top of the claims of the token
+	claims map[string]interface{}
+}
+
+// newTestProvider starts a provider logging in carol, and returns it with a handler using it
+func newTestProvider(t *testing.T, opts ...Option) (*testProvider, *testAPI) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error on generate rsa key: %s", err.Error())
+	}
+
+	key, err := jwt.NewRSAPrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("error on create signing key: %s", err.Error())
+	}
+
+	p := &testProvider{
+		t:     t,
+		keys:  jwt.NewKeySet("key1", key),
+		codes: make(map[string][2]string),
+	}
+	p.login("carol-id", "carol@example.com", true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) { _ = json.NewEncoder(w).Encode(p.keys.JWKS()) })
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:      p.URL,
+		ClientID:    "realworld",
+		RedirectURL: "http://localhost:8080/users/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("error on discover: %s", err.Error())
+	}
+
+	return p, newTestAPI(t, append([]Option{WithOIDC(provider)}, opts...)...)
+}
+
+// login sets the user the provider logs in next
+func (p *testProvider) login(subject, email string, emailVerified bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.claims = map[string]interface{}{
+		"sub":            subject,
+		"email":          email,
+		"email_verified": emailVerified,
+	}
+}
+
+func (p *testProvider) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(oidc.Metadata{
+		Issuer:                p.URL,
+		AuthorizationEndpoint: p.URL + "/authorize",
+		TokenEndpoint:         p.URL + "/token",
+		JWKSURI:               p.URL + "/jwks",
+	})
+}
+
+func (p *testProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	code := fmt.Sprintf("code%d", time.Now().UnixNano())
+
+	p.mu.Lock()
+	p.codes[code] = [2]string{query.Get("code_challenge"), query.Get("nonce")}
+	p.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (p *testProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	// codes can be used once
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	if !ok || oidc.Challenge(r.PostFormValue("code_verifier")) != auth[0] {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+
+		return
+	}
+
+	token := jwt.New()
+
+	p.mu.Lock()
+	claims := map[string]interface{}{
+		"iss":   p.URL,
+		"aud":   "realworld",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": auth[1],
+	}
+	for name, value := range p.claims {
+		claims[name] = value
+	}
+	p.mu.Unlock()
+
+	err := token.SetClaims(claims)
+	if err != nil {
+		p.t.Errorf("error on set claims: %s", err.Error())
+	}
+
+	idToken, err := p.keys.Sign(token)
+	if err != nil {
+		p.t.Errorf("error on sign id token: %s", err.Error())
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+}
+
+// oidcLogin starts a login and follows it at the provider like a browser,
+// returning the login cookie and the code and state the provider redirects back with
+func (api *testAPI) oidcLogin() (cookie, code, state string) {
+	api.t.Helper()
+
+	w := api.request(http.MethodGet, "/users/oidc/login", "", nil)
+	api.expect(w, http.StatusFound)
+
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		api.t.Fatalf("error on authorize: %s", err.Error())
+	}
+	_ = res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		api.t.Fatalf("error on get redirect location: %s", err.Error())
+	}
+
+	login := cookiesOf(w)[oidcLoginCookie]
+	if login == nil || login.Value == "" {
+		api.t.Fatalf("expected cookie '%s' set on login", oidcLoginCookie)
+	}
+
+	return login.Value, location.Query().Get("code"), location.Query().Get("state")
+}
+
+// oidcCallback sends the callback of the provider with code and state, in the browser with the login cookie
+func (api *testAPI) oidcCallback(cookie, code, state string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	path := "/users/oidc/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+
+	return api.cookieRequest(http.MethodGet, path, map[string]string{oidcLoginCookie: cookie}, "", nil)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p, api := newTestProvider(t)
+
+	w := api.oidcCallback(api.oidcLogin())
+	api.expect(w, http.StatusOK)
+
+	var res UserResponse
+	api.decode(w, &res)
+
+	if res.User.Username != "carol" || res.User.Email != "carol@example.com" || !res.User.Verified {
+		t.Errorf("expected verified user 'carol' created, but got '%+v'", res.User)
+	}
+
+	api.expect(api.request(http.MethodGet, "/user", res.User.Token, nil), http.StatusOK)
+
+	if cookie := cookiesOf(w)[oidcLoginCookie]; cookie == nil || cookie.MaxAge >= 0 {
+		t.Error("expected login cookie cleared on callback")
+	}
+
+	identity, err := api.h.identityRepo.GetByIssuerAndSubject(p.URL, "carol-id")
+	if err != nil {
+		t.Fatalf("error on get identity: %s", err.Error())
+	}
+
+	user, err := api.h.userRepo.GetByUsername("carol")
+	if err != nil {
+		t.Fatalf("error on get user: %s", err.Error())
+	}
+
+	if identity.UserID != user.ID {
+		t.Errorf("expected identity linked to user %d, but got %d", user.ID, identity.UserID)
+	}
+
+	t.Run("Linked Identity", func(t *testing.T) {
+		// the identity logs in its user, even after the email changes at the provider
+		p.login("carol-id", "carol@other.example.com", true)
+
+		w := api.oidcCallback(api.oidcLogin())
+		api.expect(w, http.StatusOK)
+
+		var res UserResponse
+		api.decode(w, &res)
+
+		if res.User.Username != "carol" {
+			t.Errorf("expected user 'carol', but got '%s'", res.User.Username)
+		}
+	})
+}
+
+func TestOIDCLinkEmail(t *testing.T) {
+	p, api := newTestProvider(t)
+	api.register("alice")
+	api.register("bob")
+	api.verifyEmail(api.verificationToken("alice@example.com"), http.StatusNoContent)
+
+	t.Run("Verified Email", func(t *testing.T) {
+		p.login("alice-id", "alice@example.com", true)
+
+		w := api.oidcCallback(api.oidcLogin())
+		api.expect(w, http.StatusOK)
+
+		var res UserResponse
+		api.decode(w, &res)
+
+		if res.User.Username != "alice" {
+			t.Errorf("expected user 'alice' linked, but got '%s'", res.User.Username)
+		}
+
+		_, err := api.h.identityRepo.GetByIssuerAndSubject(p.URL, "alice-id")
+		if err != nil {
+			t.Errorf("error on get identity: %s", err.Error())
+		}
+	})
+
+	// accounts are linked only if both sides verified the email
+	t.Run("Unverified Email", func(t *testing.T) {
+		p.login("bob-id", "bob@example.com", true)
+		api.expect(api.oidcCallback(api.oidcLogin()), http.StatusConflict)
+	})
+
+	t.Run("Unverified At Provider", func(t *testing.T) {
+		p.login("other-alice-id", "alice@example.com", false)
+		api.expect(api.oidcCallback(api.oidcLogin()), http.StatusConflict)
+	})
+}
+
+func TestOIDCCallbackErrors(t *testing.T) {
+	_, api := newTestProvider(t)
+
+	t.Run("State Mismatch", func(t *testing.T) {
+		cookie, code, _ := api.oidcLogin()
+		api.expect(api.oidcCallback(cookie, code, "other"), http.StatusUnauthorized)
+	})
+
+	// a code redeemed with the verifier of another login fails code challenge at the provider
+	t.Run("PKCE Mismatch", func(t *testing.T) {
+		_, code, _ := api.oidcLogin()
+		cookie, _, state := api.oidcLogin()
+		api.expect(api.oidcCallback(cookie, code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("Missing Cookie", func(t *testing.T) {
+		_, code, state := api.oidcLogin()
+		api.expect(api.oidcCallback("", code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("Tampered Cookie", func(t *testing.T) {
+		cookie, code, state := api.oidcLogin()
+		api.expect(api.oidcCallback(cookie[:len(cookie)-4]+"AAAA", code, state), http.StatusUnauthorized)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		cookie, _, state := api.oidcLogin()
+		path := "/users/oidc/callback?" + url.Values{"error": {"access_denied"}, "state": {state}}.Encode()
+		api.expect(api.cookieRequest(http.MethodGet, path, map[string]string{oidcLoginCookie: cookie}, "", nil), http.StatusUnauthorized)
+	})
+
+	t.Run("Used Code", func(t *testing.T) {
+		cookie, code, state := api.oidcLogin()
+		api.expect(api.oidcCallback(cookie, code, state), http.StatusOK)
+		api.expect(api.oidcCallback(cookie, code, state), http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"strings"
+	"time"
+)
+
+var errInvalidOneTimeToken = errors.New("invalid one time token")
+
+// issueOneTimeToken adds a token of user for purpose that expires after lifetime, and returns it to be sent to user.
+// Expired tokens are cleaned up on the way, which is best effort.
+func (h *handler) issueOneTimeToken(userID int, purpose models.TokenPurpose, lifetime time.Duration) (string, error) {
+	now := time.Now()
+
+	_ = h.oneTimeTokenRepo.DeleteExpired(now)
+
+	id, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.oneTimeTokenRepo.Add(models.OneTimeToken{
+		ID:        id,
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashSecret(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error on add one time token: %w", err)
+	}
+
+	return oneTimeToken(id, secret), nil
+}
+
+// useOneTimeToken uses up token issued for purpose and returns it,
+// or errInvalidOneTimeToken if it is unknown, of another purpose, expired or already used
+func (h *handler) useOneTimeToken(tokenStr string, purpose models.TokenPurpose) (*models.OneTimeToken, error) {
+	id, secret, err := parseOneTimeToken(tokenStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidOneTimeToken, err.Error())
+	}
+
+	token, err := h.oneTimeTokenRepo.GetByID(id)
+	if err != nil {
+		if errors.As(err, &models.OneTimeTokenByIDNotFoundError{}) {
+			return nil, fmt.Errorf("%w: %s", errInvalidOneTimeToken, err.Error())
+		}
+
+		return nil, fmt.Errorf("error on get one time token: %w", err)
+	}
+
+	if token.Purpose != purpose || subtle.ConstantTimeCompare([]byte(token.TokenHash), []byte(hashSecret(secret))) != 1 {
+		return nil, fmt.Errorf("%w: token does not match", errInvalidOneTimeToken)
+	}
+
+	if token.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: token is expired", errInvalidOneTimeToken)
+	}
+
+	// the one deleting it uses it, so concurrent uses fail
+	err = h.oneTimeTokenRepo.DeleteByID(id)
+	if err != nil {
+		if errors.As(err, &models.OneTimeTokenByIDNotFoundError{}) {
+			return nil, fmt.Errorf("%w: token is already used", errInvalidOneTimeToken)
+		}
+
+		return nil, fmt.Errorf("error on delete one time token: %w", err)
+	}
+
+	return token, nil
+}
+
+// oneTimeToken is token id and secret joined by a dot
+func oneTimeToken(id, secret string) string {
+	return id + "." + secret
+}
+
+func parseOneTimeToken(token string) (id, secret string, err error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", errors.New("malformed one time token")
+	}
+
+	return id, secret, nil
+}
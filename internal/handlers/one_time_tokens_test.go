@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// passwordReset is the body of POST /users/password-reset/confirm with token and new password
+func passwordReset(token, password string) map[string]interface{} {
+	return map[string]interface{}{"user": map[string]interface{}{"token": token, "password": password}}
+}
+
+// requestPasswordReset requests a password reset for email, expecting the response of every email
+func (api *testAPI) requestPasswordReset(email string) {
+	api.t.Helper()
+
+	w := api.request(http.MethodPost, "/users/password-reset", "", map[string]interface{}{"user": map[string]interface{}{"email": email}})
+	api.expect(w, http.StatusAccepted)
+
+	if w.Body.Len() != 0 {
+		api.t.Errorf("expected empty body, but got %s", w.Body.String())
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	phone := api.login("alice")
+
+	api.requestPasswordReset(alice.Email)
+	token := api.mailed(alice.Email)
+
+	t.Run("Unknown Email", func(t *testing.T) {
+		sent := len(api.mailer.messages)
+
+		api.requestPasswordReset("nobody@example.com")
+
+		if len(api.mailer.messages) != sent {
+			t.Error("expected no message sent to unknown email")
+		}
+	})
+
+	t.Run("Invalid Password", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/users/password-reset/confirm", "", passwordReset(token, "short")), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/users/password-reset/confirm", "", passwordReset(token+"x", "new-password")), http.StatusUnauthorized)
+	})
+
+	// a rejected password or token does not use up the token
+	api.expect(api.request(http.MethodPost, "/users/password-reset/confirm", "", passwordReset(token, "new-password")), http.StatusNoContent)
+
+	t.Run("Used Token", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/users/password-reset/confirm", "", passwordReset(token, "other-password")), http.StatusUnauthorized)
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		login := func(password string) map[string]interface{} {
+			return map[string]interface{}{"user": map[string]interface{}{"email": alice.Email, "password": password}}
+		}
+
+		api.expect(api.request(http.MethodPost, "/users/login", "", login(testPassword)), http.StatusUnauthorized)
+		api.expect(api.request(http.MethodPost, "/users/login", "", login("new-password")), http.StatusOK)
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		// sessions may have been started by someone who knew the old password
+		for _, user := range []User{alice, phone} {
+			api.expect(api.request(http.MethodGet, "/user", user.Token, nil), http.StatusUnauthorized)
+			api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(user.RefreshToken)), http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestPasswordResetExpired(t *testing.T) {
+	api := newTestAPI(t, WithPasswordResetLifetime(time.Millisecond))
+	alice := api.register("alice")
+
+	api.requestPasswordReset(alice.Email)
+	token := api.mailed(alice.Email)
+
+	time.Sleep(10 * time.Millisecond)
+
+	api.expect(api.request(http.MethodPost, "/users/password-reset/confirm", "", passwordReset(token, "new-password")), http.StatusUnauthorized)
+	api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusOK)
+}
+
+func TestPasswordResetMailerFailure(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	api.mailer.err = errors.New("mail server is down")
+
+	// failures are not responded, since they would reveal emails of users
+	api.requestPasswordReset(alice.Email)
+	api.requestPasswordReset("nobody@example.com")
+}
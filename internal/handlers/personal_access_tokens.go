@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+)
+
+// personalAccessTokenPrefix starts personal access tokens, so they are told apart from jwt,
+// and secret scanners can find leaked ones
+const personalAccessTokenPrefix = "rwpat_"
+
+// Scopes of personal access tokens
+const (
+	ScopeRead          = "read"
+	ScopeWriteArticles = "write:articles"
+	ScopeWriteProfiles = "write:profiles"
+)
+
+var scopes = []string{ScopeRead, ScopeWriteArticles, ScopeWriteProfiles}
+
+func hasScope(granted []string, scope string) bool {
+	for i := range granted {
+		if granted[i] == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// personalAccessToken is the prefix, token id and secret joined by a dot
+func personalAccessToken(id, secret string) string {
+	return personalAccessTokenPrefix + id + "." + secret
+}
+
+func parsePersonalAccessToken(token string) (id, secret string, err error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, personalAccessTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", errors.New("malformed personal access token")
+	}
+
+	return id, secret, nil
+}
+
+// authenticatePersonalAccessToken checks a personal access token, and adds current user and the token to ctx
+func (h *handler) authenticatePersonalAccessToken(ctx context.Context, tokenStr string) (context.Context, error) {
+	id, secret, err := parsePersonalAccessToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := h.personalAccessTokenRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.TokenHash), []byte(hashSecret(secret))) != 1 {
+		return nil, errors.New("invalid personal access token")
+	}
+
+	user, err := h.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// last use is tracked by the minute, so every request does not write
+	now := time.Now()
+	if now.Sub(token.LastUsedAt) >= time.Minute {
+		_ = h.personalAccessTokenRepo.UpdateLastUsedAtByID(token.ID, now)
+		token.LastUsedAt = now
+	}
+
+	ctx = context.WithValue(ctx, currentUserCtx, user)
+	ctx = context.WithValue(ctx, currentPersonalAccessTokenCtx, token)
+
+	return ctx, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// createToken creates a personal access token named name with scopes by token, returning it with its secret
+func (api *testAPI) createToken(token, name string, scopes ...string) PersonalAccessToken {
+	api.t.Helper()
+
+	body := map[string]interface{}{"personalAccessToken": map[string]interface{}{"name": name, "scopes": scopes}}
+
+	w := api.request(http.MethodPost, "/user/tokens", token, body)
+	api.expect(w, http.StatusCreated)
+
+	var res SinglePersonalAccessTokenResponse
+	api.decode(w, &res)
+
+	return res.PersonalAccessToken
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	created := api.createToken(alice.Token, "ci", ScopeRead, ScopeWriteArticles)
+
+	if !strings.HasPrefix(created.Token, personalAccessTokenPrefix) {
+		t.Fatalf("expected token with prefix '%s', but got '%s'", personalAccessTokenPrefix, created.Token)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		body := map[string]interface{}{"personalAccessToken": map[string]interface{}{"name": "ci", "scopes": []string{"admin"}}}
+
+		api.expect(api.request(http.MethodPost, "/user/tokens", alice.Token, body), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w := api.request(http.MethodGet, "/user/tokens", alice.Token, nil)
+		api.expect(w, http.StatusOK)
+
+		var res MultiplePersonalAccessTokensResponse
+		api.decode(w, &res)
+
+		// the secret is shown on creation only
+		if len(res.PersonalAccessTokens) != 1 || res.PersonalAccessTokens[0].ID != created.ID || res.PersonalAccessTokens[0].Token != "" {
+			t.Errorf("expected token '%s' without secret, but got %+v", created.ID, res.PersonalAccessTokens)
+		}
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		api.expect(api.request(http.MethodGet, "/user", created.Token, nil), http.StatusOK)
+		api.createArticle(created.Token, "By Token")
+
+		// a wrong secret of a known token is rejected
+		api.expect(api.request(http.MethodGet, "/user", created.Token+"x", nil), http.StatusUnauthorized)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user/tokens/"+created.ID, alice.Token, nil), http.StatusNoContent)
+		api.expect(api.request(http.MethodDelete, "/user/tokens/"+created.ID, alice.Token, nil), http.StatusNotFound)
+		api.expect(api.request(http.MethodGet, "/user", created.Token, nil), http.StatusUnauthorized)
+	})
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+	slug := api.createArticle(alice.Token, "How To")
+
+	read := api.createToken(alice.Token, "read", ScopeRead)
+	article := map[string]interface{}{"article": map[string]interface{}{"title": "By Token", "description": "description", "body": "body"}}
+
+	t.Run("Read", func(t *testing.T) {
+		api.expect(api.request(http.MethodGet, "/user", read.Token, nil), http.StatusOK)
+		api.expect(api.request(http.MethodGet, "/articles/feed", read.Token, nil), http.StatusOK)
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/articles", read.Token, article), http.StatusForbidden)
+		api.expect(api.request(http.MethodDelete, "/articles/"+slug, read.Token, nil), http.StatusForbidden)
+		api.expect(api.request(http.MethodPost, "/articles/"+slug+"/favorite", read.Token, nil), http.StatusForbidden)
+		api.expect(api.request(http.MethodPost, "/profiles/bob/follow", read.Token, nil), http.StatusForbidden)
+	})
+
+	t.Run("Session Only", func(t *testing.T) {
+		// tokens can not take over the account, even with every scope
+		all := api.createToken(alice.Token, "all", scopes...)
+
+		for _, tc := range []struct {
+			method, path string
+			body         interface{}
+		}{
+			{http.MethodPut, "/user", map[string]interface{}{"user": map[string]interface{}{"bio": "bio"}}},
+			{http.MethodDelete, "/user", deleteAccount(testPassword, "")},
+			{http.MethodGet, "/user/tokens", nil},
+			{http.MethodPost, "/user/tokens", map[string]interface{}{"personalAccessToken": map[string]interface{}{"name": "more", "scopes": scopes}}},
+			{http.MethodGet, "/user/sessions", nil},
+			{http.MethodPost, "/user/2fa/totp", nil},
+			{http.MethodPost, "/users/logout", nil},
+		} {
+			if w := api.request(tc.method, tc.path, all.Token, tc.body); w.Code != http.StatusForbidden {
+				t.Errorf("%s %s: expected status %d, but got %d", tc.method, tc.path, http.StatusForbidden, w.Code)
+			}
+		}
+
+		api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusOK)
+	})
+
+	t.Run("Token Of Another User", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user/tokens/"+read.ID, bob.Token, nil), http.StatusNotFound)
+		api.expect(api.request(http.MethodGet, "/user", read.Token, nil), http.StatusOK)
+	})
+}
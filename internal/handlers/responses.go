@@ -3,11 +3,14 @@ package handlers
 const dateLayout = "2006-01-02T15:04:05.999Z"
 
 type User struct {
-	Email    string `json:"email"`
-	Token    string `json:"token"`
-	Username string `json:"username"`
-	Bio      string `json:"bio"`
-	Image    string `json:"image"`
+	Email        string `json:"email"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Username     string `json:"username"`
+	Bio          string `json:"bio"`
+	Image        string `json:"image"`
+	Role         string `json:"role,omitempty"`
+	Verified     bool   `json:"verified"`
 }
 
 type UserResponse struct {
@@ -74,6 +77,85 @@ type ListOfTagsResponse struct {
 	Tags []string `json:"tags"`
 }
 
+type AdminUser struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Verified bool   `json:"verified"`
+}
+
+type AdminUserResponse struct {
+	User AdminUser `json:"user"`
+}
+
+type PersonalAccessToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Token      string   `json:"token,omitempty"`
+	CreatedAt  string   `json:"createdAt"`
+	LastUsedAt *string  `json:"lastUsedAt"`
+}
+
+type SinglePersonalAccessTokenResponse struct {
+	PersonalAccessToken PersonalAccessToken `json:"personalAccessToken"`
+}
+
+type MultiplePersonalAccessTokensResponse struct {
+	PersonalAccessTokens []PersonalAccessToken `json:"personalAccessTokens"`
+}
+
+type Session struct {
+	ID         string `json:"id"`
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	Current    bool   `json:"current"`
+}
+
+type MultipleSessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPEnrollmentResponse struct {
+	TOTP TOTPEnrollment `json:"totp"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorChallenge struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type TwoFactorChallengeResponse struct {
+	Challenge TwoFactorChallenge `json:"challenge"`
+}
+
+type Lockout struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Subject   string `json:"subject"`
+	Failures  int    `json:"failures"`
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt"`
+	Active    bool   `json:"active"`
+}
+
+type MultipleLockoutsResponse struct {
+	Lockouts      []Lockout `json:"lockouts"`
+	LockoutsCount int       `json:"lockoutsCount"`
+}
+
 type ErrorResponse struct {
 	Errors map[string]interface{} `json:"errors"`
 }
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+// configuredRole is the least role of user, which is admin for verified admin emails
+func (h *handler) configuredRole(user models.User) models.Role {
+	if user.Verified && h.adminEmails[user.Email] {
+		return models.RoleAdmin
+	}
+
+	return models.RoleUser
+}
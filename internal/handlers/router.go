@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// router matches requests to handlers by method and path, walking a trie of path segments.
+// Patterns are like /articles/{slug:[\w-]+}/comments/{id:int}, where a parameter matches a whole segment,
+// by its regular expression if given, or as a non-negative int by type int, which it is converted to.
+type router struct {
+	root routeNode
+	// options answers OPTIONS requests of paths without an OPTIONS route, after setting their Allow header
+	options http.HandlerFunc
+	// notFound answers requests no route matches, and methodNotAllowed ones of methods routes of their path do not allow,
+	// after setting their Allow header. They respond plain text if nil, like http.ServeMux.
+	notFound         http.HandlerFunc
+	methodNotAllowed http.HandlerFunc
+}
+
+type routeNode struct {
+	// static children are tried before the param child, and the param child if they do not match the rest of path
+	static map[string]*routeNode
+	param  *routeNode
+	// paramName, paramExpr and paramPattern compiled of it are of the param child
+	paramName    string
+	paramExpr    string
+	paramPattern *regexp.Regexp
+	// paramConvert converts the segment to the type of the param child, failing to match it if it can not
+	paramConvert func(segment string) (interface{}, bool)
+	handlers     map[string]http.HandlerFunc
+}
+
+// routeMatch is a node matching a path, with parameters of the path
+type routeMatch struct {
+	node   *routeNode
+	params pathParams
+}
+
+// pathParams are parameters of path of a request by name, converted to types of their route pattern
+type pathParams map[string]interface{}
+
+const pathParamsCtx contextKey = "path_params"
+
+// PathParam returns string parameter name of path of r, panicking if its route pattern has no such parameter
+func PathParam(r *http.Request, name string) string {
+	return r.Context().Value(pathParamsCtx).(pathParams)[name].(string)
+}
+
+// PathParamInt returns int parameter name of path of r, panicking if its route pattern has no such parameter of type int
+func PathParamInt(r *http.Request, name string) int {
+	return r.Context().Value(pathParamsCtx).(pathParams)[name].(int)
+}
+
+// paramTypes are types of route pattern params, converting segments they match
+var paramTypes = map[string]func(segment string) (interface{}, bool){
+	"int": func(segment string) (interface{}, bool) {
+		// digits only, since Atoi accepts signs too
+		if strings.Trim(segment, "0123456789") != "" {
+			return nil, false
+		}
+
+		v, err := strconv.Atoi(segment)
+
+		return v, err == nil
+	},
+}
+
+// handle registers handler for method and pattern, panicking on invalid or conflicting ones, like http.ServeMux
+func (rt *router) handle(method, pattern string, handler http.HandlerFunc) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("route pattern '%s' does not start with /", pattern))
+	}
+
+	node := &rt.root
+
+	for _, segment := range strings.Split(pattern[1:], "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			if node.static == nil {
+				node.static = map[string]*routeNode{}
+			}
+
+			if node.static[segment] == nil {
+				node.static[segment] = &routeNode{}
+			}
+
+			node = node.static[segment]
+
+			continue
+		}
+
+		name, expr, _ := strings.Cut(segment[1:len(segment)-1], ":")
+		if name == "" {
+			panic(fmt.Sprintf("route pattern '%s' has a param without name", pattern))
+		}
+
+		var paramPattern *regexp.Regexp
+
+		paramConvert, ok := paramTypes[expr]
+		if !ok && expr != "" {
+			paramPattern = regexp.MustCompile("^(?:" + expr + ")$")
+		}
+
+		if node.param == nil {
+			node.param = &routeNode{}
+			node.paramName = name
+			node.paramExpr = expr
+			node.paramPattern = paramPattern
+			node.paramConvert = paramConvert
+		} else if node.paramName != name || node.paramExpr != expr {
+			panic(fmt.Sprintf("route pattern '%s' conflicts with param '%s' of another route", pattern, node.paramName))
+		}
+
+		node = node.param
+	}
+
+	if node.handlers == nil {
+		node.handlers = map[string]http.HandlerFunc{}
+	}
+
+	if _, ok := node.handlers[method]; ok {
+		panic(fmt.Sprintf("route %s %s is already registered", method, pattern))
+	}
+
+	node.handlers[method] = handler
+}
+
+// match returns nodes with handlers matching path, in order of priority
+func (rt *router) match(path string) []routeMatch {
+	if !strings.HasPrefix(path, "/") {
+		return nil
+	}
+
+	var res []routeMatch
+
+	rt.root.match(strings.Split(path[1:], "/"), pathParams{}, &res)
+
+	return res
+}
+
+func (node *routeNode) match(segments []string, params pathParams, res *[]routeMatch) {
+	if len(segments) == 0 {
+		if len(node.handlers) > 0 {
+			matched := make(pathParams, len(params))
+			for k, v := range params {
+				matched[k] = v
+			}
+
+			*res = append(*res, routeMatch{node: node, params: matched})
+		}
+
+		return
+	}
+
+	segment, rest := segments[0], segments[1:]
+
+	if child, ok := node.static[segment]; ok {
+		child.match(rest, params, res)
+	}
+
+	if node.param == nil || segment == "" || (node.paramPattern != nil && !node.paramPattern.MatchString(segment)) {
+		return
+	}
+
+	var value interface{} = segment
+
+	if node.paramConvert != nil {
+		converted, ok := node.paramConvert(segment)
+		if !ok {
+			return
+		}
+
+		value = converted
+	}
+
+	params[node.paramName] = value
+	node.param.match(rest, params, res)
+	delete(params, node.paramName)
+}
+
+// allowedMethods returns sorted methods of matches, with HEAD for GET and OPTIONS always
+func allowedMethods(matches []routeMatch) []string {
+	set := map[string]bool{http.MethodOptions: true}
+
+	for _, m := range matches {
+		for method := range m.node.handlers {
+			set[method] = true
+
+			if method == http.MethodGet {
+				set[http.MethodHead] = true
+			}
+		}
+	}
+
+	res := make([]string, 0, len(set))
+	for method := range set {
+		res = append(res, method)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// lookup returns handler of method for path and its parameters, and methods allowed on path.
+// HEAD falls back to GET handlers, whose body the server discards.
+func (rt *router) lookup(method, path string) (http.HandlerFunc, pathParams, []string) {
+	matches := rt.match(path)
+	if len(matches) == 0 {
+		return nil, nil, nil
+	}
+
+	for _, m := range matches {
+		if handler, ok := m.node.handlers[method]; ok {
+			return handler, m.params, nil
+		}
+	}
+
+	if method == http.MethodHead {
+		for _, m := range matches {
+			if handler, ok := m.node.handlers[http.MethodGet]; ok {
+				return handler, m.params, nil
+			}
+		}
+	}
+
+	return nil, nil, allowedMethods(matches)
+}
+
+// ServeHTTP calls handler of r with its path parameters in its context, or responds 404 if no route matches path of r,
+// or 405 with the Allow header if routes of path do not allow method of r, unless it is OPTIONS
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, params, allow := rt.lookup(r.Method, r.URL.Path)
+	if handler != nil {
+		handler(w, r.WithContext(context.WithValue(r.Context(), pathParamsCtx, params)))
+
+		return
+	}
+
+	if allow == nil {
+		if rt.notFound != nil {
+			rt.notFound(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+
+	if r.Method == http.MethodOptions {
+		if rt.options != nil {
+			rt.options(w, r)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return
+	}
+
+	if rt.methodNotAllowed != nil {
+		rt.methodNotAllowed(w, r)
+	} else {
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *router {
+	var rt router
+
+	for _, route := range []struct {
+		method, pattern string
+	}{
+		{http.MethodGet, "/articles"},
+		{http.MethodPost, "/articles"},
+		{http.MethodGet, "/articles/feed"},
+		{http.MethodGet, "/articles/{slug:[\\w-]+}"},
+		{http.MethodPut, "/articles/{slug:[\\w-]+}"},
+		{http.MethodDelete, "/articles/{slug:[\\w-]+}/comments/{id:int}"},
+		{http.MethodGet, "/profiles/{username}"},
+	} {
+		method, pattern := route.method, route.pattern
+
+		rt.handle(method, pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", method+" "+pattern)
+
+			for name, v := range r.Context().Value(pathParamsCtx).(pathParams) {
+				w.Header().Set("X-Param-"+name, fmt.Sprintf("%T %v", v, v))
+			}
+
+			_, _ = w.Write([]byte("ok"))
+		})
+	}
+
+	return &rt
+}
+
+func TestRouter(t *testing.T) {
+	rt := newTestRouter()
+
+	tt := []struct {
+		method, path     string
+		status           int
+		route, allow     string
+		slug, id, author string
+	}{
+		{method: http.MethodGet, path: "/articles", status: http.StatusOK, route: "GET /articles"},
+		{method: http.MethodPost, path: "/articles", status: http.StatusOK, route: "POST /articles"},
+		{method: http.MethodGet, path: "/articles/feed", status: http.StatusOK, route: "GET /articles/feed"},
+		{method: http.MethodGet, path: "/articles/how-to", status: http.StatusOK, route: "GET /articles/{slug:[\\w-]+}", slug: "string how-to"},
+		// static segments do not hide params of other methods
+		{method: http.MethodPut, path: "/articles/feed", status: http.StatusOK, route: "PUT /articles/{slug:[\\w-]+}", slug: "string feed"},
+		{method: http.MethodDelete, path: "/articles/how-to/comments/1", status: http.StatusOK, route: "DELETE /articles/{slug:[\\w-]+}/comments/{id:int}", slug: "string how-to", id: "int 1"},
+		{method: http.MethodGet, path: "/profiles/alice", status: http.StatusOK, route: "GET /profiles/{username}", author: "string alice"},
+		{method: http.MethodHead, path: "/articles/how-to", status: http.StatusOK, route: "GET /articles/{slug:[\\w-]+}", slug: "string how-to"},
+		{method: http.MethodDelete, path: "/articles", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, POST"},
+		{method: http.MethodDelete, path: "/articles/feed", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, PUT"},
+		{method: http.MethodOptions, path: "/articles/how-to", status: http.StatusNoContent, allow: "GET, HEAD, OPTIONS, PUT"},
+		{method: http.MethodDelete, path: "/articles/how-to/comments/first", status: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/articles/how-to/comments/-1", status: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/articles/how-to/comments/99999999999999999999", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/articles/", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/profiles", status: http.StatusNotFound},
+		{method: http.MethodOptions, path: "/unknown", status: http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, but got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+
+		for header, expected := range map[string]string{
+			"X-Route":          tc.route,
+			"Allow":            tc.allow,
+			"X-Param-slug":     tc.slug,
+			"X-Param-id":       tc.id,
+			"X-Param-username": tc.author,
+		} {
+			if actual := w.Header().Get(header); actual != expected {
+				t.Errorf("%s %s: expected %s '%s', but got '%s'", tc.method, tc.path, header, expected, actual)
+			}
+		}
+	}
+}
+
+func TestRouterOptions(t *testing.T) {
+	rt := newTestRouter()
+	rt.options = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/articles", nil))
+
+	if expected, actual := "GET, HEAD, OPTIONS, POST", w.Header().Get("Access-Control-Allow-Methods"); actual != expected {
+		t.Errorf("expected allowed methods '%s', but got '%s'", expected, actual)
+	}
+}
+
+func TestRouterConflict(t *testing.T) {
+	for name, patterns := range map[string][]string{
+		"Duplicate":          {"/articles", "/articles"},
+		"Param Name":         {"/articles/{slug}", "/articles/{id}/comments"},
+		"Param Pattern":      {"/articles/{id:\\d+}", "/articles/{id:\\w+}/comments"},
+		"Relative Path":      {"articles"},
+		"Param Without Name": {"/articles/{:\\d+}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic on patterns %v, but got none", patterns)
+				}
+			}()
+
+			var rt router
+			for _, pattern := range patterns {
+				rt.handle(http.MethodGet, pattern, func(http.ResponseWriter, *http.Request) {})
+			}
+		})
+	}
+}
+
+func TestPathParam(t *testing.T) {
+	var rt router
+
+	rt.handle(http.MethodDelete, "/articles/{slug}/comments/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		if slug := PathParam(r, "slug"); slug != "how-to" {
+			t.Errorf("expected slug 'how-to', but got '%s'", slug)
+		}
+
+		if id := PathParamInt(r, "id"); id != 42 {
+			t.Errorf("expected id 42, but got %d", id)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/articles/how-to/comments/42", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, but got %d", http.StatusNoContent, w.Code)
+	}
+}
@@ -1,28 +1,65 @@
 package handlers
 
-import "net/http"
+import (
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"net/http"
+)
 
 func (h *handler) registerRoutes() {
 	middlewareAuthentication := h.middlewareAuthentication
+	middlewareScope := h.middlewareScope
+	middlewareSession := h.middlewareSession
+	middlewareAuthorization := h.middlewareAuthorization
+	middlewareVerified := h.middlewareVerified
 
-	h.registerRoute(http.MethodOptions, "^.+$", h.handleCORS())
-	h.registerRoute(http.MethodPost, "^/users/login$", h.handleAuthentication())
-	h.registerRoute(http.MethodPost, "^/users$", h.handleRegistration())
-	h.registerRoute(http.MethodGet, "^/user$", middlewareAuthentication(h.handleGetCurrentUser(), true))
-	h.registerRoute(http.MethodPut, "^/user$", middlewareAuthentication(h.handleUpdateUser(), true))
-	h.registerRoute(http.MethodGet, "^/profiles/(?P<username>[\\w]+)$", middlewareAuthentication(h.handleGetProfile(), false))
-	h.registerRoute(http.MethodPost, "^/profiles/(?P<username>[\\w]+)/follow$", middlewareAuthentication(h.handleFollowUser(), true))
-	h.registerRoute(http.MethodDelete, "^/profiles/(?P<username>[\\w]+)/follow$", middlewareAuthentication(h.handleUnfollowUser(), true))
-	h.registerRoute(http.MethodGet, "^/articles$", middlewareAuthentication(h.handleListArticles(), false))
-	h.registerRoute(http.MethodGet, "^/articles/feed$", middlewareAuthentication(h.handleFeedArticles(), true))
-	h.registerRoute(http.MethodGet, "^/articles/(?P<slug>[\\w-]+)$", h.handleGetArticle())
-	h.registerRoute(http.MethodPost, "^/articles$", middlewareAuthentication(h.handleCreateArticle(), true))
-	h.registerRoute(http.MethodPut, "^/articles/(?P<slug>[\\w-]+)$", middlewareAuthentication(h.handleUpdateArticle(), true))
-	h.registerRoute(http.MethodDelete, "^/articles/(?P<slug>[\\w-]+)$", middlewareAuthentication(h.handleDeleteArticle(), true))
-	h.registerRoute(http.MethodPost, "^/articles/(?P<slug>[\\w-]+)/comments$", middlewareAuthentication(h.handleAddCommentsToAnArticle(), true))
-	h.registerRoute(http.MethodGet, "^/articles/(?P<slug>[\\w-]+)/comments$", middlewareAuthentication(h.handleGetCommentsFromAnArticle(), false))
-	h.registerRoute(http.MethodDelete, "^/articles/(?P<slug>[\\w-]+)/comments/(?P<id>[\\d]+)$", middlewareAuthentication(h.handleDeleteComment(), true))
-	h.registerRoute(http.MethodPost, "^/articles/(?P<slug>[\\w-]+)/favorite$", middlewareAuthentication(h.handleFavoriteArticle(), true))
-	h.registerRoute(http.MethodDelete, "^/articles/(?P<slug>[\\w-]+)/favorite$", middlewareAuthentication(h.handleUnfavoriteArticle(), true))
-	h.registerRoute(http.MethodGet, "^/tags$", h.handleGetTags())
+	h.router.options = h.handleCORS()
+	h.router.notFound = h.handleNotFound()
+	h.router.methodNotAllowed = h.handleMethodNotAllowed()
+
+	h.registerRoute(http.MethodPost, "/users/login", h.handleAuthentication())
+	h.registerRoute(http.MethodPost, "/users/login/2fa", h.handleTwoFactorAuthentication())
+	h.registerRoute(http.MethodPost, "/users", h.handleRegistration())
+
+	if h.oidcProvider != nil {
+		h.registerRoute(http.MethodGet, "/users/oidc/login", h.handleOIDCLogin())
+		h.registerRoute(http.MethodGet, "/users/oidc/callback", h.handleOIDCCallback())
+	}
+
+	h.registerRoute(http.MethodPost, "/users/refresh", h.handleRefreshToken())
+	h.registerRoute(http.MethodPost, "/users/password-reset", h.handleRequestPasswordReset())
+	h.registerRoute(http.MethodPost, "/users/password-reset/confirm", h.handleConfirmPasswordReset())
+	h.registerRoute(http.MethodGet, "/users/verify-email", h.handleVerifyEmail())
+	h.registerRoute(http.MethodPost, "/users/logout", middlewareAuthentication(middlewareSession(h.handleLogout()), true))
+	h.registerRoute(http.MethodGet, "/user", middlewareAuthentication(middlewareScope(h.handleGetCurrentUser(), ScopeRead), true))
+	h.registerRoute(http.MethodPut, "/user", middlewareAuthentication(middlewareSession(h.handleUpdateUser()), true))
+	h.registerRoute(http.MethodDelete, "/user", middlewareAuthentication(middlewareSession(h.handleDeleteUser()), true))
+	h.registerRoute(http.MethodPost, "/user/verification-email", middlewareAuthentication(middlewareSession(h.handleSendVerificationEmail()), true))
+	h.registerRoute(http.MethodPost, "/user/2fa/totp", middlewareAuthentication(middlewareSession(h.handleEnrollTOTP()), true))
+	h.registerRoute(http.MethodPost, "/user/2fa/totp/confirm", middlewareAuthentication(middlewareSession(h.handleConfirmTOTP()), true))
+	h.registerRoute(http.MethodDelete, "/user/2fa/totp", middlewareAuthentication(middlewareSession(h.handleDisableTOTP()), true))
+	h.registerRoute(http.MethodGet, "/user/tokens", middlewareAuthentication(middlewareSession(h.handleListPersonalAccessTokens()), true))
+	h.registerRoute(http.MethodPost, "/user/tokens", middlewareAuthentication(middlewareSession(h.handleCreatePersonalAccessToken()), true))
+	h.registerRoute(http.MethodDelete, "/user/tokens/{id:[\\w-]+}", middlewareAuthentication(middlewareSession(h.handleDeletePersonalAccessToken()), true))
+	h.registerRoute(http.MethodGet, "/user/sessions", middlewareAuthentication(middlewareSession(h.handleListSessions()), true))
+	h.registerRoute(http.MethodDelete, "/user/sessions/{id:[\\w-]+}", middlewareAuthentication(middlewareSession(h.handleDeleteSession()), true))
+	h.registerRoute(http.MethodGet, "/profiles/{username:\\w+}", middlewareAuthentication(middlewareScope(h.handleGetProfile(), ScopeRead), false))
+	h.registerRoute(http.MethodPost, "/profiles/{username:\\w+}/follow", middlewareAuthentication(middlewareScope(h.handleFollowUser(), ScopeWriteProfiles), true))
+	h.registerRoute(http.MethodDelete, "/profiles/{username:\\w+}/follow", middlewareAuthentication(middlewareScope(h.handleUnfollowUser(), ScopeWriteProfiles), true))
+	h.registerRoute(http.MethodGet, "/articles", middlewareAuthentication(middlewareScope(h.handleListArticles(), ScopeRead), false))
+	h.registerRoute(http.MethodGet, "/articles/feed", middlewareAuthentication(middlewareScope(h.handleFeedArticles(), ScopeRead), true))
+	h.registerRoute(http.MethodGet, "/articles/{slug:[\\w-]+}", h.handleGetArticle())
+	h.registerRoute(http.MethodPost, "/articles", middlewareAuthentication(middlewareScope(middlewareVerified(h.handleCreateArticle()), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodPut, "/articles/{slug:[\\w-]+}", middlewareAuthentication(middlewareScope(h.handleUpdateArticle(), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodDelete, "/articles/{slug:[\\w-]+}", middlewareAuthentication(middlewareScope(h.handleDeleteArticle(), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodPost, "/articles/{slug:[\\w-]+}/comments", middlewareAuthentication(middlewareScope(middlewareVerified(h.handleAddCommentsToAnArticle()), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodGet, "/articles/{slug:[\\w-]+}/comments", middlewareAuthentication(middlewareScope(h.handleGetCommentsFromAnArticle(), ScopeRead), false))
+	h.registerRoute(http.MethodDelete, "/articles/{slug:[\\w-]+}/comments/{id:int}", middlewareAuthentication(middlewareScope(h.handleDeleteComment(), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodPost, "/articles/{slug:[\\w-]+}/favorite", middlewareAuthentication(middlewareScope(h.handleFavoriteArticle(), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodDelete, "/articles/{slug:[\\w-]+}/favorite", middlewareAuthentication(middlewareScope(h.handleUnfavoriteArticle(), ScopeWriteArticles), true))
+	h.registerRoute(http.MethodPut, "/admin/users/{username:\\w+}/role", middlewareAuthentication(middlewareSession(middlewareAuthorization(h.handleUpdateUserRole(), models.RoleAdmin)), true))
+	h.registerRoute(http.MethodDelete, "/admin/users/{username:\\w+}", middlewareAuthentication(middlewareSession(middlewareAuthorization(h.handleAdminDeleteUser(), models.RoleAdmin)), true))
+	h.registerRoute(http.MethodGet, "/admin/lockouts", middlewareAuthentication(middlewareSession(middlewareAuthorization(h.handleListLockouts(), models.RoleAdmin)), true))
+	h.registerRoute(http.MethodDelete, "/admin/lockouts/{id:\\w+}", middlewareAuthentication(middlewareSession(middlewareAuthorization(h.handleUnlockLockout(), models.RoleAdmin)), true))
+	h.registerRoute(http.MethodGet, "/tags", h.handleGetTags())
+	h.registerRoute(http.MethodGet, "/.well-known/jwks.json", h.handleJWKS())
 }
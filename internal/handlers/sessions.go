@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	uniqueID "github.com/nasermirzaei89/realworld-go/pkg/id"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// claimSessionID is the private claim of access tokens, holding id of the session they are issued for
+const claimSessionID = "sid"
+
+type accessTokenClaims struct {
+	Subject   string `json:"sub"`
+	JWTID     string `json:"jti"`
+	SessionID string `json:"sid"`
+}
+
+// issueToken signs an access token for user in session that expires after the configured lifetime
+func (h *handler) issueToken(userID int, sessionID string) (string, error) {
+	now := time.Now()
+
+	token := jwt.New()
+	token.SetIssuedAt(now)
+	token.SetNotBefore(now)
+	token.SetExpirationTime(now.Add(h.lifetime))
+
+	err := token.SetClaims(accessTokenClaims{
+		Subject:   strconv.Itoa(userID),
+		JWTID:     uniqueID.New(16),
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return h.keys.Sign(token)
+}
+
+// startSession adds a session for user, started by request r, and returns it with its refresh token.
+// Expired sessions are cleaned up on the way, which is best effort.
+func (h *handler) startSession(r *http.Request, userID int) (*models.Session, string, error) {
+	now := time.Now()
+
+	_ = h.sessionRepo.DeleteExpired(now)
+
+	id, err := randomString(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := models.Session{
+		ID:               id,
+		UserID:           userID,
+		RefreshTokenHash: hashSecret(secret),
+		CreatedAt:        now,
+		RefreshedAt:      now,
+		ExpiresAt:        now.Add(h.refreshLifetime),
+		LastSeenAt:       now,
+		UserAgent:        userAgent(r),
+		IP:               h.clientIP(r),
+	}
+
+	err = h.sessionRepo.Add(session)
+	if err != nil {
+		return nil, "", fmt.Errorf("error on add session: %w", err)
+	}
+
+	return &session, refreshToken(id, secret), nil
+}
+
+// refreshToken is session id and secret joined by a dot, so the session is found without scanning hashes
+func refreshToken(sessionID, secret string) string {
+	return sessionID + "." + secret
+}
+
+func parseRefreshToken(token string) (sessionID, secret string, err error) {
+	sessionID, secret, ok := strings.Cut(token, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", errors.New("malformed refresh token")
+	}
+
+	return sessionID, secret, nil
+}
+
+// seeSession records request r as the last one of session, at most once a minute, which is best effort
+func (h *handler) seeSession(r *http.Request, session *models.Session, now time.Time) {
+	if now.Sub(session.LastSeenAt) < time.Minute {
+		return
+	}
+
+	session.LastSeenAt = now
+	session.UserAgent = userAgent(r)
+	session.IP = h.clientIP(r)
+
+	_ = h.sessionRepo.SeenByID(session.ID, session.LastSeenAt, session.UserAgent, session.IP)
+}
+
+// maxUserAgentLength is the longest user agent kept for a session
+const maxUserAgentLength = 512
+
+// userAgent returns user agent of r, cut to maxUserAgentLength
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLength], "")
+	}
+
+	return ua
+}
+
+// authenticateJWT verifies an access token and its session, and adds current user, token and session to context of r
+func (h *handler) authenticateJWT(r *http.Request, tokenStr string) (context.Context, error) {
+	// verify token, rejecting ones without expiration, which older versions issued for life
+	token, err := h.keys.ParseAndVerify(
+		tokenStr,
+		jwt.WithRequiredClaims(jwt.ClaimExpirationTime, jwt.ClaimSubject, jwt.ClaimJWTID, claimSessionID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims accessTokenClaims
+	err = token.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// reject revoked tokens, e.g. logged out ones
+	revoked, err := h.sessionRepo.IsTokenIDRevoked(claims.JWTID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("token is revoked")
+	}
+
+	// reject tokens of ended sessions, so killing a session kills its access tokens too
+	session, err := h.sessionRepo.GetByID(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("session is expired")
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID != user.ID {
+		return nil, errors.New("session does not belong to token subject")
+	}
+
+	h.seeSession(r, session, time.Now())
+
+	ctx := context.WithValue(r.Context(), currentUserCtx, user)
+	ctx = context.WithValue(ctx, currentTokenCtx, token)
+	ctx = context.WithValue(ctx, currentSessionCtx, session)
+
+	return ctx, nil
+}
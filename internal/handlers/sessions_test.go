@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+// refresh is the body of POST /users/refresh with refreshToken
+func refresh(refreshToken string) map[string]interface{} {
+	return map[string]interface{}{"user": map[string]interface{}{"refreshToken": refreshToken}}
+}
+
+func TestSessions(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	phone := api.login("alice")
+	bob := api.register("bob")
+
+	// list sessions of alice
+	w := api.request(http.MethodGet, "/user/sessions", alice.Token, nil)
+	api.expect(w, http.StatusOK)
+
+	var res MultipleSessionsResponse
+	api.decode(w, &res)
+
+	if len(res.Sessions) != 2 {
+		t.Fatalf("expected 2 sessions, but got %d", len(res.Sessions))
+	}
+
+	var current, other Session
+	for _, session := range res.Sessions {
+		if session.Current {
+			current = session
+		} else {
+			other = session
+		}
+	}
+
+	if current.ID == "" || other.ID == "" {
+		t.Fatalf("expected one current session, but got %+v", res.Sessions)
+	}
+
+	t.Run("Session Of Another User", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user/sessions/"+other.ID, bob.Token, nil), http.StatusNotFound)
+		api.expect(api.request(http.MethodGet, "/user", phone.Token, nil), http.StatusOK)
+	})
+
+	t.Run("Unknown Session", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user/sessions/unknown", alice.Token, nil), http.StatusNotFound)
+	})
+
+	t.Run("Sign Out Remotely", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/user/sessions/"+other.ID, alice.Token, nil), http.StatusNoContent)
+
+		// tokens of the deleted session stop working
+		api.expect(api.request(http.MethodGet, "/user", phone.Token, nil), http.StatusUnauthorized)
+		api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(phone.RefreshToken)), http.StatusUnauthorized)
+
+		// while the current one keeps working
+		api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusOK)
+		api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(alice.RefreshToken)), http.StatusOK)
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	phone := api.login("alice")
+
+	// refresh rotates the refresh token
+	w := api.request(http.MethodPost, "/users/refresh", "", refresh(alice.RefreshToken))
+	api.expect(w, http.StatusOK)
+
+	var res UserResponse
+	api.decode(w, &res)
+
+	if res.User.RefreshToken == "" || res.User.RefreshToken == alice.RefreshToken {
+		t.Fatalf("expected refresh token rotated, but got '%s'", res.User.RefreshToken)
+	}
+
+	api.expect(api.request(http.MethodGet, "/user", res.User.Token, nil), http.StatusOK)
+
+	t.Run("Invalid Refresh Token", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh("invalid")), http.StatusUnauthorized)
+	})
+
+	t.Run("Reused Refresh Token", func(t *testing.T) {
+		api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(alice.RefreshToken)), http.StatusUnauthorized)
+
+		// a reused refresh token means it is stolen, so the whole session is ended
+		api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(res.User.RefreshToken)), http.StatusUnauthorized)
+		api.expect(api.request(http.MethodGet, "/user", res.User.Token, nil), http.StatusUnauthorized)
+		api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusUnauthorized)
+
+		// while other sessions keep working
+		api.expect(api.request(http.MethodGet, "/user", phone.Token, nil), http.StatusOK)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	phone := api.login("alice")
+
+	token, err := api.h.keys.ParseAndVerify(alice.Token)
+	if err != nil {
+		t.Fatalf("error on parse token: %s", err.Error())
+	}
+
+	jti, err := token.GetJWTID()
+	if err != nil {
+		t.Fatalf("error on get jti: %s", err.Error())
+	}
+
+	api.expect(api.request(http.MethodPost, "/users/logout", alice.Token, nil), http.StatusNoContent)
+
+	revoked, err := api.h.sessionRepo.IsTokenIDRevoked(jti)
+	if err != nil {
+		t.Fatalf("error on check revoked token: %s", err.Error())
+	}
+
+	if !revoked {
+		t.Error("expected access token revoked on logout")
+	}
+
+	api.expect(api.request(http.MethodGet, "/user", alice.Token, nil), http.StatusUnauthorized)
+	api.expect(api.request(http.MethodPost, "/users/refresh", "", refresh(alice.RefreshToken)), http.StatusUnauthorized)
+
+	// only the current session is ended
+	api.expect(api.request(http.MethodGet, "/user", phone.Token, nil), http.StatusOK)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	uniqueID "github.com/nasermirzaei89/realworld-go/pkg/id"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errInvalidCredentials is the only error of failed logins, so they do not tell whether an email is registered
+var errInvalidCredentials = errors.New("invalid email or password")
+
+// loginFailureWindow is how long failed logins are remembered after the last one
+const loginFailureWindow = 24 * time.Hour
+
+// loginSubjects are the account and the ip failed logins with email from ip are counted by
+func loginSubjects(email, ip string) []models.LoginThrottle {
+	return []models.LoginThrottle{
+		{Kind: models.LoginThrottleKindAccount, Subject: strings.ToLower(strings.TrimSpace(email))},
+		{Kind: models.LoginThrottleKindIP, Subject: ip},
+	}
+}
+
+// loginLockedUntil returns until when logins with email from ip are locked out, which is zero if they are not
+func (h *handler) loginLockedUntil(email, ip string) (time.Time, error) {
+	var until time.Time
+
+	for _, subject := range loginSubjects(email, ip) {
+		throttle, err := h.loginThrottleRepo.GetByKindAndSubject(subject.Kind, subject.Subject)
+		if err != nil {
+			if errors.As(err, &models.LoginThrottleByKindAndSubjectNotFoundError{}) {
+				continue
+			}
+
+			return time.Time{}, fmt.Errorf("error on get login throttle: %w", err)
+		}
+
+		if throttle.LockedUntil.After(until) {
+			until = throttle.LockedUntil
+		}
+	}
+
+	return until, nil
+}
+
+// recordLoginFailure counts a failed login with email from ip, and locks out the account or the ip
+// once they reach their limit, twice as long for each further failure
+func (h *handler) recordLoginFailure(email, ip string) error {
+	now := time.Now()
+
+	for _, subject := range loginSubjects(email, ip) {
+		throttle, err := h.loginThrottleRepo.AddFailure(subject.Kind, subject.Subject, now, now.Add(-loginFailureWindow))
+		if err != nil {
+			return fmt.Errorf("error on add login failure: %w", err)
+		}
+
+		limit := h.loginMaxFailures
+		if subject.Kind == models.LoginThrottleKindIP {
+			limit = h.loginMaxIPFailures
+		}
+
+		if throttle.Failures < limit {
+			continue
+		}
+
+		lockout := h.loginLockout
+		for i := limit; i < throttle.Failures && lockout < h.loginMaxLockout; i++ {
+			lockout *= 2
+		}
+
+		if lockout > h.loginMaxLockout {
+			lockout = h.loginMaxLockout
+		}
+
+		err = h.loginThrottleRepo.Lock(models.Lockout{
+			ID:        uniqueID.New(16),
+			Kind:      subject.Kind,
+			Subject:   subject.Subject,
+			Failures:  throttle.Failures,
+			CreatedAt: now,
+			ExpiresAt: now.Add(lockout),
+		})
+		if err != nil {
+			return fmt.Errorf("error on lock: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// resetLoginFailures forgets failed logins of the account with email after a successful one.
+// Failures of the ip are kept, so logging in to an own account does not allow guessing more passwords of others.
+func (h *handler) resetLoginFailures(email string) error {
+	subject := loginSubjects(email, "")[0]
+
+	err := h.loginThrottleRepo.DeleteByKindAndSubject(subject.Kind, subject.Subject)
+	if err != nil {
+		return fmt.Errorf("error on delete login throttle: %w", err)
+	}
+
+	return nil
+}
+
+// verifyDummyPassword takes as long as verifying a password, for logins with unknown emails
+func (h *handler) verifyDummyPassword(pass string) {
+	h.dummyPasswordHashOnce.Do(func() {
+		h.dummyPasswordHash, _ = h.hasher.Hash("dummy password")
+	})
+
+	if h.dummyPasswordHash != "" {
+		_, _ = h.hasher.Verify(h.dummyPasswordHash, pass)
+	}
+}
+
+// clientIP returns ip of client of r from the client ip header if set, or the remote address otherwise
+func (h *handler) clientIP(r *http.Request) string {
+	if h.clientIPHeader != "" {
+		values := strings.Split(r.Header.Get(h.clientIPHeader), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// retryAfter is the Retry-After header value of until, in whole seconds rounded up
+func retryAfter(until time.Time) string {
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.Itoa(seconds)
+}
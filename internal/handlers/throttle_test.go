@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// loginWith logs in with email and password, returning the response as it is
+func (api *testAPI) loginWith(email, password string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	return api.request(http.MethodPost, "/users/login", "", map[string]interface{}{"user": map[string]interface{}{"email": email, "password": password}})
+}
+
+// expectLockedOut fails the test if w is not a lockout response
+func (api *testAPI) expectLockedOut(w *httptest.ResponseRecorder) {
+	api.t.Helper()
+
+	api.expect(w, http.StatusTooManyRequests)
+
+	if seconds, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || seconds < 1 {
+		api.t.Errorf("expected Retry-After in seconds, but got '%s'", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	api := newTestAPI(t, WithLoginMaxFailures(3, 100))
+	api.register("alice")
+	api.register("bob")
+
+	for i := 0; i < 3; i++ {
+		api.expect(api.loginWith("alice@example.com", "wrong-password"), http.StatusUnauthorized)
+	}
+
+	// even the right password is rejected while locked out
+	api.expectLockedOut(api.loginWith("alice@example.com", testPassword))
+	api.expectLockedOut(api.loginWith("ALICE@example.com ", testPassword))
+
+	// other accounts are not locked out
+	api.expect(api.loginWith("bob@example.com", testPassword), http.StatusOK)
+}
+
+func TestLoginIPLockout(t *testing.T) {
+	api := newTestAPI(t, WithLoginMaxFailures(100, 3))
+	api.register("alice")
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		api.expect(api.loginWith(email, "wrong-password"), http.StatusUnauthorized)
+	}
+
+	api.expectLockedOut(api.loginWith("alice@example.com", testPassword))
+}
+
+func TestLoginUniformError(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+
+	wrongPassword := api.loginWith("alice@example.com", "wrong-password")
+	unknownEmail := api.loginWith("nobody@example.com", "wrong-password")
+
+	if wrongPassword.Code != http.StatusUnauthorized || unknownEmail.Code != wrongPassword.Code {
+		t.Errorf("expected status %d for both, but got %d and %d", http.StatusUnauthorized, wrongPassword.Code, unknownEmail.Code)
+	}
+
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Errorf("expected same body, but got %s and %s", wrongPassword.Body.String(), unknownEmail.Body.String())
+	}
+}
+
+func TestLoginResetsFailures(t *testing.T) {
+	api := newTestAPI(t, WithLoginMaxFailures(3, 100))
+	api.register("alice")
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 2; i++ {
+			api.expect(api.loginWith("alice@example.com", "wrong-password"), http.StatusUnauthorized)
+		}
+
+		api.expect(api.loginWith("alice@example.com", testPassword), http.StatusOK)
+	}
+}
+
+func TestAdminLockouts(t *testing.T) {
+	api := newTestAPI(t, WithLoginMaxFailures(3, 100))
+	admin := api.register("admin")
+	api.setRole("admin", models.RoleAdmin)
+	bob := api.register("bob")
+	api.register("alice")
+
+	for i := 0; i < 3; i++ {
+		api.expect(api.loginWith("alice@example.com", "wrong-password"), http.StatusUnauthorized)
+	}
+
+	// list
+	w := api.request(http.MethodGet, "/admin/lockouts", admin.Token, nil)
+	api.expect(w, http.StatusOK)
+
+	var res MultipleLockoutsResponse
+	api.decode(w, &res)
+
+	if res.LockoutsCount != 1 || len(res.Lockouts) != 1 {
+		t.Fatalf("expected 1 lockout, but got %+v", res)
+	}
+
+	lockout := res.Lockouts[0]
+	if lockout.Kind != string(models.LoginThrottleKindAccount) || lockout.Subject != "alice@example.com" || lockout.Failures != 3 || !lockout.Active {
+		t.Errorf("unexpected lockout %+v", lockout)
+	}
+
+	t.Run("Not Admin", func(t *testing.T) {
+		api.expect(api.request(http.MethodGet, "/admin/lockouts", bob.Token, nil), http.StatusForbidden)
+		api.expect(api.request(http.MethodDelete, "/admin/lockouts/"+lockout.ID, bob.Token, nil), http.StatusForbidden)
+		api.expectLockedOut(api.loginWith("alice@example.com", testPassword))
+	})
+
+	t.Run("Invalid Page", func(t *testing.T) {
+		api.expect(api.request(http.MethodGet, "/admin/lockouts?offset=-1", admin.Token, nil), http.StatusUnprocessableEntity)
+		api.expect(api.request(http.MethodGet, "/admin/lockouts?limit=-1", admin.Token, nil), http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Unknown Lockout", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/lockouts/unknown", admin.Token, nil), http.StatusNotFound)
+	})
+
+	t.Run("Unlock", func(t *testing.T) {
+		api.expect(api.request(http.MethodDelete, "/admin/lockouts/"+lockout.ID, admin.Token, nil), http.StatusNoContent)
+
+		// failures are forgotten too, so a single failure does not lock out again
+		api.expect(api.loginWith("alice@example.com", "wrong-password"), http.StatusUnauthorized)
+		api.expect(api.loginWith("alice@example.com", testPassword), http.StatusOK)
+
+		w := api.request(http.MethodGet, "/admin/lockouts", admin.Token, nil)
+		api.expect(w, http.StatusOK)
+
+		var res MultipleLockoutsResponse
+		api.decode(w, &res)
+
+		if len(res.Lockouts) != 1 || res.Lockouts[0].Active {
+			t.Errorf("expected lockout kept as an ended record, but got %+v", res.Lockouts)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/pkg/totp"
+	"strings"
+	"time"
+)
+
+// twoFactorChallengeLifetime is how long users have to enter their second factor after their password
+const twoFactorChallengeLifetime = 5 * time.Minute
+
+// recoveryCodeCount is how many recovery codes are issued on enabling two factor authentication
+const recoveryCodeCount = 10
+
+var errInvalidSecondFactor = errors.New("invalid two factor code")
+
+// verifySecondFactor checks code is a TOTP code or a recovery code of twoFactor and uses it up,
+// or returns errInvalidSecondFactor
+func (h *handler) verifySecondFactor(twoFactor models.TwoFactor, code string) error {
+	if isTOTPCode(code) {
+		step, ok, err := totp.Verify(twoFactor.Secret, code, time.Now())
+		if err != nil {
+			return fmt.Errorf("error on verify totp code: %w", err)
+		}
+
+		if !ok {
+			return errInvalidSecondFactor
+		}
+
+		err = h.twoFactorRepo.UseStepByUserID(twoFactor.UserID, step)
+		if err != nil {
+			if errors.As(err, &models.TOTPStepUsedError{}) {
+				return fmt.Errorf("%w: code is already used", errInvalidSecondFactor)
+			}
+
+			return fmt.Errorf("error on use totp step: %w", err)
+		}
+
+		return nil
+	}
+
+	err := h.twoFactorRepo.UseRecoveryCodeByUserID(twoFactor.UserID, hashSecret(normalizeRecoveryCode(code)))
+	if err != nil {
+		if errors.As(err, &models.RecoveryCodeNotFoundError{}) {
+			return errInvalidSecondFactor
+		}
+
+		return fmt.Errorf("error on use recovery code: %w", err)
+	}
+
+	return nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRecoveryCodes returns recovery codes to show to user once, and their hashes to store
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error on read random bytes: %w", err)
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b)[:10])
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashSecret(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces, so codes can be typed as users like
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
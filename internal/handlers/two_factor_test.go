@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"github.com/nasermirzaei89/realworld-go/pkg/totp"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// totpCode returns the totp code of secret at step
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("error on generate totp code: %s", err.Error())
+	}
+
+	return code
+}
+
+// enableTOTP enrolls and confirms totp of user by token with the code of the previous step,
+// leaving codes of the current and the next step usable, and returns the secret and recovery codes
+func (api *testAPI) enableTOTP(token string) (string, []string) {
+	api.t.Helper()
+
+	w := api.request(http.MethodPost, "/user/2fa/totp", token, nil)
+	api.expect(w, http.StatusOK)
+
+	var enrollment TOTPEnrollmentResponse
+	api.decode(w, &enrollment)
+
+	code := totpCode(api.t, enrollment.TOTP.Secret, totp.Step(time.Now())-1)
+
+	w = api.request(http.MethodPost, "/user/2fa/totp/confirm", token, map[string]interface{}{"totp": map[string]interface{}{"code": code}})
+	api.expect(w, http.StatusOK)
+
+	var res RecoveryCodesResponse
+	api.decode(w, &res)
+
+	return enrollment.TOTP.Secret, res.RecoveryCodes
+}
+
+// challenge logs in user with username, expecting a two factor challenge instead of tokens, and returns it
+func (api *testAPI) challenge(username string) string {
+	api.t.Helper()
+
+	body := map[string]interface{}{"user": map[string]interface{}{"email": username + "@example.com", "password": testPassword}}
+
+	w := api.request(http.MethodPost, "/users/login", "", body)
+	api.expect(w, http.StatusOK)
+
+	var res TwoFactorChallengeResponse
+	api.decode(w, &res)
+
+	if res.Challenge.Token == "" {
+		api.t.Fatal("expected two factor challenge on login")
+	}
+
+	return res.Challenge.Token
+}
+
+// secondFactor answers challenge with code
+func (api *testAPI) secondFactor(challenge, code string) *httptest.ResponseRecorder {
+	api.t.Helper()
+
+	return api.request(http.MethodPost, "/users/login/2fa", "", map[string]interface{}{"user": map[string]interface{}{"challenge": challenge, "code": code}})
+}
+
+// totpCodeBody is the body of confirming and disabling totp with code
+func totpCodeBody(code string) map[string]interface{} {
+	return map[string]interface{}{"totp": map[string]interface{}{"code": code}}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	api.expect(api.request(http.MethodPost, "/user/2fa/totp/confirm", alice.Token, totpCodeBody("000000")), http.StatusUnprocessableEntity)
+
+	w := api.request(http.MethodPost, "/user/2fa/totp", alice.Token, nil)
+	api.expect(w, http.StatusOK)
+
+	var enrollment TOTPEnrollmentResponse
+	api.decode(w, &enrollment)
+
+	if enrollment.TOTP.Secret == "" || enrollment.TOTP.URI == "" {
+		t.Fatalf("expected totp secret and uri, but got %+v", enrollment.TOTP)
+	}
+
+	// login does not ask for the second factor until enrollment is confirmed
+	if user := api.login("alice"); user.Token == "" {
+		t.Error("expected tokens on login before confirmation")
+	}
+
+	wrong := totpCode(t, enrollment.TOTP.Secret, totp.Step(time.Now())+10)
+	api.expect(api.request(http.MethodPost, "/user/2fa/totp/confirm", alice.Token, totpCodeBody(wrong)), http.StatusUnprocessableEntity)
+
+	code := totpCode(t, enrollment.TOTP.Secret, totp.Step(time.Now()))
+
+	w = api.request(http.MethodPost, "/user/2fa/totp/confirm", alice.Token, totpCodeBody(code))
+	api.expect(w, http.StatusOK)
+
+	var res RecoveryCodesResponse
+	api.decode(w, &res)
+
+	if len(res.RecoveryCodes) == 0 {
+		t.Error("expected recovery codes on confirmation")
+	}
+
+	api.expect(api.request(http.MethodPost, "/user/2fa/totp/confirm", alice.Token, totpCodeBody(code)), http.StatusUnprocessableEntity)
+	api.challenge("alice")
+}
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	secret, recoveryCodes := api.enableTOTP(alice.Token)
+	step := totp.Step(time.Now())
+
+	t.Run("Challenge Is Single Use", func(t *testing.T) {
+		challenge := api.challenge("alice")
+
+		api.expect(api.secondFactor(challenge, "000000"), http.StatusUnauthorized)
+		api.expect(api.secondFactor(challenge, totpCode(t, secret, step)), http.StatusUnauthorized)
+	})
+
+	t.Run("TOTP Code", func(t *testing.T) {
+		code := totpCode(t, secret, step)
+
+		w := api.secondFactor(api.challenge("alice"), code)
+		api.expect(w, http.StatusOK)
+
+		var res UserResponse
+		api.decode(w, &res)
+
+		api.expect(api.request(http.MethodGet, "/user", res.User.Token, nil), http.StatusOK)
+
+		// codes of the used step and steps before it are rejected
+		api.expect(api.secondFactor(api.challenge("alice"), code), http.StatusUnauthorized)
+		api.expect(api.secondFactor(api.challenge("alice"), totpCode(t, secret, step-1)), http.StatusUnauthorized)
+	})
+
+	t.Run("Recovery Code", func(t *testing.T) {
+		api.expect(api.secondFactor(api.challenge("alice"), recoveryCodes[0]), http.StatusOK)
+		api.expect(api.secondFactor(api.challenge("alice"), recoveryCodes[0]), http.StatusUnauthorized)
+	})
+}
+
+func TestDisableTOTP(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	secret, _ := api.enableTOTP(alice.Token)
+
+	api.expect(api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody("")), http.StatusUnprocessableEntity)
+	api.expect(api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody("000000")), http.StatusUnprocessableEntity)
+	api.challenge("alice")
+
+	code := totpCode(t, secret, totp.Step(time.Now()))
+	api.expect(api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody(code)), http.StatusNoContent)
+
+	if user := api.login("alice"); user.Token == "" {
+		t.Error("expected tokens on login after disabling")
+	}
+
+	api.expect(api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody(code)), http.StatusUnprocessableEntity)
+}
+
+func TestDisableTOTPLockout(t *testing.T) {
+	api := newTestAPI(t, WithLoginMaxFailures(3, 20))
+	alice := api.register("alice")
+	secret, recoveryCodes := api.enableTOTP(alice.Token)
+
+	for i := 0; i < 3; i++ {
+		api.expect(api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody("000000")), http.StatusUnprocessableEntity)
+	}
+
+	// a stolen session can not guess codes, even a valid one is rejected until the lockout ends
+	for _, code := range []string{totpCode(t, secret, totp.Step(time.Now())), recoveryCodes[0]} {
+		w := api.request(http.MethodDelete, "/user/2fa/totp", alice.Token, totpCodeBody(code))
+		api.expect(w, http.StatusTooManyRequests)
+
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("expected Retry-After header")
+		}
+	}
+
+	user, err := api.h.userRepo.GetByUsername("alice")
+	if err != nil {
+		t.Fatalf("error on get user: %s", err.Error())
+	}
+
+	if twoFactor, err := api.h.twoFactorRepo.GetByUserID(user.ID); err != nil || !twoFactor.Enabled {
+		t.Errorf("expected two factor kept, but got error %v", err)
+	}
+}
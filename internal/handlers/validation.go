@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+)
+
+// lengths of request fields, in characters except for passwords, which are in bytes since bcrypt hashes 72 bytes at most
+const (
+	maxUsernameLength    = 32
+	minPasswordLength    = 8
+	maxPasswordLength    = 72
+	maxImageLength       = 2048
+	maxBioLength         = 1000
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	maxArticleBodyLength = 100000
+	maxTagLength         = 32
+	maxTags              = 20
+	maxCommentBodyLength = 10000
+)
+
+// usernamePattern is of usernames reachable by profile routes
+var usernamePattern = regexp.MustCompile(`^\w+$`)
+
+// validationErrors are messages of invalid request fields by field name, responded all at once with 422
+// as {"errors":{"email":["can't be blank"]}}
+type validationErrors map[string][]string
+
+// add adds message to errors of field, once
+func (errs validationErrors) add(field, message string) {
+	for _, v := range errs[field] {
+		if v == message {
+			return
+		}
+	}
+
+	errs[field] = append(errs[field], message)
+}
+
+// response returns errs as errors of ErrorResponse
+func (errs validationErrors) response() map[string]interface{} {
+	res := make(map[string]interface{}, len(errs))
+	for field, messages := range errs {
+		res[field] = messages
+	}
+
+	return res
+}
+
+// required checks value of field is not blank, reporting whether it is not
+func (errs validationErrors) required(field, value string) bool {
+	if value == "" {
+		errs.add(field, "can't be blank")
+
+		return false
+	}
+
+	return true
+}
+
+// length checks value of field has from min to max characters, with max ignored if zero
+func (errs validationErrors) length(field, value string, min, max int) {
+	n := utf8.RuneCountInString(value)
+
+	if n < min {
+		errs.add(field, fmt.Sprintf("is too short (minimum is %d characters)", min))
+	}
+
+	if max > 0 && n > max {
+		errs.add(field, fmt.Sprintf("is too long (maximum is %d characters)", max))
+	}
+}
+
+func (errs validationErrors) email(field, value string) {
+	if errs.required(field, value) && !validEmail(value) {
+		errs.add(field, "is invalid")
+	}
+}
+
+func (errs validationErrors) username(field, value string) {
+	if !errs.required(field, value) {
+		return
+	}
+
+	errs.length(field, value, 1, maxUsernameLength)
+
+	if !usernamePattern.MatchString(value) {
+		errs.add(field, "is invalid")
+	}
+}
+
+func (errs validationErrors) password(field, value string) {
+	if !errs.required(field, value) {
+		return
+	}
+
+	errs.length(field, value, minPasswordLength, 0)
+
+	if len(value) > maxPasswordLength {
+		errs.add(field, fmt.Sprintf("is too long (maximum is %d bytes)", maxPasswordLength))
+	}
+}
+
+// image checks value of field is empty, or an absolute http or https url
+func (errs validationErrors) image(field, value string) {
+	if value == "" {
+		return
+	}
+
+	errs.length(field, value, 0, maxImageLength)
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(field, "is invalid")
+	}
+}
+
+// text checks value of field is not blank if required, and has max characters at most
+func (errs validationErrors) text(field, value string, required bool, max int) {
+	if required && !errs.required(field, value) {
+		return
+	}
+
+	errs.length(field, value, 0, max)
+}
+
+func (errs validationErrors) tags(field string, tags []string) {
+	if len(tags) > maxTags {
+		errs.add(field, fmt.Sprintf("is too long (maximum is %d tags)", maxTags))
+	}
+
+	for _, tag := range tags {
+		if tag == "" {
+			errs.add(field, "can't have blank tags")
+
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			errs.add(field, fmt.Sprintf("can't have tags longer than %d characters", maxTagLength))
+		}
+	}
+}
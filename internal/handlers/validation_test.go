@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrors(t *testing.T) {
+	tt := map[string]struct {
+		validate func(errs validationErrors)
+		expected validationErrors
+	}{
+		"Valid": {
+			validate: func(errs validationErrors) {
+				errs.email("email", "alice@example.com")
+				errs.username("username", "alice_1")
+				errs.password("password", "password123")
+				errs.image("image", "https://example.com/alice.png")
+				errs.image("image", "")
+				errs.text("bio", "", false, maxBioLength)
+				errs.tags("tagList", []string{"go", "web"})
+			},
+			expected: validationErrors{},
+		},
+		"Blank": {
+			validate: func(errs validationErrors) {
+				errs.email("email", "")
+				errs.username("username", "")
+				errs.password("password", "")
+				errs.text("title", "", true, maxTitleLength)
+			},
+			expected: validationErrors{
+				"email":    {"can't be blank"},
+				"username": {"can't be blank"},
+				"password": {"can't be blank"},
+				"title":    {"can't be blank"},
+			},
+		},
+		"Invalid": {
+			validate: func(errs validationErrors) {
+				errs.email("email", "Alice <alice@example.com>")
+				errs.username("username", "alice smith")
+				errs.image("image", "javascript:alert(1)")
+				errs.tags("tagList", []string{"", "go", ""})
+			},
+			expected: validationErrors{
+				"email":    {"is invalid"},
+				"username": {"is invalid"},
+				"image":    {"is invalid"},
+				"tagList":  {"can't have blank tags"},
+			},
+		},
+		"Length": {
+			validate: func(errs validationErrors) {
+				errs.username("username", strings.Repeat("a", maxUsernameLength+1))
+				errs.password("password", "short")
+				errs.password("password", strings.Repeat("é", maxPasswordLength/2+1))
+				errs.text("bio", strings.Repeat("é", maxBioLength), false, maxBioLength)
+			},
+			expected: validationErrors{
+				"username": {"is too long (maximum is 32 characters)"},
+				"password": {"is too short (minimum is 8 characters)", "is too long (maximum is 72 bytes)"},
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			errs := validationErrors{}
+			tc.validate(errs)
+
+			if !reflect.DeepEqual(errs, tc.expected) {
+				t.Errorf("expected errors %v, but got %v", tc.expected, errs)
+			}
+		})
+	}
+}
@@ -30,10 +30,21 @@ type ArticleRepository interface {
 	List(offset, limit int, filters ...ArticleFilter) (res []Article, total int, err error)
 	GetBySlug(slug string) (res *Article, err error)
 	Add(entity Article) (err error)
+	// UpdateBySlug updates article fields and tags, favorites and comments are kept as they are
 	UpdateBySlug(slug string, entity Article) (err error)
 	DeleteBySlug(slug string) (err error)
-	NewCommentID() (id int)
+	AddFavoriteBySlug(slug string, userID int) (err error)
+	RemoveFavoriteBySlug(slug string, userID int) (err error)
+	NewCommentID() (id int, err error)
+	AddCommentBySlug(slug string, comment Comment) (err error)
+	DeleteCommentBySlug(slug string, commentID int) (err error)
 	GetTags() (res []string, err error)
+	// DeleteByAuthorID deletes articles and comments of author, and does nothing if there are none
+	DeleteByAuthorID(authorID int) (err error)
+	// TransferByAuthorID makes articles and comments of author ones of newAuthorID, and does nothing if there are none
+	TransferByAuthorID(authorID, newAuthorID int) (err error)
+	// RemoveFavoritesByUserID removes favorites of user from all articles
+	RemoveFavoritesByUserID(userID int) (err error)
 }
 
 type ArticleBySlugNotFoundError struct {
@@ -44,6 +55,15 @@ func (e ArticleBySlugNotFoundError) Error() string {
 	return fmt.Sprintf("article with slug '%s' not found", e.Slug)
 }
 
+type CommentByIDNotFoundError struct {
+	ArticleSlug string
+	ID          int
+}
+
+func (e CommentByIDNotFoundError) Error() string {
+	return fmt.Sprintf("comment with id '%d' in article with slug '%s' not found", e.ID, e.ArticleSlug)
+}
+
 type ArticleFilter func([]Article) []Article
 
 func FilterArticlesByTag(tag string) ArticleFilter {
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Identity links a user to its subject at an external identity provider, e.g. an OpenID provider
+type Identity struct {
+	Issuer    string // unique with subject
+	Subject   string
+	UserID    int // unique with issuer
+	Email     string
+	CreatedAt time.Time
+}
+
+type IdentityRepository interface {
+	Add(entity Identity) (err error)
+	GetByIssuerAndSubject(issuer, subject string) (res *Identity, err error)
+	// DeleteByUserID deletes identities of user, and does nothing if there are none
+	DeleteByUserID(userID int) (err error)
+}
+
+type IdentityByIssuerAndSubjectNotFoundError struct {
+	Issuer  string
+	Subject string
+}
+
+func (e IdentityByIssuerAndSubjectNotFoundError) Error() string {
+	return fmt.Sprintf("identity with subject '%s' of issuer '%s' not found", e.Subject, e.Issuer)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginThrottleKind is what failed logins are counted by
+type LoginThrottleKind string
+
+const (
+	// LoginThrottleKindAccount counts by email given on login, whether it is registered or not
+	LoginThrottleKindAccount LoginThrottleKind = "account"
+	// LoginThrottleKindIP counts by ip of client
+	LoginThrottleKindIP LoginThrottleKind = "ip"
+)
+
+// LoginThrottle counts consecutive failed logins of an account or ip
+type LoginThrottle struct {
+	Kind         LoginThrottleKind // unique with subject
+	Subject      string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time // zero if never locked
+}
+
+// Lockout is a record of an account or ip locked out of login after too many failures
+type Lockout struct {
+	ID        string // unique
+	Kind      LoginThrottleKind
+	Subject   string
+	Failures  int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type LoginThrottleRepository interface {
+	GetByKindAndSubject(kind LoginThrottleKind, subject string) (res *LoginThrottle, err error)
+	// AddFailure counts a failed login of subject at now atomically, starting over if the last one was before resetBefore
+	AddFailure(kind LoginThrottleKind, subject string, now, resetBefore time.Time) (res *LoginThrottle, err error)
+	// Lock locks the subject of lockout until it expires, and records the lockout
+	Lock(lockout Lockout) (err error)
+	// DeleteByKindAndSubject forgets failures of subject, e.g. after a successful login, and does nothing if there are none
+	DeleteByKindAndSubject(kind LoginThrottleKind, subject string) (err error)
+	// ListLockouts lists lockouts, latest first
+	ListLockouts(offset, limit int) (res []Lockout, total int, err error)
+	// Unlock ends active lockouts of the subject of lockout with id at now, and forgets failures of the subject
+	Unlock(id string, now time.Time) (err error)
+}
+
+type LoginThrottleByKindAndSubjectNotFoundError struct {
+	Kind    LoginThrottleKind
+	Subject string
+}
+
+func (e LoginThrottleByKindAndSubjectNotFoundError) Error() string {
+	return fmt.Sprintf("login throttle of %s '%s' not found", e.Kind, e.Subject)
+}
+
+type LockoutByIDNotFoundError struct {
+	ID string
+}
+
+func (e LockoutByIDNotFoundError) Error() string {
+	return fmt.Sprintf("lockout with id '%s' not found", e.ID)
+}
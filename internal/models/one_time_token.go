@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TokenPurpose is what a one time token can be used for, so a token issued for one purpose can not be used for another
+type TokenPurpose string
+
+const (
+	TokenPurposePasswordReset TokenPurpose = "password_reset"
+	// TokenPurposeTwoFactorChallenge is of tokens proving the password is checked, waiting for the second factor
+	TokenPurposeTwoFactorChallenge TokenPurpose = "two_factor_challenge"
+)
+
+// OneTimeToken is a secret given to user, e.g. by email, which can be used once before it expires
+type OneTimeToken struct {
+	ID        string // unique
+	UserID    int
+	Purpose   TokenPurpose
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type OneTimeTokenRepository interface {
+	Add(entity OneTimeToken) (err error)
+	GetByID(id string) (res *OneTimeToken, err error)
+	// DeleteByID uses up the token, so it fails with not found error for all but one of concurrent calls
+	DeleteByID(id string) (err error)
+	// DeleteByUserID deletes tokens of user issued for purpose
+	DeleteByUserID(userID int, purpose TokenPurpose) (err error)
+	// DeleteExpired deletes tokens expired before now
+	DeleteExpired(now time.Time) (err error)
+}
+
+type OneTimeTokenByIDNotFoundError struct {
+	ID string
+}
+
+func (e OneTimeTokenByIDNotFoundError) Error() string {
+	return fmt.Sprintf("one time token with id '%s' not found", e.ID)
+}
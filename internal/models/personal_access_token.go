@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// PersonalAccessToken authenticates scripts as its user with its scopes only
+type PersonalAccessToken struct {
+	ID         string // unique
+	UserID     int
+	Name       string // unique per user
+	Scopes     []string
+	TokenHash  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time // zero if never used
+}
+
+type PersonalAccessTokenRepository interface {
+	Add(entity PersonalAccessToken) (err error)
+	GetByID(id string) (res *PersonalAccessToken, err error)
+	// ListByUserID lists tokens of user in order of creation
+	ListByUserID(userID int) (res []PersonalAccessToken, err error)
+	UpdateLastUsedAtByID(id string, lastUsedAt time.Time) (err error)
+	DeleteByID(id string) (err error)
+}
+
+type PersonalAccessTokenByIDNotFoundError struct {
+	ID string
+}
+
+func (e PersonalAccessTokenByIDNotFoundError) Error() string {
+	return fmt.Sprintf("personal access token with id '%s' not found", e.ID)
+}
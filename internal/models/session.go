@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type Session struct {
+	ID               string // unique
+	UserID           int
+	RefreshTokenHash string
+	CreatedAt        time.Time
+	RefreshedAt      time.Time
+	ExpiresAt        time.Time
+	// LastSeenAt, UserAgent and IP are of the last request of the session, tracked by the minute
+	LastSeenAt time.Time
+	UserAgent  string
+	IP         string
+}
+
+type SessionRepository interface {
+	Add(entity Session) (err error)
+	GetByID(id string) (res *Session, err error)
+	// ListByUserID lists sessions of user, latest seen first
+	ListByUserID(userID int) (res []Session, err error)
+	// RefreshByID updates refresh token hash and times only if the stored hash is still refreshTokenHash,
+	// so each refresh token can be used once
+	RefreshByID(id, refreshTokenHash string, entity Session) (err error)
+	// SeenByID updates last seen time, user agent and ip of session
+	SeenByID(id string, lastSeenAt time.Time, userAgent, ip string) (err error)
+	DeleteByID(id string) (err error)
+	// DeleteByUserID deletes all sessions of user, e.g. to log them out everywhere
+	DeleteByUserID(userID int) (err error)
+	// DeleteExpired deletes sessions and revoked token ids expired before now
+	DeleteExpired(now time.Time) (err error)
+	// RevokeTokenID marks the access token with jti as revoked until it expires at expiresAt
+	RevokeTokenID(jti string, expiresAt time.Time) (err error)
+	IsTokenIDRevoked(jti string) (revoked bool, err error)
+}
+
+type SessionByIDNotFoundError struct {
+	ID string
+}
+
+func (e SessionByIDNotFoundError) Error() string {
+	return fmt.Sprintf("session with id '%s' not found", e.ID)
+}
+
+type RefreshTokenMismatchError struct {
+	SessionID string
+}
+
+func (e RefreshTokenMismatchError) Error() string {
+	return fmt.Sprintf("refresh token of session with id '%s' does not match", e.SessionID)
+}
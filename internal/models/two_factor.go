@@ -0,0 +1,49 @@
+package models
+
+import "fmt"
+
+// TwoFactor is the TOTP second factor of a user
+type TwoFactor struct {
+	UserID  int // unique
+	Secret  string
+	Enabled bool // false until user confirms enrollment with a code
+	// LastUsedStep is the time step of the last accepted code, so codes can not be replayed
+	LastUsedStep       int64
+	RecoveryCodeHashes []string // in order of hash
+}
+
+type TwoFactorRepository interface {
+	// Put adds two factor of user or replaces it with its recovery codes
+	Put(entity TwoFactor) (err error)
+	GetByUserID(userID int) (res *TwoFactor, err error)
+	// UseStepByUserID sets last used step to step if it is later, so each code can be used once
+	UseStepByUserID(userID int, step int64) (err error)
+	// UseRecoveryCodeByUserID deletes the recovery code hash of user, so each recovery code can be used once
+	UseRecoveryCodeByUserID(userID int, codeHash string) (err error)
+	DeleteByUserID(userID int) (err error)
+}
+
+type TwoFactorByUserIDNotFoundError struct {
+	UserID int
+}
+
+func (e TwoFactorByUserIDNotFoundError) Error() string {
+	return fmt.Sprintf("two factor of user with id '%d' not found", e.UserID)
+}
+
+type TOTPStepUsedError struct {
+	UserID int
+	Step   int64
+}
+
+func (e TOTPStepUsedError) Error() string {
+	return fmt.Sprintf("code of step '%d' or later is already used by user with id '%d'", e.Step, e.UserID)
+}
+
+type RecoveryCodeNotFoundError struct {
+	UserID int
+}
+
+func (e RecoveryCodeNotFoundError) Error() string {
+	return fmt.Sprintf("recovery code of user with id '%d' not found", e.UserID)
+}
@@ -5,22 +5,60 @@ import "fmt"
 type User struct {
 	ID        int    // unique
 	Email     string // unique
-	Token     string // unique
 	Username  string // unique
 	Password  string
 	Bio       string
 	Image     string
+	Role      Role
+	Verified  bool // whether user proved owning email
 	Followers map[int]bool
 }
 
+// Role of user, which grants permissions of lower roles too
+type Role string
+
+// Roles
+const (
+	RoleUser Role = "user"
+	// RoleModerator can edit and delete any article or comment
+	RoleModerator Role = "moderator"
+	// RoleAdmin can do what moderators do and change roles of users
+	RoleAdmin Role = "admin"
+	// RoleDeleted is of the account standing in as author of content of deleted users, which has no permissions
+	RoleDeleted Role = "deleted"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+
+	return ok
+}
+
+// AtLeast reports whether r has permissions of role, which unknown roles never have
+func (r Role) AtLeast(role Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[role]
+}
+
 type UserRepository interface {
-	NewID() int
+	NewID() (id int, err error)
 	GetByEmail(email string) (res *User, err error)
 	GetByUsername(username string) (res *User, err error)
 	GetByID(id int) (res *User, err error)
 	Add(entity User) error
+	// UpdateByID updates user fields, followers are kept as they are
 	UpdateByID(id int, entity User) (err error)
+	AddFollowerByID(id, followerID int) (err error)
+	RemoveFollowerByID(id, followerID int) (err error)
 	ListByFollowedBy(userID int) (res []User, err error)
+	// DeleteByID deletes user with its follows, of and by them, so their content must be handled before
+	DeleteByID(id int) (err error)
 }
 
 type UserByEmailNotFoundError struct {
@@ -3,9 +3,11 @@ package inmem
 import (
 	"fmt"
 	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sync"
 )
 
 type articleRepo struct {
+	mu            sync.RWMutex
 	articles      []models.Article
 	nextCommentID int
 }
@@ -18,14 +20,28 @@ func NewArticleRepository() models.ArticleRepository {
 }
 
 func (repo *articleRepo) List(offset, limit int, filters ...models.ArticleFilter) ([]models.Article, int, error) {
+	repo.mu.RLock()
 	res := make([]models.Article, len(repo.articles))
-	copy(res, repo.articles)
+	for i := range repo.articles {
+		res[i] = copyArticle(repo.articles[i])
+	}
+	repo.mu.RUnlock()
+
 	for _, filter := range filters {
 		res = filter(res)
 	}
 
 	total := len(res)
 
+	// negative offset and limit are of bad requests, so they are clamped instead of panicking on slicing
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit < 0 {
+		limit = 0
+	}
+
 	if offset > total {
 		offset = total
 	}
@@ -38,28 +54,38 @@ func (repo *articleRepo) List(offset, limit int, filters ...models.ArticleFilter
 }
 
 func (repo *articleRepo) GetBySlug(slug string) (*models.Article, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	for _, article := range repo.articles {
 		if article.Slug == slug {
+			article = copyArticle(article)
 			return &article, nil
 		}
 	}
 
-	return nil, &models.ArticleBySlugNotFoundError{Slug: slug}
+	return nil, models.ArticleBySlugNotFoundError{Slug: slug}
 }
 
 func (repo *articleRepo) Add(entity models.Article) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	for _, article := range repo.articles {
 		if article.Slug == entity.Slug {
 			return fmt.Errorf("article with slug '%s' already exists", entity.Slug)
 		}
 	}
 
-	repo.articles = append(repo.articles, entity)
+	repo.articles = append(repo.articles, copyArticle(entity))
 
 	return nil
 }
 
 func (repo *articleRepo) UpdateBySlug(slug string, entity models.Article) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	index := -1
 	for i, article := range repo.articles {
 		if article.Slug == slug {
@@ -72,15 +98,20 @@ func (repo *articleRepo) UpdateBySlug(slug string, entity models.Article) error
 	}
 
 	if index == -1 {
-		return &models.ArticleBySlugNotFoundError{Slug: slug}
+		return models.ArticleBySlugNotFoundError{Slug: slug}
 	}
 
-	repo.articles[index] = entity
+	entity.Favorites = repo.articles[index].Favorites
+	entity.Comments = repo.articles[index].Comments
+	repo.articles[index] = copyArticle(entity)
 
 	return nil
 }
 
 func (repo *articleRepo) DeleteBySlug(slug string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	for i, article := range repo.articles {
 		if article.Slug == slug {
 			repo.articles = append(repo.articles[:i], repo.articles[i+1:]...)
@@ -88,15 +119,90 @@ func (repo *articleRepo) DeleteBySlug(slug string) error {
 		}
 	}
 
-	return &models.ArticleBySlugNotFoundError{Slug: slug}
+	return models.ArticleBySlugNotFoundError{Slug: slug}
+}
+
+func (repo *articleRepo) AddFavoriteBySlug(slug string, userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		if repo.articles[i].Slug == slug {
+			repo.articles[i].Favorites[userID] = true
+			return nil
+		}
+	}
+
+	return models.ArticleBySlugNotFoundError{Slug: slug}
+}
+
+func (repo *articleRepo) RemoveFavoriteBySlug(slug string, userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		if repo.articles[i].Slug == slug {
+			delete(repo.articles[i].Favorites, userID)
+			return nil
+		}
+	}
+
+	return models.ArticleBySlugNotFoundError{Slug: slug}
 }
 
-func (repo *articleRepo) NewCommentID() int {
+func (repo *articleRepo) NewCommentID() (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	defer func() { repo.nextCommentID = repo.nextCommentID + 1 }()
-	return repo.nextCommentID
+	return repo.nextCommentID, nil
+}
+
+func (repo *articleRepo) AddCommentBySlug(slug string, comment models.Comment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		if repo.articles[i].Slug == slug {
+			for _, c := range repo.articles[i].Comments {
+				if c.ID == comment.ID {
+					return fmt.Errorf("comment with id '%d' already exists", comment.ID)
+				}
+			}
+
+			repo.articles[i].Comments = append(repo.articles[i].Comments, comment)
+			return nil
+		}
+	}
+
+	return models.ArticleBySlugNotFoundError{Slug: slug}
+}
+
+func (repo *articleRepo) DeleteCommentBySlug(slug string, commentID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		if repo.articles[i].Slug == slug {
+			comments := repo.articles[i].Comments
+			for j := range comments {
+				if comments[j].ID == commentID {
+					repo.articles[i].Comments = append(comments[:j], comments[j+1:]...)
+					return nil
+				}
+			}
+
+			return models.CommentByIDNotFoundError{ArticleSlug: slug, ID: commentID}
+		}
+	}
+
+	return models.ArticleBySlugNotFoundError{Slug: slug}
 }
 
 func (repo *articleRepo) GetTags() ([]string, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	res := make([]string, 0)
 	keys := make(map[string]bool)
 	for _, article := range repo.articles {
@@ -110,3 +216,74 @@ func (repo *articleRepo) GetTags() ([]string, error) {
 
 	return res, nil
 }
+
+func (repo *articleRepo) DeleteByAuthorID(authorID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	articles := make([]models.Article, 0, len(repo.articles))
+	for _, article := range repo.articles {
+		if article.AuthorID == authorID {
+			continue
+		}
+
+		comments := make([]models.Comment, 0, len(article.Comments))
+		for _, comment := range article.Comments {
+			if comment.AuthorID != authorID {
+				comments = append(comments, comment)
+			}
+		}
+
+		article.Comments = comments
+		articles = append(articles, article)
+	}
+
+	repo.articles = articles
+
+	return nil
+}
+
+func (repo *articleRepo) TransferByAuthorID(authorID, newAuthorID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		if repo.articles[i].AuthorID == authorID {
+			repo.articles[i].AuthorID = newAuthorID
+		}
+
+		for j := range repo.articles[i].Comments {
+			if repo.articles[i].Comments[j].AuthorID == authorID {
+				repo.articles[i].Comments[j].AuthorID = newAuthorID
+			}
+		}
+	}
+
+	return nil
+}
+
+func (repo *articleRepo) RemoveFavoritesByUserID(userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.articles {
+		delete(repo.articles[i].Favorites, userID)
+	}
+
+	return nil
+}
+
+// copyArticle returns a copy of article that shares no mutable state with it
+func copyArticle(article models.Article) models.Article {
+	article.Tags = append(make([]string, 0, len(article.Tags)), article.Tags...)
+	article.Comments = append(make([]models.Comment, 0, len(article.Comments)), article.Comments...)
+
+	favorites := make(map[int]bool, len(article.Favorites))
+	for k, v := range article.Favorites {
+		favorites[k] = v
+	}
+
+	article.Favorites = favorites
+
+	return article
+}
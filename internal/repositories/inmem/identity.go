@@ -0,0 +1,70 @@
+package inmem
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sync"
+)
+
+type identityRepo struct {
+	mu         sync.RWMutex
+	identities []models.Identity
+}
+
+func NewIdentityRepository() models.IdentityRepository {
+	return &identityRepo{
+		identities: make([]models.Identity, 0),
+	}
+}
+
+func (repo *identityRepo) Add(entity models.Identity) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, identity := range repo.identities {
+		if identity.Issuer != entity.Issuer {
+			continue
+		}
+
+		if identity.Subject == entity.Subject {
+			return fmt.Errorf("identity with subject '%s' of issuer '%s' already exists", entity.Subject, entity.Issuer)
+		}
+
+		if identity.UserID == entity.UserID {
+			return fmt.Errorf("identity of user with id '%d' of issuer '%s' already exists", entity.UserID, entity.Issuer)
+		}
+	}
+
+	repo.identities = append(repo.identities, entity)
+
+	return nil
+}
+
+func (repo *identityRepo) GetByIssuerAndSubject(issuer, subject string) (*models.Identity, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, identity := range repo.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+
+	return nil, models.IdentityByIssuerAndSubjectNotFoundError{Issuer: issuer, Subject: subject}
+}
+
+func (repo *identityRepo) DeleteByUserID(userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	identities := make([]models.Identity, 0, len(repo.identities))
+	for _, identity := range repo.identities {
+		if identity.UserID != userID {
+			identities = append(identities, identity)
+		}
+	}
+
+	repo.identities = identities
+
+	return nil
+}
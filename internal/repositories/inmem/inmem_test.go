@@ -0,0 +1,241 @@
+package inmem_test
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/inmem"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/repotest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const goroutines = 50
+
+// run calls fn concurrently from goroutines and waits for all of them
+func run(fn func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	repo := inmem.NewUserRepository()
+
+	ids := make(chan int, goroutines)
+	run(func(i int) {
+		id, err := repo.NewID()
+		if err != nil {
+			t.Errorf("error on new id: %s", err.Error())
+			return
+		}
+
+		ids <- id
+
+		err = repo.Add(models.User{
+			ID:       id,
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Username: fmt.Sprintf("user%d", i),
+		})
+		if err != nil {
+			t.Errorf("error on add user: %s", err.Error())
+		}
+	})
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("id '%d' handed out more than once", id)
+		}
+
+		seen[id] = true
+
+		_, err := repo.GetByID(id)
+		if err != nil {
+			t.Errorf("error on get user '%d': %s", id, err.Error())
+		}
+	}
+
+	// only one of many registrations with the same email should win
+	id, _ := repo.NewID()
+	failures := make(chan error, goroutines)
+	run(func(i int) {
+		failures <- repo.Add(models.User{ID: id + i + 1, Email: "same@example.com", Username: fmt.Sprintf("same%d", i)})
+	})
+	close(failures)
+
+	succeeded := 0
+	for err := range failures {
+		if err == nil {
+			succeeded++
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly one registration with the same email to succeed, but %d did", succeeded)
+	}
+}
+
+func TestConcurrentFollowing(t *testing.T) {
+	repo := inmem.NewUserRepository()
+
+	err := repo.Add(models.User{ID: 1, Email: "author@example.com", Username: "author"})
+	if err != nil {
+		t.Fatalf("error on add user: %s", err.Error())
+	}
+
+	run(func(i int) {
+		err := repo.AddFollowerByID(1, i+2)
+		if err != nil {
+			t.Errorf("error on add follower: %s", err.Error())
+		}
+
+		// readers mutating their copies must not affect the repository
+		user, err := repo.GetByID(1)
+		if err != nil {
+			t.Errorf("error on get user: %s", err.Error())
+			return
+		}
+
+		user.Followers[-i] = true
+	})
+
+	user, err := repo.GetByID(1)
+	if err != nil {
+		t.Fatalf("error on get user: %s", err.Error())
+	}
+
+	if len(user.Followers) != goroutines {
+		t.Errorf("expected %d followers, but got %d", goroutines, len(user.Followers))
+	}
+}
+
+func TestConcurrentFavoriting(t *testing.T) {
+	repo := inmem.NewArticleRepository()
+
+	err := repo.Add(models.Article{Slug: "article", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("error on add article: %s", err.Error())
+	}
+
+	run(func(i int) {
+		err := repo.AddFavoriteBySlug("article", i+1)
+		if err != nil {
+			t.Errorf("error on add favorite: %s", err.Error())
+		}
+
+		_, _, err = repo.List(0, 20, models.FilterArticlesByFavorite(models.User{ID: i + 1}))
+		if err != nil {
+			t.Errorf("error on list articles: %s", err.Error())
+		}
+	})
+
+	article, err := repo.GetBySlug("article")
+	if err != nil {
+		t.Fatalf("error on get article: %s", err.Error())
+	}
+
+	if len(article.Favorites) != goroutines {
+		t.Errorf("expected %d favorites, but got %d", goroutines, len(article.Favorites))
+	}
+
+	run(func(i int) {
+		err := repo.RemoveFavoriteBySlug("article", i+1)
+		if err != nil {
+			t.Errorf("error on remove favorite: %s", err.Error())
+		}
+	})
+
+	article, err = repo.GetBySlug("article")
+	if err != nil {
+		t.Fatalf("error on get article: %s", err.Error())
+	}
+
+	if len(article.Favorites) != 0 {
+		t.Errorf("expected no favorites, but got %d", len(article.Favorites))
+	}
+}
+
+func TestConcurrentCommenting(t *testing.T) {
+	repo := inmem.NewArticleRepository()
+
+	err := repo.Add(models.Article{Slug: "article", AuthorID: 1})
+	if err != nil {
+		t.Fatalf("error on add article: %s", err.Error())
+	}
+
+	run(func(i int) {
+		id, err := repo.NewCommentID()
+		if err != nil {
+			t.Errorf("error on new comment id: %s", err.Error())
+			return
+		}
+
+		err = repo.AddCommentBySlug("article", models.Comment{ID: id, Body: "comment", AuthorID: i + 1, CreatedAt: time.Now()})
+		if err != nil {
+			t.Errorf("error on add comment: %s", err.Error())
+		}
+
+		// article updates must not drop comments added meanwhile
+		article, err := repo.GetBySlug("article")
+		if err != nil {
+			t.Errorf("error on get article: %s", err.Error())
+			return
+		}
+
+		article.Body = fmt.Sprintf("body %d", i)
+		err = repo.UpdateBySlug("article", *article)
+		if err != nil {
+			t.Errorf("error on update article: %s", err.Error())
+		}
+	})
+
+	article, err := repo.GetBySlug("article")
+	if err != nil {
+		t.Fatalf("error on get article: %s", err.Error())
+	}
+
+	if len(article.Comments) != goroutines {
+		t.Fatalf("expected %d comments, but got %d", goroutines, len(article.Comments))
+	}
+
+	run(func(i int) {
+		err := repo.DeleteCommentBySlug("article", article.Comments[i].ID)
+		if err != nil {
+			t.Errorf("error on delete comment: %s", err.Error())
+		}
+	})
+
+	article, err = repo.GetBySlug("article")
+	if err != nil {
+		t.Fatalf("error on get article: %s", err.Error())
+	}
+
+	if len(article.Comments) != 0 {
+		t.Errorf("expected no comments, but got %d", len(article.Comments))
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		return repotest.Repositories{
+			Users:                inmem.NewUserRepository(),
+			Articles:             inmem.NewArticleRepository(),
+			Sessions:             inmem.NewSessionRepository(),
+			PersonalAccessTokens: inmem.NewPersonalAccessTokenRepository(),
+			OneTimeTokens:        inmem.NewOneTimeTokenRepository(),
+			TwoFactors:           inmem.NewTwoFactorRepository(),
+			LoginThrottles:       inmem.NewLoginThrottleRepository(),
+			Identities:           inmem.NewIdentityRepository(),
+		}
+	})
+}
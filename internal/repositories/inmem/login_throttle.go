@@ -0,0 +1,147 @@
+package inmem
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type loginThrottleKey struct {
+	kind    models.LoginThrottleKind
+	subject string
+}
+
+type loginThrottleRepo struct {
+	mu        sync.RWMutex
+	throttles map[loginThrottleKey]models.LoginThrottle
+	lockouts  []models.Lockout
+}
+
+func NewLoginThrottleRepository() models.LoginThrottleRepository {
+	return &loginThrottleRepo{
+		throttles: make(map[loginThrottleKey]models.LoginThrottle),
+		lockouts:  make([]models.Lockout, 0),
+	}
+}
+
+func (repo *loginThrottleRepo) GetByKindAndSubject(kind models.LoginThrottleKind, subject string) (*models.LoginThrottle, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	throttle, ok := repo.throttles[loginThrottleKey{kind: kind, subject: subject}]
+	if !ok {
+		return nil, models.LoginThrottleByKindAndSubjectNotFoundError{Kind: kind, Subject: subject}
+	}
+
+	return &throttle, nil
+}
+
+func (repo *loginThrottleRepo) AddFailure(kind models.LoginThrottleKind, subject string, now, resetBefore time.Time) (*models.LoginThrottle, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := loginThrottleKey{kind: kind, subject: subject}
+
+	throttle, ok := repo.throttles[key]
+	if !ok {
+		throttle = models.LoginThrottle{Kind: kind, Subject: subject}
+	}
+
+	if throttle.LastFailedAt.Before(resetBefore) {
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailedAt = now
+	repo.throttles[key] = throttle
+
+	return &throttle, nil
+}
+
+func (repo *loginThrottleRepo) Lock(lockout models.Lockout) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.lockouts {
+		if repo.lockouts[i].ID == lockout.ID {
+			return fmt.Errorf("lockout with id '%s' already exists", lockout.ID)
+		}
+	}
+
+	key := loginThrottleKey{kind: lockout.Kind, subject: lockout.Subject}
+
+	throttle, ok := repo.throttles[key]
+	if !ok {
+		throttle = models.LoginThrottle{Kind: lockout.Kind, Subject: lockout.Subject, Failures: lockout.Failures, LastFailedAt: lockout.CreatedAt}
+	}
+
+	throttle.LockedUntil = lockout.ExpiresAt
+	repo.throttles[key] = throttle
+
+	repo.lockouts = append(repo.lockouts, lockout)
+
+	return nil
+}
+
+func (repo *loginThrottleRepo) DeleteByKindAndSubject(kind models.LoginThrottleKind, subject string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.throttles, loginThrottleKey{kind: kind, subject: subject})
+
+	return nil
+}
+
+func (repo *loginThrottleRepo) ListLockouts(offset, limit int) ([]models.Lockout, int, error) {
+	repo.mu.RLock()
+	res := make([]models.Lockout, len(repo.lockouts))
+	copy(res, repo.lockouts)
+	repo.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.After(res[j].CreatedAt)
+		}
+
+		return res[i].ID < res[j].ID
+	})
+
+	total := len(res)
+
+	if offset > total {
+		offset = total
+	}
+
+	if offset+limit > total {
+		limit = total - offset
+	}
+
+	return res[offset : offset+limit], total, nil
+}
+
+func (repo *loginThrottleRepo) Unlock(id string, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.lockouts {
+		if repo.lockouts[i].ID != id {
+			continue
+		}
+
+		key := loginThrottleKey{kind: repo.lockouts[i].Kind, subject: repo.lockouts[i].Subject}
+
+		for j := range repo.lockouts {
+			if repo.lockouts[j].Kind == key.kind && repo.lockouts[j].Subject == key.subject && repo.lockouts[j].ExpiresAt.After(now) {
+				repo.lockouts[j].ExpiresAt = now
+			}
+		}
+
+		delete(repo.throttles, key)
+
+		return nil
+	}
+
+	return models.LockoutByIDNotFoundError{ID: id}
+}
@@ -0,0 +1,83 @@
+package inmem
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sync"
+	"time"
+)
+
+type oneTimeTokenRepo struct {
+	mu     sync.RWMutex
+	tokens map[string]models.OneTimeToken
+}
+
+func NewOneTimeTokenRepository() models.OneTimeTokenRepository {
+	return &oneTimeTokenRepo{
+		tokens: make(map[string]models.OneTimeToken),
+	}
+}
+
+func (repo *oneTimeTokenRepo) Add(entity models.OneTimeToken) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.tokens[entity.ID]; ok {
+		return fmt.Errorf("one time token with id '%s' already exists", entity.ID)
+	}
+
+	repo.tokens[entity.ID] = entity
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) GetByID(id string) (*models.OneTimeToken, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	token, ok := repo.tokens[id]
+	if !ok {
+		return nil, models.OneTimeTokenByIDNotFoundError{ID: id}
+	}
+
+	return &token, nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByID(id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.tokens[id]; !ok {
+		return models.OneTimeTokenByIDNotFoundError{ID: id}
+	}
+
+	delete(repo.tokens, id)
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByUserID(userID int, purpose models.TokenPurpose) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, token := range repo.tokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			delete(repo.tokens, id)
+		}
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteExpired(now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, token := range repo.tokens {
+		if token.ExpiresAt.Before(now) {
+			delete(repo.tokens, id)
+		}
+	}
+
+	return nil
+}
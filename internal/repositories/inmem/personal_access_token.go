@@ -0,0 +1,112 @@
+package inmem
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type personalAccessTokenRepo struct {
+	mu     sync.RWMutex
+	tokens []models.PersonalAccessToken
+}
+
+func NewPersonalAccessTokenRepository() models.PersonalAccessTokenRepository {
+	return &personalAccessTokenRepo{
+		tokens: make([]models.PersonalAccessToken, 0),
+	}
+}
+
+func (repo *personalAccessTokenRepo) Add(entity models.PersonalAccessToken) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, token := range repo.tokens {
+		if token.ID == entity.ID {
+			return fmt.Errorf("personal access token with id '%s' already exists", entity.ID)
+		}
+
+		if token.UserID == entity.UserID && token.Name == entity.Name {
+			return fmt.Errorf("personal access token with name '%s' already exists", entity.Name)
+		}
+	}
+
+	repo.tokens = append(repo.tokens, copyPersonalAccessToken(entity))
+
+	return nil
+}
+
+func (repo *personalAccessTokenRepo) GetByID(id string) (*models.PersonalAccessToken, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, token := range repo.tokens {
+		if token.ID == id {
+			token = copyPersonalAccessToken(token)
+			return &token, nil
+		}
+	}
+
+	return nil, models.PersonalAccessTokenByIDNotFoundError{ID: id}
+}
+
+func (repo *personalAccessTokenRepo) ListByUserID(userID int) ([]models.PersonalAccessToken, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	res := make([]models.PersonalAccessToken, 0)
+	for _, token := range repo.tokens {
+		if token.UserID == userID {
+			res = append(res, copyPersonalAccessToken(token))
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.Before(res[j].CreatedAt)
+		}
+
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (repo *personalAccessTokenRepo) UpdateLastUsedAtByID(id string, lastUsedAt time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.tokens {
+		if repo.tokens[i].ID == id {
+			repo.tokens[i].LastUsedAt = lastUsedAt
+			return nil
+		}
+	}
+
+	return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+}
+
+func (repo *personalAccessTokenRepo) DeleteByID(id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.tokens {
+		if repo.tokens[i].ID == id {
+			repo.tokens = append(repo.tokens[:i], repo.tokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+}
+
+func copyPersonalAccessToken(token models.PersonalAccessToken) models.PersonalAccessToken {
+	scopes := make([]string, len(token.Scopes))
+	copy(scopes, token.Scopes)
+
+	token.Scopes = scopes
+
+	return token
+}
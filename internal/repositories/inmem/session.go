@@ -0,0 +1,172 @@
+package inmem
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type sessionRepo struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+	revoked  map[string]time.Time
+}
+
+func NewSessionRepository() models.SessionRepository {
+	return &sessionRepo{
+		sessions: make(map[string]models.Session),
+		revoked:  make(map[string]time.Time),
+	}
+}
+
+func (repo *sessionRepo) Add(entity models.Session) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.sessions[entity.ID]; ok {
+		return fmt.Errorf("session with id '%s' already exists", entity.ID)
+	}
+
+	repo.sessions[entity.ID] = entity
+
+	return nil
+}
+
+func (repo *sessionRepo) GetByID(id string) (*models.Session, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	session, ok := repo.sessions[id]
+	if !ok {
+		return nil, models.SessionByIDNotFoundError{ID: id}
+	}
+
+	return &session, nil
+}
+
+func (repo *sessionRepo) ListByUserID(userID int) ([]models.Session, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	res := make([]models.Session, 0)
+	for _, session := range repo.sessions {
+		if session.UserID == userID {
+			res = append(res, session)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].LastSeenAt.Equal(res[j].LastSeenAt) {
+			return res[i].LastSeenAt.After(res[j].LastSeenAt)
+		}
+
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (repo *sessionRepo) RefreshByID(id, refreshTokenHash string, entity models.Session) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	session, ok := repo.sessions[id]
+	if !ok {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	if session.RefreshTokenHash != refreshTokenHash {
+		return models.RefreshTokenMismatchError{SessionID: id}
+	}
+
+	session.RefreshTokenHash = entity.RefreshTokenHash
+	session.RefreshedAt = entity.RefreshedAt
+	session.ExpiresAt = entity.ExpiresAt
+	repo.sessions[id] = session
+
+	return nil
+}
+
+func (repo *sessionRepo) SeenByID(id string, lastSeenAt time.Time, userAgent, ip string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	session, ok := repo.sessions[id]
+	if !ok {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	session.LastSeenAt = lastSeenAt
+	session.UserAgent = userAgent
+	session.IP = ip
+	repo.sessions[id] = session
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByID(id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.sessions[id]; !ok {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	delete(repo.sessions, id)
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByUserID(userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, session := range repo.sessions {
+		if session.UserID == userID {
+			delete(repo.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteExpired(now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, session := range repo.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(repo.sessions, id)
+		}
+	}
+
+	for jti, expiresAt := range repo.revoked {
+		if expiresAt.Before(now) {
+			delete(repo.revoked, jti)
+		}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) RevokeTokenID(jti string, expiresAt time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if current, ok := repo.revoked[jti]; !ok || current.Before(expiresAt) {
+		repo.revoked[jti] = expiresAt
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) IsTokenIDRevoked(jti string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	_, ok := repo.revoked[jti]
+
+	return ok, nil
+}
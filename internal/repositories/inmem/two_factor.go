@@ -0,0 +1,108 @@
+package inmem
+
+import (
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sort"
+	"sync"
+)
+
+type twoFactorRepo struct {
+	mu         sync.RWMutex
+	twoFactors map[int]models.TwoFactor
+}
+
+func NewTwoFactorRepository() models.TwoFactorRepository {
+	return &twoFactorRepo{
+		twoFactors: make(map[int]models.TwoFactor),
+	}
+}
+
+func (repo *twoFactorRepo) Put(entity models.TwoFactor) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.twoFactors[entity.UserID] = copyTwoFactor(entity)
+
+	return nil
+}
+
+func (repo *twoFactorRepo) GetByUserID(userID int) (*models.TwoFactor, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	twoFactor, ok := repo.twoFactors[userID]
+	if !ok {
+		return nil, models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	twoFactor = copyTwoFactor(twoFactor)
+
+	return &twoFactor, nil
+}
+
+func (repo *twoFactorRepo) UseStepByUserID(userID int, step int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	twoFactor, ok := repo.twoFactors[userID]
+	if !ok {
+		return models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	if twoFactor.LastUsedStep >= step {
+		return models.TOTPStepUsedError{UserID: userID, Step: step}
+	}
+
+	twoFactor.LastUsedStep = step
+	repo.twoFactors[userID] = twoFactor
+
+	return nil
+}
+
+func (repo *twoFactorRepo) UseRecoveryCodeByUserID(userID int, codeHash string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	twoFactor, ok := repo.twoFactors[userID]
+	if !ok {
+		return models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	for i := range twoFactor.RecoveryCodeHashes {
+		if twoFactor.RecoveryCodeHashes[i] == codeHash {
+			hashes := make([]string, 0, len(twoFactor.RecoveryCodeHashes)-1)
+			hashes = append(hashes, twoFactor.RecoveryCodeHashes[:i]...)
+			hashes = append(hashes, twoFactor.RecoveryCodeHashes[i+1:]...)
+
+			twoFactor.RecoveryCodeHashes = hashes
+			repo.twoFactors[userID] = twoFactor
+
+			return nil
+		}
+	}
+
+	return models.RecoveryCodeNotFoundError{UserID: userID}
+}
+
+func (repo *twoFactorRepo) DeleteByUserID(userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.twoFactors[userID]; !ok {
+		return models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	delete(repo.twoFactors, userID)
+
+	return nil
+}
+
+func copyTwoFactor(twoFactor models.TwoFactor) models.TwoFactor {
+	hashes := make([]string, len(twoFactor.RecoveryCodeHashes))
+	copy(hashes, twoFactor.RecoveryCodeHashes)
+	sort.Strings(hashes)
+
+	twoFactor.RecoveryCodeHashes = hashes
+
+	return twoFactor
+}
@@ -3,9 +3,11 @@ package inmem
 import (
 	"fmt"
 	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"sync"
 )
 
 type userRepo struct {
+	mu     sync.RWMutex
 	users  []models.User
 	nextID int
 }
@@ -17,14 +19,21 @@ func NewUserRepository() models.UserRepository {
 	}
 }
 
-func (repo *userRepo) NewID() int {
+func (repo *userRepo) NewID() (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	defer func() { repo.nextID = repo.nextID + 1 }()
-	return repo.nextID
+	return repo.nextID, nil
 }
 
 func (repo *userRepo) GetByEmail(email string) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	for _, user := range repo.users {
 		if user.Email == email {
+			user = copyUser(user)
 			return &user, nil
 		}
 	}
@@ -33,8 +42,12 @@ func (repo *userRepo) GetByEmail(email string) (*models.User, error) {
 }
 
 func (repo *userRepo) GetByUsername(username string) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	for _, user := range repo.users {
 		if user.Username == username {
+			user = copyUser(user)
 			return &user, nil
 		}
 	}
@@ -43,8 +56,12 @@ func (repo *userRepo) GetByUsername(username string) (*models.User, error) {
 }
 
 func (repo *userRepo) GetByID(id int) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	for _, user := range repo.users {
 		if user.ID == id {
+			user = copyUser(user)
 			return &user, nil
 		}
 	}
@@ -53,6 +70,9 @@ func (repo *userRepo) GetByID(id int) (*models.User, error) {
 }
 
 func (repo *userRepo) Add(entity models.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	for _, user := range repo.users {
 		if user.ID == entity.ID {
 			return fmt.Errorf("user with id '%d' already exists", entity.ID)
@@ -63,17 +83,17 @@ func (repo *userRepo) Add(entity models.User) error {
 		if user.Username == entity.Username {
 			return fmt.Errorf("user with username '%s' already exists", entity.Username)
 		}
-		if entity.Token != "" && user.Token == entity.Token {
-			return fmt.Errorf("user with token '%s' already exists", entity.Token)
-		}
 	}
 
-	repo.users = append(repo.users, entity)
+	repo.users = append(repo.users, copyUser(entity))
 
 	return nil
 }
 
 func (repo *userRepo) UpdateByID(id int, entity models.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
 	index := -1
 	for i, user := range repo.users {
 		if user.ID == id {
@@ -89,27 +109,91 @@ func (repo *userRepo) UpdateByID(id int, entity models.User) error {
 		if user.Username == entity.Username {
 			return fmt.Errorf("user with username '%s' already exists", entity.Username)
 		}
-		if entity.Token != "" && user.Token == entity.Token {
-			return fmt.Errorf("user with token '%s' already exists", entity.Token)
-		}
 	}
 
 	if index == -1 {
 		return models.UserByIDNotFoundError{ID: id}
 	}
 
-	repo.users[index] = entity
+	entity.Followers = repo.users[index].Followers
+	repo.users[index] = copyUser(entity)
 
 	return nil
 }
 
+func (repo *userRepo) AddFollowerByID(id, followerID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.users {
+		if repo.users[i].ID == id {
+			repo.users[i].Followers[followerID] = true
+			return nil
+		}
+	}
+
+	return models.UserByIDNotFoundError{ID: id}
+}
+
+func (repo *userRepo) RemoveFollowerByID(id, followerID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for i := range repo.users {
+		if repo.users[i].ID == id {
+			delete(repo.users[i].Followers, followerID)
+			return nil
+		}
+	}
+
+	return models.UserByIDNotFoundError{ID: id}
+}
+
 func (repo *userRepo) ListByFollowedBy(userID int) ([]models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	var res []models.User
 	for _, user := range repo.users {
 		if f, ok := user.Followers[userID]; f && ok {
-			res = append(res, user)
+			res = append(res, copyUser(user))
 		}
 	}
 
 	return res, nil
 }
+
+func (repo *userRepo) DeleteByID(id int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	index := -1
+	for i := range repo.users {
+		if repo.users[i].ID == id {
+			index = i
+			continue
+		}
+
+		delete(repo.users[i].Followers, id)
+	}
+
+	if index == -1 {
+		return models.UserByIDNotFoundError{ID: id}
+	}
+
+	repo.users = append(repo.users[:index], repo.users[index+1:]...)
+
+	return nil
+}
+
+// copyUser returns a copy of user that shares no mutable state with it
+func copyUser(user models.User) models.User {
+	followers := make(map[int]bool, len(user.Followers))
+	for k, v := range user.Followers {
+		followers[k] = v
+	}
+
+	user.Followers = followers
+
+	return user
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+type identityRepo struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) models.IdentityRepository {
+	return &identityRepo{
+		db: db,
+	}
+}
+
+func (repo *identityRepo) Add(entity models.Identity) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO identities (issuer, subject, user_id, email, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		entity.Issuer, entity.Subject, entity.UserID, entity.Email, entity.CreatedAt,
+	)
+	if err != nil {
+		constraint, ok := uniqueViolation(err)
+		if ok && constraint == "identities_pkey" {
+			return fmt.Errorf("identity with subject '%s' of issuer '%s' already exists", entity.Subject, entity.Issuer)
+		}
+
+		if ok && constraint == "identities_user_id_issuer_key" {
+			return fmt.Errorf("identity of user with id '%d' of issuer '%s' already exists", entity.UserID, entity.Issuer)
+		}
+
+		return fmt.Errorf("error on insert identity: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *identityRepo) GetByIssuerAndSubject(issuer, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := repo.db.QueryRow(
+		`SELECT issuer, subject, user_id, email, created_at FROM identities WHERE issuer = $1 AND subject = $2`, issuer, subject,
+	).Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.IdentityByIssuerAndSubjectNotFoundError{Issuer: issuer, Subject: subject}
+		}
+
+		return nil, fmt.Errorf("error on get identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+func (repo *identityRepo) DeleteByUserID(userID int) error {
+	_, err := repo.db.Exec(`DELETE FROM identities WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete identities of user: %w", err)
+	}
+
+	return nil
+}
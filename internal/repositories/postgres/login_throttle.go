@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type loginThrottleRepo struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) models.LoginThrottleRepository {
+	return &loginThrottleRepo{
+		db: db,
+	}
+}
+
+func (repo *loginThrottleRepo) GetByKindAndSubject(kind models.LoginThrottleKind, subject string) (*models.LoginThrottle, error) {
+	throttle, err := scanLoginThrottle(repo.db.QueryRow(
+		`SELECT kind, subject, failures, last_failed_at, locked_until FROM login_throttles WHERE kind = $1 AND subject = $2`, kind, subject,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.LoginThrottleByKindAndSubjectNotFoundError{Kind: kind, Subject: subject}
+		}
+
+		return nil, fmt.Errorf("error on get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (repo *loginThrottleRepo) AddFailure(kind models.LoginThrottleKind, subject string, now, resetBefore time.Time) (*models.LoginThrottle, error) {
+	throttle, err := scanLoginThrottle(repo.db.QueryRow(
+		`INSERT INTO login_throttles (kind, subject, failures, last_failed_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failed_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failed_at = excluded.last_failed_at
+		RETURNING kind, subject, failures, last_failed_at, locked_until`,
+		kind, subject, now, resetBefore,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error on upsert login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (repo *loginThrottleRepo) Lock(lockout models.Lockout) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			`INSERT INTO login_throttles (kind, subject, failures, last_failed_at, locked_until) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (kind, subject) DO UPDATE SET locked_until = excluded.locked_until`,
+			lockout.Kind, lockout.Subject, lockout.Failures, lockout.CreatedAt, lockout.ExpiresAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error on upsert login throttle: %w", err)
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO lockouts (id, kind, subject, failures, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+			lockout.ID, lockout.Kind, lockout.Subject, lockout.Failures, lockout.CreatedAt, lockout.ExpiresAt,
+		)
+		if err != nil {
+			constraint, ok := uniqueViolation(err)
+			if ok && constraint == "lockouts_pkey" {
+				return fmt.Errorf("lockout with id '%s' already exists", lockout.ID)
+			}
+
+			return fmt.Errorf("error on insert lockout: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *loginThrottleRepo) DeleteByKindAndSubject(kind models.LoginThrottleKind, subject string) error {
+	_, err := repo.db.Exec(`DELETE FROM login_throttles WHERE kind = $1 AND subject = $2`, kind, subject)
+	if err != nil {
+		return fmt.Errorf("error on delete login throttle: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *loginThrottleRepo) ListLockouts(offset, limit int) ([]models.Lockout, int, error) {
+	var total int
+	err := repo.db.QueryRow(`SELECT COUNT(*) FROM lockouts`).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error on count lockouts: %w", err)
+	}
+
+	rows, err := repo.db.Query(
+		`SELECT id, kind, subject, failures, created_at, expires_at FROM lockouts ORDER BY created_at DESC, id LIMIT $1 OFFSET $2`, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error on query lockouts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	res := make([]models.Lockout, 0)
+	for rows.Next() {
+		var lockout models.Lockout
+		err = rows.Scan(&lockout.ID, &lockout.Kind, &lockout.Subject, &lockout.Failures, &lockout.CreatedAt, &lockout.ExpiresAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error on scan lockout: %w", err)
+		}
+
+		res = append(res, lockout)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error on iterate lockouts: %w", err)
+	}
+
+	return res, total, nil
+}
+
+func (repo *loginThrottleRepo) Unlock(id string, now time.Time) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		var (
+			kind    models.LoginThrottleKind
+			subject string
+		)
+
+		err := tx.QueryRow(`SELECT kind, subject FROM lockouts WHERE id = $1`, id).Scan(&kind, &subject)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.LockoutByIDNotFoundError{ID: id}
+			}
+
+			return fmt.Errorf("error on get lockout: %w", err)
+		}
+
+		_, err = tx.Exec(`UPDATE lockouts SET expires_at = $1 WHERE kind = $2 AND subject = $3 AND expires_at > $1`, now, kind, subject)
+		if err != nil {
+			return fmt.Errorf("error on update lockouts: %w", err)
+		}
+
+		_, err = tx.Exec(`DELETE FROM login_throttles WHERE kind = $1 AND subject = $2`, kind, subject)
+		if err != nil {
+			return fmt.Errorf("error on delete login throttle: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func scanLoginThrottle(row scanner) (*models.LoginThrottle, error) {
+	var (
+		throttle    models.LoginThrottle
+		lockedUntil sql.NullTime
+	)
+
+	err := row.Scan(&throttle.Kind, &throttle.Subject, &throttle.Failures, &throttle.LastFailedAt, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		throttle.LockedUntil = lockedUntil.Time
+	}
+
+	return &throttle, nil
+}
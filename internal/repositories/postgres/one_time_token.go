@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type oneTimeTokenRepo struct {
+	db *sql.DB
+}
+
+func NewOneTimeTokenRepository(db *sql.DB) models.OneTimeTokenRepository {
+	return &oneTimeTokenRepo{
+		db: db,
+	}
+}
+
+func (repo *oneTimeTokenRepo) Add(entity models.OneTimeToken) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO one_time_tokens (id, user_id, purpose, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		entity.ID, entity.UserID, entity.Purpose, entity.TokenHash, entity.CreatedAt, entity.ExpiresAt,
+	)
+	if err != nil {
+		constraint, ok := uniqueViolation(err)
+		if ok && constraint == "one_time_tokens_pkey" {
+			return fmt.Errorf("one time token with id '%s' already exists", entity.ID)
+		}
+
+		return fmt.Errorf("error on insert one time token: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) GetByID(id string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	err := repo.db.QueryRow(
+		`SELECT id, user_id, purpose, token_hash, created_at, expires_at FROM one_time_tokens WHERE id = $1`, id,
+	).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.OneTimeTokenByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get one time token: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete one time token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.OneTimeTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByUserID(userID int, purpose models.TokenPurpose) error {
+	_, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2`, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("error on delete one time tokens of user: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteExpired(now time.Time) error {
+	_, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return fmt.Errorf("error on delete expired one time tokens: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"strings"
+	"time"
+)
+
+type personalAccessTokenRepo struct {
+	db *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) models.PersonalAccessTokenRepository {
+	return &personalAccessTokenRepo{
+		db: db,
+	}
+}
+
+func (repo *personalAccessTokenRepo) Add(entity models.PersonalAccessToken) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO personal_access_tokens (id, user_id, name, scopes, token_hash, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entity.ID, entity.UserID, entity.Name, strings.Join(entity.Scopes, " "), entity.TokenHash, entity.CreatedAt, nullTime(entity.LastUsedAt),
+	)
+	if err != nil {
+		constraint, ok := uniqueViolation(err)
+		if !ok {
+			return fmt.Errorf("error on insert personal access token: %w", err)
+		}
+
+		switch constraint {
+		case "personal_access_tokens_pkey":
+			return fmt.Errorf("personal access token with id '%s' already exists", entity.ID)
+		case "personal_access_tokens_user_id_name_key":
+			return fmt.Errorf("personal access token with name '%s' already exists", entity.Name)
+		default:
+			return fmt.Errorf("error on insert personal access token: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (repo *personalAccessTokenRepo) GetByID(id string) (*models.PersonalAccessToken, error) {
+	token, err := scanPersonalAccessToken(repo.db.QueryRow(
+		`SELECT id, user_id, name, scopes, token_hash, created_at, last_used_at FROM personal_access_tokens WHERE id = $1`, id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.PersonalAccessTokenByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get personal access token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (repo *personalAccessTokenRepo) ListByUserID(userID int) ([]models.PersonalAccessToken, error) {
+	rows, err := repo.db.Query(
+		`SELECT id, user_id, name, scopes, token_hash, created_at, last_used_at FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at, id`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on query personal access tokens: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	res := make([]models.PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *token)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate personal access tokens: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *personalAccessTokenRepo) UpdateLastUsedAtByID(id string, lastUsedAt time.Time) error {
+	res, err := repo.db.Exec(`UPDATE personal_access_tokens SET last_used_at = $2 WHERE id = $1`, id, lastUsedAt)
+	if err != nil {
+		return fmt.Errorf("error on update personal access token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *personalAccessTokenRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM personal_access_tokens WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete personal access token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func scanPersonalAccessToken(row scanner) (*models.PersonalAccessToken, error) {
+	var (
+		token      models.PersonalAccessToken
+		scopes     string
+		lastUsedAt sql.NullTime
+	)
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.TokenHash, &token.CreatedAt, &lastUsedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error on scan personal access token: %w", err)
+	}
+
+	// scopes are stored space separated, like the scope parameter of OAuth 2.0
+	token.Scopes = strings.Fields(scopes)
+	if token.Scopes == nil {
+		token.Scopes = []string{}
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = lastUsedAt.Time
+	}
+
+	return &token, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// migrations are applied in order and each one exactly once, so existing entries must never be edited
+var migrations = []string{
+	`CREATE SEQUENCE users_id_seq;
+
+	CREATE TABLE users (
+		id       INTEGER NOT NULL,
+		email    TEXT    NOT NULL,
+		token    TEXT,
+		username TEXT    NOT NULL,
+		password TEXT    NOT NULL,
+		bio      TEXT    NOT NULL DEFAULT '',
+		image    TEXT    NOT NULL DEFAULT '',
+		CONSTRAINT users_pkey PRIMARY KEY (id),
+		CONSTRAINT users_email_key UNIQUE (email),
+		CONSTRAINT users_token_key UNIQUE (token),
+		CONSTRAINT users_username_key UNIQUE (username)
+	);
+
+	CREATE TABLE follows (
+		followee_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		follower_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		PRIMARY KEY (followee_id, follower_id)
+	);
+
+	CREATE INDEX follows_follower_id_idx ON follows (follower_id);
+
+	CREATE TABLE articles (
+		id          SERIAL      NOT NULL,
+		slug        TEXT        NOT NULL,
+		title       TEXT        NOT NULL,
+		description TEXT        NOT NULL,
+		body        TEXT        NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL,
+		updated_at  TIMESTAMPTZ NOT NULL,
+		author_id   INTEGER     NOT NULL REFERENCES users (id),
+		CONSTRAINT articles_pkey PRIMARY KEY (id),
+		CONSTRAINT articles_slug_key UNIQUE (slug)
+	);
+
+	CREATE TABLE article_tags (
+		article_id INTEGER NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		tag        TEXT    NOT NULL,
+		PRIMARY KEY (article_id, position)
+	);
+
+	CREATE INDEX article_tags_tag_idx ON article_tags (tag);
+
+	CREATE TABLE favorites (
+		article_id INTEGER NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		PRIMARY KEY (article_id, user_id)
+	);
+
+	CREATE SEQUENCE comments_id_seq;
+
+	CREATE TABLE comments (
+		id         INTEGER     NOT NULL,
+		article_id INTEGER     NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		body       TEXT        NOT NULL,
+		author_id  INTEGER     NOT NULL REFERENCES users (id),
+		CONSTRAINT comments_pkey PRIMARY KEY (id)
+	);
+
+	CREATE INDEX comments_article_id_idx ON comments (article_id);`,
+
+	// tokens are issued per response with an expiration instead of being stored
+	`ALTER TABLE users DROP COLUMN token;`,
+
+	`CREATE TABLE sessions (
+		id                 TEXT        NOT NULL,
+		user_id            INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		refresh_token_hash TEXT        NOT NULL,
+		created_at         TIMESTAMPTZ NOT NULL,
+		refreshed_at       TIMESTAMPTZ NOT NULL,
+		expires_at         TIMESTAMPTZ NOT NULL,
+		CONSTRAINT sessions_pkey PRIMARY KEY (id)
+	);
+
+	CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+	CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+	CREATE TABLE revoked_tokens (
+		jti        TEXT        NOT NULL PRIMARY KEY,
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);`,
+
+	`CREATE TABLE personal_access_tokens (
+		id           TEXT        NOT NULL,
+		user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		name         TEXT        NOT NULL,
+		scopes       TEXT        NOT NULL,
+		token_hash   TEXT        NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL,
+		last_used_at TIMESTAMPTZ,
+		CONSTRAINT personal_access_tokens_pkey PRIMARY KEY (id),
+		CONSTRAINT personal_access_tokens_user_id_name_key UNIQUE (user_id, name)
+	);`,
+
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';`,
+
+	`CREATE TABLE one_time_tokens (
+		id         TEXT        NOT NULL,
+		user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		purpose    TEXT        NOT NULL,
+		token_hash TEXT        NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT one_time_tokens_pkey PRIMARY KEY (id)
+	);
+
+	CREATE INDEX one_time_tokens_user_id_idx ON one_time_tokens (user_id);
+
+	CREATE INDEX one_time_tokens_expires_at_idx ON one_time_tokens (expires_at);`,
+
+	`ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+	`CREATE TABLE two_factors (
+		user_id        INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		secret         TEXT    NOT NULL,
+		enabled        BOOLEAN NOT NULL,
+		last_used_step BIGINT  NOT NULL,
+		CONSTRAINT two_factors_pkey PRIMARY KEY (user_id)
+	);
+
+	CREATE TABLE recovery_codes (
+		user_id   INTEGER NOT NULL REFERENCES two_factors (user_id) ON DELETE CASCADE,
+		code_hash TEXT    NOT NULL,
+		CONSTRAINT recovery_codes_pkey PRIMARY KEY (user_id, code_hash)
+	);`,
+
+	`CREATE TABLE login_throttles (
+		kind           TEXT        NOT NULL,
+		subject        TEXT        NOT NULL,
+		failures       INTEGER     NOT NULL,
+		last_failed_at TIMESTAMPTZ NOT NULL,
+		locked_until   TIMESTAMPTZ,
+		CONSTRAINT login_throttles_pkey PRIMARY KEY (kind, subject)
+	);
+
+	CREATE TABLE lockouts (
+		id         TEXT        NOT NULL,
+		kind       TEXT        NOT NULL,
+		subject    TEXT        NOT NULL,
+		failures   INTEGER     NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT lockouts_pkey PRIMARY KEY (id)
+	);
+
+	CREATE INDEX lockouts_created_at_idx ON lockouts (created_at);`,
+
+	`CREATE TABLE identities (
+		issuer     TEXT        NOT NULL,
+		subject    TEXT        NOT NULL,
+		user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		email      TEXT        NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT identities_pkey PRIMARY KEY (issuer, subject),
+		CONSTRAINT identities_user_id_issuer_key UNIQUE (user_id, issuer)
+	);`,
+
+	// sessions started before are last seen when refreshed, as far as it is known
+	`ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMPTZ;
+
+	UPDATE sessions SET last_seen_at = refreshed_at;
+
+	ALTER TABLE sessions ALTER COLUMN last_seen_at SET NOT NULL;
+
+	ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+	ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';`,
+}
+
+// Migrate creates or upgrades the database schema used by the repositories of this package
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("error on create schema migrations table: %w", err)
+	}
+
+	var current int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("error on get schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		err = withTx(db, func(tx *sql.Tx) error {
+			_, err := tx.Exec(migrations[i])
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, i+1)
+
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error on apply migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func uniqueViolation(err error) (constraint string, ok bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint, true
+	}
+
+	return "", false
+}
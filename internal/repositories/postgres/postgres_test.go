@@ -0,0 +1,62 @@
+package postgres_test
+
+import (
+	"database/sql"
+	_ "github.com/lib/pq"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/postgres"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/repotest"
+	"os"
+	"testing"
+)
+
+// newTestDB connects to the database in POSTGRES_TEST_DSN and recreates its public schema,
+// so it must only ever point to a throwaway database
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn, ok := os.LookupEnv("POSTGRES_TEST_DSN")
+	if !ok {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("error on open database: %s", err.Error())
+	}
+
+	_, err = db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public`)
+	if err != nil {
+		t.Fatalf("error on reset database: %s", err.Error())
+	}
+
+	err = postgres.Migrate(db)
+	if err != nil {
+		t.Fatalf("error on migrate database: %s", err.Error())
+	}
+
+	// migrating twice should be a no-op
+	err = postgres.Migrate(db)
+	if err != nil {
+		t.Fatalf("error on migrate database again: %s", err.Error())
+	}
+
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		db := newTestDB(t)
+		t.Cleanup(func() { _ = db.Close() })
+
+		return repotest.Repositories{
+			Users:                postgres.NewUserRepository(db),
+			Articles:             postgres.NewArticleRepository(db),
+			Sessions:             postgres.NewSessionRepository(db),
+			PersonalAccessTokens: postgres.NewPersonalAccessTokenRepository(db),
+			OneTimeTokens:        postgres.NewOneTimeTokenRepository(db),
+			TwoFactors:           postgres.NewTwoFactorRepository(db),
+			LoginThrottles:       postgres.NewLoginThrottleRepository(db),
+			Identities:           postgres.NewIdentityRepository(db),
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type sessionRepo struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) models.SessionRepository {
+	return &sessionRepo{
+		db: db,
+	}
+}
+
+const sessionColumns = `id, user_id, refresh_token_hash, created_at, refreshed_at, expires_at, last_seen_at, user_agent, ip`
+
+func (repo *sessionRepo) Add(entity models.Session) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entity.ID, entity.UserID, entity.RefreshTokenHash, entity.CreatedAt, entity.RefreshedAt, entity.ExpiresAt,
+		entity.LastSeenAt, entity.UserAgent, entity.IP,
+	)
+	if err != nil {
+		constraint, ok := uniqueViolation(err)
+		if ok && constraint == "sessions_pkey" {
+			return fmt.Errorf("session with id '%s' already exists", entity.ID)
+		}
+
+		return fmt.Errorf("error on insert session: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) GetByID(id string) (*models.Session, error) {
+	session, err := scanSession(repo.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.SessionByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (repo *sessionRepo) ListByUserID(userID int) ([]models.Session, error) {
+	rows, err := repo.db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY last_seen_at DESC, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error on query sessions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	res := make([]models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan session: %w", err)
+		}
+
+		res = append(res, *session)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate sessions: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *sessionRepo) RefreshByID(id, refreshTokenHash string, entity models.Session) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			`UPDATE sessions SET refresh_token_hash = $3, refreshed_at = $4, expires_at = $5 WHERE id = $1 AND refresh_token_hash = $2`,
+			id, refreshTokenHash, entity.RefreshTokenHash, entity.RefreshedAt, entity.ExpiresAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error on update session: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected > 0 {
+			return nil
+		}
+
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error on check session existence: %w", err)
+		}
+
+		if !exists {
+			return models.SessionByIDNotFoundError{ID: id}
+		}
+
+		return models.RefreshTokenMismatchError{SessionID: id}
+	})
+}
+
+func (repo *sessionRepo) SeenByID(id string, lastSeenAt time.Time, userAgent, ip string) error {
+	res, err := repo.db.Exec(
+		`UPDATE sessions SET last_seen_at = $2, user_agent = $3, ip = $4 WHERE id = $1`,
+		id, lastSeenAt, userAgent, ip,
+	)
+	if err != nil {
+		return fmt.Errorf("error on update session: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete session: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByUserID(userID int) error {
+	_, err := repo.db.Exec(`DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete sessions of user: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteExpired(now time.Time) error {
+	_, err := repo.db.Exec(`DELETE FROM sessions WHERE expires_at < $1`, now)
+	if err != nil {
+		return fmt.Errorf("error on delete expired sessions: %w", err)
+	}
+
+	_, err = repo.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return fmt.Errorf("error on delete expired revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) RevokeTokenID(jti string, expiresAt time.Time) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)`,
+		jti, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error on insert revoked token: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) IsTokenIDRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := repo.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("error on check revoked token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+func scanSession(row scanner) (*models.Session, error) {
+	var session models.Session
+
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.RefreshTokenHash, &session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt,
+		&session.LastSeenAt, &session.UserAgent, &session.IP,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,2185 @@
+// Package repotest provides a conformance suite that every repository implementation should pass
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Repositories are repositories sharing the same storage
+type Repositories struct {
+	Users                models.UserRepository
+	Articles             models.ArticleRepository
+	Sessions             models.SessionRepository
+	PersonalAccessTokens models.PersonalAccessTokenRepository
+	OneTimeTokens        models.OneTimeTokenRepository
+	TwoFactors           models.TwoFactorRepository
+	LoginThrottles       models.LoginThrottleRepository
+	Identities           models.IdentityRepository
+}
+
+// Factory returns new and empty repositories sharing the same storage
+type Factory func(t *testing.T) Repositories
+
+// Run runs the conformance suite, calling newRepositories once for each test
+func Run(t *testing.T, newRepositories Factory) {
+	t.Run("UserRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository){
+			"NewID":            testUserNewID,
+			"Get":              testUserGet,
+			"Not Found":        testUserNotFound,
+			"Unique On Add":    testUserUniqueOnAdd,
+			"Unique On Update": testUserUniqueOnUpdate,
+			"Update":           testUserUpdate,
+			"Followers":        testUserFollowers,
+			"Isolation":        testUserIsolation,
+			"Delete":           testUserDelete,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users)
+			})
+		}
+	})
+
+	t.Run("ArticleRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.ArticleRepository){
+			"Get":              testArticleGet,
+			"Not Found":        testArticleNotFound,
+			"Unique On Add":    testArticleUniqueOnAdd,
+			"Unique On Update": testArticleUniqueOnUpdate,
+			"Update":           testArticleUpdate,
+			"Delete":           testArticleDelete,
+			"Favorites":        testArticleFavorites,
+			"Comments":         testArticleComments,
+			"Filters":          testArticleFilters,
+			"Pagination":       testArticlePagination,
+			"Tags":             testArticleTags,
+			"Isolation":        testArticleIsolation,
+			"Delete By Author": testArticleDeleteByAuthor,
+			"Transfer":         testArticleTransfer,
+			"Remove Favorites": testArticleRemoveFavorites,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.Articles)
+			})
+		}
+	})
+
+	t.Run("SessionRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.SessionRepository){
+			"Get":             testSessionGet,
+			"Not Found":       testSessionNotFound,
+			"Unique On Add":   testSessionUniqueOnAdd,
+			"List By User":    testSessionListByUser,
+			"Refresh":         testSessionRefresh,
+			"Seen":            testSessionSeen,
+			"Delete":          testSessionDelete,
+			"Delete By User":  testSessionDeleteByUser,
+			"Delete Expired":  testSessionDeleteExpired,
+			"Revoke Token ID": testSessionRevokeTokenID,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.Sessions)
+			})
+		}
+	})
+
+	t.Run("PersonalAccessTokenRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.PersonalAccessTokenRepository){
+			"Get":           testPersonalAccessTokenGet,
+			"Not Found":     testPersonalAccessTokenNotFound,
+			"Unique On Add": testPersonalAccessTokenUniqueOnAdd,
+			"List":          testPersonalAccessTokenList,
+			"Last Used At":  testPersonalAccessTokenLastUsedAt,
+			"Delete":        testPersonalAccessTokenDelete,
+			"Isolation":     testPersonalAccessTokenIsolation,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.PersonalAccessTokens)
+			})
+		}
+	})
+
+	t.Run("OneTimeTokenRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.OneTimeTokenRepository){
+			"Get":            testOneTimeTokenGet,
+			"Not Found":      testOneTimeTokenNotFound,
+			"Unique On Add":  testOneTimeTokenUniqueOnAdd,
+			"Delete":         testOneTimeTokenDelete,
+			"Delete By User": testOneTimeTokenDeleteByUser,
+			"Delete Expired": testOneTimeTokenDeleteExpired,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.OneTimeTokens)
+			})
+		}
+	})
+
+	t.Run("TwoFactorRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.TwoFactorRepository){
+			"Get":               testTwoFactorGet,
+			"Not Found":         testTwoFactorNotFound,
+			"Replace":           testTwoFactorReplace,
+			"Use Step":          testTwoFactorUseStep,
+			"Use Recovery Code": testTwoFactorUseRecoveryCode,
+			"Delete":            testTwoFactorDelete,
+			"Isolation":         testTwoFactorIsolation,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.TwoFactors)
+			})
+		}
+	})
+
+	t.Run("LoginThrottleRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.LoginThrottleRepository){
+			"Not Found":     testLoginThrottleNotFound,
+			"Add Failure":   testLoginThrottleAddFailure,
+			"Reset":         testLoginThrottleReset,
+			"Lock":          testLoginThrottleLock,
+			"Delete":        testLoginThrottleDelete,
+			"List Lockouts": testLoginThrottleListLockouts,
+			"Unlock":        testLoginThrottleUnlock,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				test(t, newRepositories(t).LoginThrottles)
+			})
+		}
+	})
+
+	t.Run("IdentityRepository", func(t *testing.T) {
+		for name, test := range map[string]func(*testing.T, models.UserRepository, models.IdentityRepository){
+			"Get":            testIdentityGet,
+			"Not Found":      testIdentityNotFound,
+			"Unique On Add":  testIdentityUniqueOnAdd,
+			"Delete By User": testIdentityDeleteByUser,
+		} {
+			test := test
+			t.Run(name, func(t *testing.T) {
+				repos := newRepositories(t)
+				test(t, repos.Users, repos.Identities)
+			})
+		}
+	})
+}
+
+// now is truncated to microseconds, the finest precision all storages keep
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func addUser(t *testing.T, repo models.UserRepository, username string) models.User {
+	t.Helper()
+
+	id, err := repo.NewID()
+	if err != nil {
+		t.Fatalf("error on new user id: %s", err.Error())
+	}
+
+	user := models.User{
+		ID:        id,
+		Email:     username + "@example.com",
+		Username:  username,
+		Password:  "password",
+		Bio:       "bio of " + username,
+		Image:     "https://example.com/" + username + ".png",
+		Role:      models.RoleUser,
+		Followers: map[int]bool{},
+	}
+
+	err = repo.Add(user)
+	if err != nil {
+		t.Fatalf("error on add user: %s", err.Error())
+	}
+
+	return user
+}
+
+func addArticle(t *testing.T, repo models.ArticleRepository, slug string, author models.User, tags ...string) models.Article {
+	t.Helper()
+
+	article := models.Article{
+		Slug:        slug,
+		Title:       "title of " + slug,
+		Description: "description of " + slug,
+		Body:        "body of " + slug,
+		Tags:        append([]string{}, tags...),
+		CreatedAt:   now(),
+		UpdatedAt:   now(),
+		AuthorID:    author.ID,
+		Favorites:   map[int]bool{},
+		Comments:    []models.Comment{},
+	}
+
+	err := repo.Add(article)
+	if err != nil {
+		t.Fatalf("error on add article: %s", err.Error())
+	}
+
+	return article
+}
+
+func addComment(t *testing.T, repo models.ArticleRepository, slug string, author models.User) models.Comment {
+	t.Helper()
+
+	id, err := repo.NewCommentID()
+	if err != nil {
+		t.Fatalf("error on new comment id: %s", err.Error())
+	}
+
+	comment := models.Comment{
+		ID:        id,
+		CreatedAt: now(),
+		UpdatedAt: now(),
+		Body:      fmt.Sprintf("comment %d", id),
+		AuthorID:  author.ID,
+	}
+
+	err = repo.AddCommentBySlug(slug, comment)
+	if err != nil {
+		t.Fatalf("error on add comment: %s", err.Error())
+	}
+
+	return comment
+}
+
+func getUser(t *testing.T, repo models.UserRepository, id int) models.User {
+	t.Helper()
+
+	user, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("error on get user by id: %s", err.Error())
+	}
+
+	return *user
+}
+
+func getArticle(t *testing.T, repo models.ArticleRepository, slug string) models.Article {
+	t.Helper()
+
+	article, err := repo.GetBySlug(slug)
+	if err != nil {
+		t.Fatalf("error on get article by slug: %s", err.Error())
+	}
+
+	return *article
+}
+
+func assertError(t *testing.T, err error, target interface{}) {
+	t.Helper()
+
+	if !errors.As(err, target) {
+		t.Errorf("expected error of type '%T', but got '%v'", reflect.ValueOf(target).Elem().Interface(), err)
+	}
+}
+
+func assertUser(t *testing.T, expected, actual models.User) {
+	t.Helper()
+
+	if len(expected.Followers) == 0 && len(actual.Followers) == 0 {
+		expected.Followers, actual.Followers = nil, nil
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected user '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func assertArticle(t *testing.T, expected, actual models.Article) {
+	t.Helper()
+
+	if !expected.CreatedAt.Equal(actual.CreatedAt) || !expected.UpdatedAt.Equal(actual.UpdatedAt) {
+		t.Errorf("expected article times '%s' and '%s', but got '%s' and '%s'", expected.CreatedAt, expected.UpdatedAt, actual.CreatedAt, actual.UpdatedAt)
+	}
+
+	if len(expected.Comments) != len(actual.Comments) {
+		t.Errorf("expected %d comments, but got %d", len(expected.Comments), len(actual.Comments))
+	} else {
+		for i := range expected.Comments {
+			assertComment(t, expected.Comments[i], actual.Comments[i])
+		}
+	}
+
+	expected.CreatedAt, expected.UpdatedAt, expected.Comments = time.Time{}, time.Time{}, nil
+	actual.CreatedAt, actual.UpdatedAt, actual.Comments = time.Time{}, time.Time{}, nil
+
+	if len(expected.Tags) == 0 && len(actual.Tags) == 0 {
+		expected.Tags, actual.Tags = nil, nil
+	}
+
+	if len(expected.Favorites) == 0 && len(actual.Favorites) == 0 {
+		expected.Favorites, actual.Favorites = nil, nil
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected article '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func assertComment(t *testing.T, expected, actual models.Comment) {
+	t.Helper()
+
+	if !expected.CreatedAt.Equal(actual.CreatedAt) || !expected.UpdatedAt.Equal(actual.UpdatedAt) {
+		t.Errorf("expected comment times '%s' and '%s', but got '%s' and '%s'", expected.CreatedAt, expected.UpdatedAt, actual.CreatedAt, actual.UpdatedAt)
+	}
+
+	expected.CreatedAt, expected.UpdatedAt = time.Time{}, time.Time{}
+	actual.CreatedAt, actual.UpdatedAt = time.Time{}, time.Time{}
+
+	if expected != actual {
+		t.Errorf("expected comment '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func assertSlugs(t *testing.T, expected []string, actual []models.Article) {
+	t.Helper()
+
+	slugs := make([]string, len(actual))
+	for i := range actual {
+		slugs[i] = actual[i].Slug
+	}
+
+	if len(expected) == 0 && len(slugs) == 0 {
+		return
+	}
+
+	if !reflect.DeepEqual(expected, slugs) {
+		t.Errorf("expected articles '%v', but got '%v'", expected, slugs)
+	}
+}
+
+func testUserNewID(t *testing.T, repo models.UserRepository) {
+	seen := make(map[int]bool)
+	for i := 0; i < 10; i++ {
+		id, err := repo.NewID()
+		if err != nil {
+			t.Fatalf("error on new id: %s", err.Error())
+		}
+
+		if seen[id] {
+			t.Errorf("id '%d' handed out more than once", id)
+		}
+
+		seen[id] = true
+	}
+}
+
+func testUserGet(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+	addUser(t, repo, "bob")
+
+	res, err := repo.GetByID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on get by id: %s", err.Error())
+	}
+
+	assertUser(t, alice, *res)
+
+	res, err = repo.GetByEmail(alice.Email)
+	if err != nil {
+		t.Fatalf("error on get by email: %s", err.Error())
+	}
+
+	assertUser(t, alice, *res)
+
+	res, err = repo.GetByUsername(alice.Username)
+	if err != nil {
+		t.Fatalf("error on get by username: %s", err.Error())
+	}
+
+	assertUser(t, alice, *res)
+}
+
+func testUserNotFound(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+
+	_, err := repo.GetByEmail("nobody@example.com")
+	assertError(t, err, &models.UserByEmailNotFoundError{})
+
+	_, err = repo.GetByUsername("nobody")
+	assertError(t, err, &models.UserByUsernameNotFoundError{})
+
+	_, err = repo.GetByID(alice.ID + 1000)
+	assertError(t, err, &models.UserByIDNotFoundError{})
+
+	err = repo.UpdateByID(alice.ID+1000, models.User{ID: alice.ID + 1000, Email: "nobody@example.com", Username: "nobody"})
+	assertError(t, err, &models.UserByIDNotFoundError{})
+
+	err = repo.AddFollowerByID(alice.ID+1000, alice.ID)
+	assertError(t, err, &models.UserByIDNotFoundError{})
+
+	err = repo.RemoveFollowerByID(alice.ID+1000, alice.ID)
+	assertError(t, err, &models.UserByIDNotFoundError{})
+}
+
+func testUserUniqueOnAdd(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+
+	id, err := repo.NewID()
+	if err != nil {
+		t.Fatalf("error on new id: %s", err.Error())
+	}
+
+	for name, user := range map[string]models.User{
+		"id":       {ID: alice.ID, Email: "carol@example.com", Username: "carol"},
+		"email":    {ID: id, Email: alice.Email, Username: "carol"},
+		"username": {ID: id, Email: "carol@example.com", Username: alice.Username},
+	} {
+		err = repo.Add(user)
+		if err == nil {
+			t.Errorf("expected error on add user with duplicate %s", name)
+		}
+	}
+
+	addUser(t, repo, "carol")
+}
+
+func testUserUniqueOnUpdate(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+	bob := addUser(t, repo, "bob")
+
+	for name, update := range map[string]func(user *models.User){
+		"email":    func(user *models.User) { user.Email = alice.Email },
+		"username": func(user *models.User) { user.Username = alice.Username },
+	} {
+		user := bob
+		update(&user)
+
+		err := repo.UpdateByID(bob.ID, user)
+		if err == nil {
+			t.Errorf("expected error on update user to duplicate %s", name)
+		}
+	}
+
+	assertUser(t, bob, getUser(t, repo, bob.ID))
+}
+
+func testUserUpdate(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+
+	alice.Email = "alice@example.org"
+	alice.Username = "alice2"
+	alice.Password = "new password"
+	alice.Bio = "new bio"
+	alice.Image = ""
+	alice.Role = models.RoleModerator
+	alice.Verified = true
+
+	err := repo.UpdateByID(alice.ID, alice)
+	if err != nil {
+		t.Fatalf("error on update user: %s", err.Error())
+	}
+
+	assertUser(t, alice, getUser(t, repo, alice.ID))
+
+	// keeping own email and username is not a conflict
+	err = repo.UpdateByID(alice.ID, alice)
+	if err != nil {
+		t.Errorf("error on update user without changes: %s", err.Error())
+	}
+
+	_, err = repo.GetByEmail("alice@example.com")
+	assertError(t, err, &models.UserByEmailNotFoundError{})
+
+	res, err := repo.GetByEmail(alice.Email)
+	if err != nil {
+		t.Fatalf("error on get by email: %s", err.Error())
+	}
+
+	assertUser(t, alice, *res)
+}
+
+func testUserFollowers(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+	bob := addUser(t, repo, "bob")
+	carol := addUser(t, repo, "carol")
+
+	for _, follow := range [][2]models.User{{alice, bob}, {alice, carol}, {bob, carol}, {alice, bob}} {
+		err := repo.AddFollowerByID(follow[0].ID, follow[1].ID)
+		if err != nil {
+			t.Fatalf("error on add follower: %s", err.Error())
+		}
+	}
+
+	// updating user fields keeps followers
+	alice.Bio = "new bio"
+	err := repo.UpdateByID(alice.ID, alice)
+	if err != nil {
+		t.Fatalf("error on update user: %s", err.Error())
+	}
+
+	alice.Followers = map[int]bool{bob.ID: true, carol.ID: true}
+	assertUser(t, alice, getUser(t, repo, alice.ID))
+
+	for follower, expected := range map[int][]int{
+		alice.ID: nil,
+		bob.ID:   {alice.ID},
+		carol.ID: {alice.ID, bob.ID},
+	} {
+		res, err := repo.ListByFollowedBy(follower)
+		if err != nil {
+			t.Fatalf("error on list by followed by: %s", err.Error())
+		}
+
+		ids := make([]int, 0, len(res))
+		for i := range res {
+			ids = append(ids, res[i].ID)
+		}
+
+		sort.Ints(ids)
+
+		if len(expected) != len(ids) || (len(ids) > 0 && !reflect.DeepEqual(expected, ids)) {
+			t.Errorf("expected user '%d' to follow '%v', but got '%v'", follower, expected, ids)
+		}
+	}
+
+	err = repo.RemoveFollowerByID(alice.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("error on remove follower: %s", err.Error())
+	}
+
+	// removing a missing follower is not an error
+	err = repo.RemoveFollowerByID(alice.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("error on remove follower again: %s", err.Error())
+	}
+
+	alice.Followers = map[int]bool{carol.ID: true}
+	assertUser(t, alice, getUser(t, repo, alice.ID))
+}
+
+func testUserIsolation(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+	bob := addUser(t, repo, "bob")
+
+	// mutating added and returned values must not change stored users
+	alice.Followers[bob.ID] = true
+
+	res := getUser(t, repo, alice.ID)
+	res.Followers[bob.ID] = true
+	res.Bio = "changed"
+
+	alice.Followers = map[int]bool{}
+	alice.Bio = "bio of alice"
+	assertUser(t, alice, getUser(t, repo, alice.ID))
+}
+
+func testUserDelete(t *testing.T, repo models.UserRepository) {
+	alice := addUser(t, repo, "alice")
+	bob := addUser(t, repo, "bob")
+	carol := addUser(t, repo, "carol")
+
+	for _, follow := range [][2]models.User{{alice, bob}, {bob, carol}, {alice, carol}} {
+		err := repo.AddFollowerByID(follow[0].ID, follow[1].ID)
+		if err != nil {
+			t.Fatalf("error on add follower: %s", err.Error())
+		}
+	}
+
+	err := repo.DeleteByID(bob.ID)
+	if err != nil {
+		t.Fatalf("error on delete user: %s", err.Error())
+	}
+
+	_, err = repo.GetByID(bob.ID)
+	assertError(t, err, &models.UserByIDNotFoundError{})
+
+	err = repo.DeleteByID(bob.ID)
+	assertError(t, err, &models.UserByIDNotFoundError{})
+
+	// follows of and by deleted user are gone
+	alice.Followers = map[int]bool{carol.ID: true}
+	assertUser(t, alice, getUser(t, repo, alice.ID))
+
+	res, err := repo.ListByFollowedBy(carol.ID)
+	if err != nil {
+		t.Fatalf("error on list by followed by: %s", err.Error())
+	}
+
+	if len(res) != 1 || res[0].ID != alice.ID {
+		t.Errorf("expected carol to follow alice only, but got '%v'", res)
+	}
+}
+
+func testArticleGet(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	article := addArticle(t, repo, "first", alice, "go", "sql")
+	addArticle(t, repo, "second", alice)
+
+	assertArticle(t, article, getArticle(t, repo, article.Slug))
+}
+
+func testArticleNotFound(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	addArticle(t, repo, "first", alice)
+
+	_, err := repo.GetBySlug("missing")
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.UpdateBySlug("missing", models.Article{Slug: "missing", AuthorID: alice.ID, CreatedAt: now(), UpdatedAt: now()})
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.DeleteBySlug("missing")
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.AddFavoriteBySlug("missing", alice.ID)
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.RemoveFavoriteBySlug("missing", alice.ID)
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	id, err := repo.NewCommentID()
+	if err != nil {
+		t.Fatalf("error on new comment id: %s", err.Error())
+	}
+
+	err = repo.AddCommentBySlug("missing", models.Comment{ID: id, AuthorID: alice.ID, CreatedAt: now(), UpdatedAt: now()})
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.DeleteCommentBySlug("missing", id)
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	err = repo.DeleteCommentBySlug("first", id)
+	assertError(t, err, &models.CommentByIDNotFoundError{})
+}
+
+func testArticleUniqueOnAdd(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	article := addArticle(t, repo, "first", alice)
+
+	err := repo.Add(models.Article{Slug: article.Slug, AuthorID: alice.ID, CreatedAt: now(), UpdatedAt: now()})
+	if err == nil {
+		t.Error("expected error on add article with duplicate slug")
+	}
+
+	assertArticle(t, article, getArticle(t, repo, article.Slug))
+}
+
+func testArticleUniqueOnUpdate(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	first := addArticle(t, repo, "first", alice)
+	second := addArticle(t, repo, "second", alice)
+
+	update := second
+	update.Slug = first.Slug
+	err := repo.UpdateBySlug(second.Slug, update)
+	if err == nil {
+		t.Error("expected error on update article to duplicate slug")
+	}
+
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+}
+
+func testArticleUpdate(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	article := addArticle(t, repo, "first", alice, "go", "sql")
+
+	err := repo.AddFavoriteBySlug(article.Slug, bob.ID)
+	if err != nil {
+		t.Fatalf("error on add favorite: %s", err.Error())
+	}
+
+	comment := addComment(t, repo, article.Slug, bob)
+
+	// keeping own slug is not a conflict
+	article.Body = "new body"
+	err = repo.UpdateBySlug(article.Slug, article)
+	if err != nil {
+		t.Fatalf("error on update article: %s", err.Error())
+	}
+
+	// updating fields and tags keeps favorites and comments
+	article.Slug = "renamed"
+	article.Title = "new title"
+	article.Description = "new description"
+	article.Tags = []string{"sql", "db"}
+	article.UpdatedAt = now().Add(time.Minute)
+	err = repo.UpdateBySlug("first", article)
+	if err != nil {
+		t.Fatalf("error on update article: %s", err.Error())
+	}
+
+	_, err = repo.GetBySlug("first")
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	article.Favorites = map[int]bool{bob.ID: true}
+	article.Comments = []models.Comment{comment}
+	assertArticle(t, article, getArticle(t, repo, article.Slug))
+}
+
+func testArticleDelete(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	first := addArticle(t, repo, "first", alice, "go")
+	second := addArticle(t, repo, "second", alice, "sql")
+	addComment(t, repo, first.Slug, alice)
+
+	err := repo.DeleteBySlug(first.Slug)
+	if err != nil {
+		t.Fatalf("error on delete article: %s", err.Error())
+	}
+
+	_, err = repo.GetBySlug(first.Slug)
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+
+	res, total, err := repo.List(0, 20)
+	if err != nil {
+		t.Fatalf("error on list articles: %s", err.Error())
+	}
+
+	if total != 1 {
+		t.Errorf("expected 1 article, but got %d", total)
+	}
+
+	assertSlugs(t, []string{second.Slug}, res)
+
+	tags, err := repo.GetTags()
+	if err != nil {
+		t.Fatalf("error on get tags: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(tags, []string{"sql"}) {
+		t.Errorf("expected tags of remaining article, but got '%v'", tags)
+	}
+}
+
+func testArticleFavorites(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	carol := addUser(t, userRepo, "carol")
+	article := addArticle(t, repo, "first", alice)
+
+	for _, user := range []models.User{bob, carol, bob} {
+		err := repo.AddFavoriteBySlug(article.Slug, user.ID)
+		if err != nil {
+			t.Fatalf("error on add favorite: %s", err.Error())
+		}
+	}
+
+	article.Favorites = map[int]bool{bob.ID: true, carol.ID: true}
+	assertArticle(t, article, getArticle(t, repo, article.Slug))
+
+	for i := 0; i < 2; i++ {
+		err := repo.RemoveFavoriteBySlug(article.Slug, bob.ID)
+		if err != nil {
+			t.Fatalf("error on remove favorite: %s", err.Error())
+		}
+	}
+
+	article.Favorites = map[int]bool{carol.ID: true}
+	assertArticle(t, article, getArticle(t, repo, article.Slug))
+}
+
+func testArticleComments(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addArticle(t, repo, "first", alice)
+	second := addArticle(t, repo, "second", alice)
+
+	comments := []models.Comment{
+		addComment(t, repo, first.Slug, bob),
+		addComment(t, repo, first.Slug, alice),
+		addComment(t, repo, first.Slug, bob),
+	}
+	other := addComment(t, repo, second.Slug, bob)
+
+	err := repo.AddCommentBySlug(first.Slug, comments[0])
+	if err == nil {
+		t.Error("expected error on add comment with duplicate id")
+	}
+
+	first.Comments = comments
+	assertArticle(t, first, getArticle(t, repo, first.Slug))
+
+	err = repo.DeleteCommentBySlug(first.Slug, comments[1].ID)
+	if err != nil {
+		t.Fatalf("error on delete comment: %s", err.Error())
+	}
+
+	// comments can only be deleted through their own article
+	err = repo.DeleteCommentBySlug(first.Slug, other.ID)
+	assertError(t, err, &models.CommentByIDNotFoundError{})
+
+	err = repo.DeleteCommentBySlug(first.Slug, comments[1].ID)
+	assertError(t, err, &models.CommentByIDNotFoundError{})
+
+	first.Comments = []models.Comment{comments[0], comments[2]}
+	assertArticle(t, first, getArticle(t, repo, first.Slug))
+
+	second.Comments = []models.Comment{other}
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+}
+
+func testArticleFilters(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	carol := addUser(t, userRepo, "carol")
+
+	addArticle(t, repo, "a1", alice, "go", "sql")
+	addArticle(t, repo, "b1", bob, "go")
+	addArticle(t, repo, "a2", alice)
+	addArticle(t, repo, "c1", carol, "sql")
+
+	for _, favorite := range []struct {
+		slug string
+		user models.User
+	}{{"a1", bob}, {"c1", bob}, {"b1", alice}} {
+		err := repo.AddFavoriteBySlug(favorite.slug, favorite.user.ID)
+		if err != nil {
+			t.Fatalf("error on add favorite: %s", err.Error())
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		filters  []models.ArticleFilter
+		expected []string
+	}{
+		"None":              {nil, []string{"a1", "b1", "a2", "c1"}},
+		"Tag":               {[]models.ArticleFilter{models.FilterArticlesByTag("go")}, []string{"a1", "b1"}},
+		"Missing Tag":       {[]models.ArticleFilter{models.FilterArticlesByTag("rust")}, nil},
+		"Author":            {[]models.ArticleFilter{models.FilterArticlesByAuthor(alice)}, []string{"a1", "a2"}},
+		"Unknown Author":    {[]models.ArticleFilter{models.FilterArticlesByAuthor(models.User{Username: "nobody"})}, nil},
+		"Authors":           {[]models.ArticleFilter{models.FilterArticlesByAuthors(bob, carol)}, []string{"b1", "c1"}},
+		"No Authors":        {[]models.ArticleFilter{models.FilterArticlesByAuthors()}, nil},
+		"Favorite":          {[]models.ArticleFilter{models.FilterArticlesByFavorite(bob)}, []string{"a1", "c1"}},
+		"Tag And Author":    {[]models.ArticleFilter{models.FilterArticlesByTag("sql"), models.FilterArticlesByAuthor(alice)}, []string{"a1"}},
+		"Tag And Favorite":  {[]models.ArticleFilter{models.FilterArticlesByTag("go"), models.FilterArticlesByFavorite(alice)}, []string{"b1"}},
+		"Author And Author": {[]models.ArticleFilter{models.FilterArticlesByAuthor(alice), models.FilterArticlesByAuthor(bob)}, nil},
+	} {
+		res, total, err := repo.List(0, 20, tc.filters...)
+		if err != nil {
+			t.Fatalf("error on list articles: %s", err.Error())
+		}
+
+		if total != len(tc.expected) {
+			t.Errorf("%s: expected total %d, but got %d", name, len(tc.expected), total)
+		}
+
+		assertSlugs(t, tc.expected, res)
+	}
+}
+
+func testArticlePagination(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+
+	slugs := make([]string, 5)
+	for i := range slugs {
+		slugs[i] = fmt.Sprintf("article-%d", i)
+		tags := []string{"all"}
+		if i%2 == 0 {
+			tags = append(tags, "even")
+		}
+
+		addArticle(t, repo, slugs[i], alice, tags...)
+	}
+
+	for _, tc := range []struct {
+		offset, limit int
+		filters       []models.ArticleFilter
+		expected      []string
+		total         int
+	}{
+		{0, 20, nil, slugs, 5},
+		{0, 2, nil, slugs[:2], 5},
+		{2, 2, nil, slugs[2:4], 5},
+		{4, 2, nil, slugs[4:], 5},
+		{5, 2, nil, nil, 5},
+		{10, 2, nil, nil, 5},
+		{0, 0, nil, nil, 5},
+		{-1, 2, nil, slugs[:2], 5},
+		{2, -1, nil, nil, 5},
+		{-1, -1, nil, nil, 5},
+		{10, -1, nil, nil, 5},
+		{1, 1, []models.ArticleFilter{models.FilterArticlesByTag("even")}, []string{slugs[2]}, 3},
+		{3, 1, []models.ArticleFilter{models.FilterArticlesByTag("even")}, nil, 3},
+	} {
+		res, total, err := repo.List(tc.offset, tc.limit, tc.filters...)
+		if err != nil {
+			t.Fatalf("error on list articles: %s", err.Error())
+		}
+
+		if total != tc.total {
+			t.Errorf("offset %d and limit %d: expected total %d, but got %d", tc.offset, tc.limit, tc.total, total)
+		}
+
+		assertSlugs(t, tc.expected, res)
+	}
+}
+
+func testArticleTags(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	tags, err := repo.GetTags()
+	if err != nil {
+		t.Fatalf("error on get tags: %s", err.Error())
+	}
+
+	if tags == nil || len(tags) != 0 {
+		t.Errorf("expected empty list of tags, but got '%#v'", tags)
+	}
+
+	alice := addUser(t, userRepo, "alice")
+	addArticle(t, repo, "first", alice, "go", "sql")
+	addArticle(t, repo, "second", alice)
+	addArticle(t, repo, "third", alice, "db", "go", "api")
+
+	tags, err = repo.GetTags()
+	if err != nil {
+		t.Fatalf("error on get tags: %s", err.Error())
+	}
+
+	// tags are distinct and listed in order of first appearance
+	if !reflect.DeepEqual(tags, []string{"go", "sql", "db", "api"}) {
+		t.Errorf("expected tags 'go', 'sql', 'db' and 'api', but got '%v'", tags)
+	}
+}
+
+func testArticleIsolation(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	article := addArticle(t, repo, "first", alice, "go")
+	comment := addComment(t, repo, article.Slug, bob)
+	expected := getArticle(t, repo, article.Slug)
+
+	// mutating added and returned values must not change stored articles
+	article.Tags[0] = "changed"
+	article.Favorites[bob.ID] = true
+
+	res := getArticle(t, repo, article.Slug)
+	res.Tags[0] = "changed"
+	res.Favorites[bob.ID] = true
+	res.Comments[0].Body = "changed"
+
+	list, _, err := repo.List(0, 20)
+	if err != nil {
+		t.Fatalf("error on list articles: %s", err.Error())
+	}
+
+	list[0].Tags[0] = "changed"
+	list[0].Comments[0].Body = "changed"
+
+	assertArticle(t, expected, getArticle(t, repo, article.Slug))
+
+	if expected.Comments[0].Body != comment.Body {
+		t.Errorf("expected comment body '%s', but got '%s'", comment.Body, expected.Comments[0].Body)
+	}
+}
+
+func testArticleDeleteByAuthor(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addArticle(t, repo, "first", alice, "go")
+	second := addArticle(t, repo, "second", bob, "sql")
+	addComment(t, repo, first.Slug, bob)
+	addComment(t, repo, second.Slug, alice)
+	comment := addComment(t, repo, second.Slug, bob)
+
+	err := repo.AddFavoriteBySlug(first.Slug, bob.ID)
+	if err != nil {
+		t.Fatalf("error on add favorite: %s", err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		err = repo.DeleteByAuthorID(alice.ID)
+		if err != nil {
+			t.Fatalf("error on delete by author: %s", err.Error())
+		}
+	}
+
+	_, err = repo.GetBySlug(first.Slug)
+	assertError(t, err, &models.ArticleBySlugNotFoundError{})
+
+	second.Comments = []models.Comment{comment}
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+}
+
+func testArticleTransfer(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	carol := addUser(t, userRepo, "carol")
+	first := addArticle(t, repo, "first", alice)
+	second := addArticle(t, repo, "second", bob)
+	firstComments := []models.Comment{addComment(t, repo, first.Slug, bob), addComment(t, repo, first.Slug, alice)}
+	secondComments := []models.Comment{addComment(t, repo, second.Slug, alice)}
+
+	err := repo.TransferByAuthorID(alice.ID, carol.ID)
+	if err != nil {
+		t.Fatalf("error on transfer by author: %s", err.Error())
+	}
+
+	first.AuthorID = carol.ID
+	firstComments[1].AuthorID = carol.ID
+	first.Comments = firstComments
+	assertArticle(t, first, getArticle(t, repo, first.Slug))
+
+	secondComments[0].AuthorID = carol.ID
+	second.Comments = secondComments
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+}
+
+func testArticleRemoveFavorites(t *testing.T, userRepo models.UserRepository, repo models.ArticleRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addArticle(t, repo, "first", alice)
+	second := addArticle(t, repo, "second", alice)
+
+	for _, favorite := range []struct {
+		slug string
+		user models.User
+	}{{first.Slug, alice}, {first.Slug, bob}, {second.Slug, bob}} {
+		err := repo.AddFavoriteBySlug(favorite.slug, favorite.user.ID)
+		if err != nil {
+			t.Fatalf("error on add favorite: %s", err.Error())
+		}
+	}
+
+	err := repo.RemoveFavoritesByUserID(bob.ID)
+	if err != nil {
+		t.Fatalf("error on remove favorites by user: %s", err.Error())
+	}
+
+	first.Favorites = map[int]bool{alice.ID: true}
+	assertArticle(t, first, getArticle(t, repo, first.Slug))
+	assertArticle(t, second, getArticle(t, repo, second.Slug))
+}
+
+func addSession(t *testing.T, repo models.SessionRepository, id string, user models.User, expiresAt time.Time) models.Session {
+	t.Helper()
+
+	session := models.Session{
+		ID:               id,
+		UserID:           user.ID,
+		RefreshTokenHash: "hash of " + id,
+		CreatedAt:        now(),
+		RefreshedAt:      now(),
+		ExpiresAt:        expiresAt,
+		LastSeenAt:       now(),
+		UserAgent:        "agent of " + id,
+		IP:               "127.0.0.1",
+	}
+
+	err := repo.Add(session)
+	if err != nil {
+		t.Fatalf("error on add session: %s", err.Error())
+	}
+
+	return session
+}
+
+func getSession(t *testing.T, repo models.SessionRepository, id string) models.Session {
+	t.Helper()
+
+	session, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("error on get session by id: %s", err.Error())
+	}
+
+	return *session
+}
+
+func assertSession(t *testing.T, expected, actual models.Session) {
+	t.Helper()
+
+	if !expected.CreatedAt.Equal(actual.CreatedAt) || !expected.RefreshedAt.Equal(actual.RefreshedAt) || !expected.ExpiresAt.Equal(actual.ExpiresAt) {
+		t.Errorf("expected session times '%s', '%s' and '%s', but got '%s', '%s' and '%s'", expected.CreatedAt, expected.RefreshedAt, expected.ExpiresAt, actual.CreatedAt, actual.RefreshedAt, actual.ExpiresAt)
+	}
+
+	if !expected.LastSeenAt.Equal(actual.LastSeenAt) {
+		t.Errorf("expected session last seen at '%s', but got '%s'", expected.LastSeenAt, actual.LastSeenAt)
+	}
+
+	expected.CreatedAt, expected.RefreshedAt, expected.ExpiresAt, expected.LastSeenAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	actual.CreatedAt, actual.RefreshedAt, actual.ExpiresAt, actual.LastSeenAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+
+	if expected != actual {
+		t.Errorf("expected session '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func assertRevoked(t *testing.T, repo models.SessionRepository, jti string, expected bool) {
+	t.Helper()
+
+	revoked, err := repo.IsTokenIDRevoked(jti)
+	if err != nil {
+		t.Fatalf("error on check token id: %s", err.Error())
+	}
+
+	if revoked != expected {
+		t.Errorf("expected token id '%s' revoked to be %t, but got %t", jti, expected, revoked)
+	}
+}
+
+func testSessionGet(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	session := addSession(t, repo, "first", alice, now().Add(time.Hour))
+	addSession(t, repo, "second", alice, now().Add(time.Hour))
+
+	assertSession(t, session, getSession(t, repo, session.ID))
+}
+
+func testSessionNotFound(t *testing.T, _ models.UserRepository, repo models.SessionRepository) {
+	_, err := repo.GetByID("missing")
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+
+	err = repo.RefreshByID("missing", "hash", models.Session{RefreshedAt: now(), ExpiresAt: now()})
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+
+	err = repo.SeenByID("missing", now(), "agent", "127.0.0.1")
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+
+	err = repo.DeleteByID("missing")
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+}
+
+func testSessionUniqueOnAdd(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	addSession(t, repo, "first", alice, now().Add(time.Hour))
+
+	err := repo.Add(models.Session{ID: "first", UserID: alice.ID, CreatedAt: now(), RefreshedAt: now(), ExpiresAt: now()})
+	if err == nil {
+		t.Errorf("expected error on duplicate session id, but got nil")
+	}
+}
+
+func testSessionListByUser(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addSession(t, repo, "first", alice, now().Add(time.Hour))
+	second := addSession(t, repo, "second", alice, now().Add(time.Hour))
+	addSession(t, repo, "other", bob, now().Add(time.Hour))
+
+	first.LastSeenAt = now().Add(time.Minute)
+
+	err := repo.SeenByID(first.ID, first.LastSeenAt, first.UserAgent, first.IP)
+	if err != nil {
+		t.Fatalf("error on see session: %s", err.Error())
+	}
+
+	res, err := repo.ListByUserID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on list sessions by user id: %s", err.Error())
+	}
+
+	// latest seen first
+	expected := []models.Session{first, second}
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d sessions, but got %d", len(expected), len(res))
+	}
+
+	for i := range expected {
+		assertSession(t, expected[i], res[i])
+	}
+
+	res, err = repo.ListByUserID(alice.ID + bob.ID)
+	if err != nil {
+		t.Fatalf("error on list sessions by user id: %s", err.Error())
+	}
+
+	if len(res) != 0 {
+		t.Errorf("expected no sessions, but got %d", len(res))
+	}
+}
+
+func testSessionRefresh(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	session := addSession(t, repo, "first", alice, now().Add(time.Hour))
+
+	refreshed := session
+	refreshed.RefreshTokenHash = "new hash"
+	refreshed.RefreshedAt = now().Add(time.Minute)
+	refreshed.ExpiresAt = now().Add(2 * time.Hour)
+
+	// id, user and creation time are kept
+	err := repo.RefreshByID(session.ID, session.RefreshTokenHash, models.Session{
+		ID:               "changed",
+		UserID:           alice.ID + 1,
+		RefreshTokenHash: refreshed.RefreshTokenHash,
+		CreatedAt:        now().Add(time.Hour),
+		RefreshedAt:      refreshed.RefreshedAt,
+		ExpiresAt:        refreshed.ExpiresAt,
+	})
+	if err != nil {
+		t.Fatalf("error on refresh session: %s", err.Error())
+	}
+
+	assertSession(t, refreshed, getSession(t, repo, session.ID))
+
+	// refreshing with the previous hash again must fail and keep the session as is
+	err = repo.RefreshByID(session.ID, session.RefreshTokenHash, models.Session{RefreshTokenHash: "another hash", RefreshedAt: now(), ExpiresAt: now()})
+	assertError(t, err, &models.RefreshTokenMismatchError{})
+
+	assertSession(t, refreshed, getSession(t, repo, session.ID))
+}
+
+func testSessionSeen(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	session := addSession(t, repo, "first", alice, now().Add(time.Hour))
+	other := addSession(t, repo, "second", alice, now().Add(time.Hour))
+
+	seen := session
+	seen.LastSeenAt = now().Add(time.Minute)
+	seen.UserAgent = "another agent"
+	seen.IP = "::1"
+
+	err := repo.SeenByID(session.ID, seen.LastSeenAt, seen.UserAgent, seen.IP)
+	if err != nil {
+		t.Fatalf("error on see session: %s", err.Error())
+	}
+
+	assertSession(t, seen, getSession(t, repo, session.ID))
+	assertSession(t, other, getSession(t, repo, other.ID))
+
+	// refreshing keeps what is seen
+	seen.RefreshTokenHash = "new hash"
+	seen.RefreshedAt = now().Add(2 * time.Minute)
+
+	err = repo.RefreshByID(session.ID, session.RefreshTokenHash, models.Session{RefreshTokenHash: seen.RefreshTokenHash, RefreshedAt: seen.RefreshedAt, ExpiresAt: seen.ExpiresAt})
+	if err != nil {
+		t.Fatalf("error on refresh session: %s", err.Error())
+	}
+
+	assertSession(t, seen, getSession(t, repo, session.ID))
+}
+
+func testSessionDelete(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	session := addSession(t, repo, "first", alice, now().Add(time.Hour))
+	other := addSession(t, repo, "second", alice, now().Add(time.Hour))
+
+	err := repo.DeleteByID(session.ID)
+	if err != nil {
+		t.Fatalf("error on delete session: %s", err.Error())
+	}
+
+	_, err = repo.GetByID(session.ID)
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+
+	assertSession(t, other, getSession(t, repo, other.ID))
+}
+
+func testSessionDeleteByUser(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addSession(t, repo, "first", alice, now().Add(time.Hour))
+	second := addSession(t, repo, "second", alice, now().Add(time.Hour))
+	other := addSession(t, repo, "other", bob, now().Add(time.Hour))
+
+	err := repo.DeleteByUserID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on delete sessions of user: %s", err.Error())
+	}
+
+	for _, session := range []models.Session{first, second} {
+		_, err = repo.GetByID(session.ID)
+		assertError(t, err, &models.SessionByIDNotFoundError{})
+	}
+
+	assertSession(t, other, getSession(t, repo, other.ID))
+
+	// deleting sessions of a user without any is not an error
+	err = repo.DeleteByUserID(alice.ID)
+	if err != nil {
+		t.Errorf("expected no error on delete sessions of user again, but got '%s'", err.Error())
+	}
+}
+
+func testSessionDeleteExpired(t *testing.T, userRepo models.UserRepository, repo models.SessionRepository) {
+	alice := addUser(t, userRepo, "alice")
+	expired := addSession(t, repo, "expired", alice, now().Add(-time.Minute))
+	active := addSession(t, repo, "active", alice, now().Add(time.Hour))
+
+	err := repo.RevokeTokenID("expired", now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("error on revoke token id: %s", err.Error())
+	}
+
+	err = repo.RevokeTokenID("active", now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("error on revoke token id: %s", err.Error())
+	}
+
+	err = repo.DeleteExpired(now())
+	if err != nil {
+		t.Fatalf("error on delete expired: %s", err.Error())
+	}
+
+	_, err = repo.GetByID(expired.ID)
+	assertError(t, err, &models.SessionByIDNotFoundError{})
+
+	assertSession(t, active, getSession(t, repo, active.ID))
+	assertRevoked(t, repo, "expired", false)
+	assertRevoked(t, repo, "active", true)
+}
+
+func testSessionRevokeTokenID(t *testing.T, _ models.UserRepository, repo models.SessionRepository) {
+	assertRevoked(t, repo, "first", false)
+
+	err := repo.RevokeTokenID("first", now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("error on revoke token id: %s", err.Error())
+	}
+
+	// revoking again with an earlier expiry must not shorten the revocation
+	err = repo.RevokeTokenID("first", now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("error on revoke token id again: %s", err.Error())
+	}
+
+	err = repo.DeleteExpired(now())
+	if err != nil {
+		t.Fatalf("error on delete expired: %s", err.Error())
+	}
+
+	assertRevoked(t, repo, "first", true)
+	assertRevoked(t, repo, "second", false)
+}
+
+func addPersonalAccessToken(t *testing.T, repo models.PersonalAccessTokenRepository, id string, user models.User, createdAt time.Time, scopes ...string) models.PersonalAccessToken {
+	t.Helper()
+
+	token := models.PersonalAccessToken{
+		ID:        id,
+		UserID:    user.ID,
+		Name:      "token " + id,
+		Scopes:    scopes,
+		TokenHash: "hash of " + id,
+		CreatedAt: createdAt,
+	}
+
+	err := repo.Add(token)
+	if err != nil {
+		t.Fatalf("error on add personal access token: %s", err.Error())
+	}
+
+	return token
+}
+
+func getPersonalAccessToken(t *testing.T, repo models.PersonalAccessTokenRepository, id string) models.PersonalAccessToken {
+	t.Helper()
+
+	token, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("error on get personal access token by id: %s", err.Error())
+	}
+
+	return *token
+}
+
+func assertPersonalAccessToken(t *testing.T, expected, actual models.PersonalAccessToken) {
+	t.Helper()
+
+	if !expected.CreatedAt.Equal(actual.CreatedAt) || !expected.LastUsedAt.Equal(actual.LastUsedAt) {
+		t.Errorf("expected personal access token times '%s' and '%s', but got '%s' and '%s'", expected.CreatedAt, expected.LastUsedAt, actual.CreatedAt, actual.LastUsedAt)
+	}
+
+	expected.CreatedAt, expected.LastUsedAt = time.Time{}, time.Time{}
+	actual.CreatedAt, actual.LastUsedAt = time.Time{}, time.Time{}
+
+	if len(expected.Scopes) == 0 && len(actual.Scopes) == 0 {
+		expected.Scopes, actual.Scopes = nil, nil
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected personal access token '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func testPersonalAccessTokenGet(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addPersonalAccessToken(t, repo, "first", alice, now(), "read", "write:articles")
+	other := addPersonalAccessToken(t, repo, "second", alice, now())
+
+	assertPersonalAccessToken(t, token, getPersonalAccessToken(t, repo, token.ID))
+	assertPersonalAccessToken(t, other, getPersonalAccessToken(t, repo, other.ID))
+}
+
+func testPersonalAccessTokenNotFound(t *testing.T, _ models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	_, err := repo.GetByID("missing")
+	assertError(t, err, &models.PersonalAccessTokenByIDNotFoundError{})
+
+	err = repo.UpdateLastUsedAtByID("missing", now())
+	assertError(t, err, &models.PersonalAccessTokenByIDNotFoundError{})
+
+	err = repo.DeleteByID("missing")
+	assertError(t, err, &models.PersonalAccessTokenByIDNotFoundError{})
+}
+
+func testPersonalAccessTokenUniqueOnAdd(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	token := addPersonalAccessToken(t, repo, "first", alice, now())
+
+	for name, duplicate := range map[string]models.PersonalAccessToken{
+		"ID":   {ID: token.ID, UserID: bob.ID, Name: "other", CreatedAt: now()},
+		"Name": {ID: "other", UserID: alice.ID, Name: token.Name, CreatedAt: now()},
+	} {
+		err := repo.Add(duplicate)
+		if err == nil {
+			t.Errorf("%s: expected error on duplicate personal access token, but got nil", name)
+		}
+	}
+
+	// names are unique per user only
+	err := repo.Add(models.PersonalAccessToken{ID: "second", UserID: bob.ID, Name: token.Name, CreatedAt: now()})
+	if err != nil {
+		t.Errorf("expected no error on same name for another user, but got '%s'", err.Error())
+	}
+}
+
+func testPersonalAccessTokenList(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	carol := addUser(t, userRepo, "carol")
+	second := addPersonalAccessToken(t, repo, "b", alice, now().Add(time.Second), "read")
+	first := addPersonalAccessToken(t, repo, "c", alice, now())
+	addPersonalAccessToken(t, repo, "a", bob, now())
+
+	tokens, err := repo.ListByUserID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on list personal access tokens: %s", err.Error())
+	}
+
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 personal access tokens, but got %d", len(tokens))
+	}
+
+	// in order of creation
+	assertPersonalAccessToken(t, first, tokens[0])
+	assertPersonalAccessToken(t, second, tokens[1])
+
+	tokens, err = repo.ListByUserID(carol.ID)
+	if err != nil {
+		t.Fatalf("error on list personal access tokens: %s", err.Error())
+	}
+
+	if tokens == nil || len(tokens) != 0 {
+		t.Errorf("expected empty personal access tokens, but got '%v'", tokens)
+	}
+}
+
+func testPersonalAccessTokenLastUsedAt(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addPersonalAccessToken(t, repo, "first", alice, now(), "read")
+	other := addPersonalAccessToken(t, repo, "second", alice, now())
+
+	token.LastUsedAt = now().Add(time.Minute)
+
+	err := repo.UpdateLastUsedAtByID(token.ID, token.LastUsedAt)
+	if err != nil {
+		t.Fatalf("error on update last used at: %s", err.Error())
+	}
+
+	assertPersonalAccessToken(t, token, getPersonalAccessToken(t, repo, token.ID))
+	assertPersonalAccessToken(t, other, getPersonalAccessToken(t, repo, other.ID))
+}
+
+func testPersonalAccessTokenDelete(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addPersonalAccessToken(t, repo, "first", alice, now())
+	other := addPersonalAccessToken(t, repo, "second", alice, now())
+
+	err := repo.DeleteByID(token.ID)
+	if err != nil {
+		t.Fatalf("error on delete personal access token: %s", err.Error())
+	}
+
+	_, err = repo.GetByID(token.ID)
+	assertError(t, err, &models.PersonalAccessTokenByIDNotFoundError{})
+
+	assertPersonalAccessToken(t, other, getPersonalAccessToken(t, repo, other.ID))
+}
+
+func testPersonalAccessTokenIsolation(t *testing.T, userRepo models.UserRepository, repo models.PersonalAccessTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addPersonalAccessToken(t, repo, "first", alice, now(), "read")
+	expected := getPersonalAccessToken(t, repo, token.ID)
+
+	// mutating added and returned values must not change stored personal access tokens
+	token.Scopes[0] = "changed"
+
+	res := getPersonalAccessToken(t, repo, token.ID)
+	res.Scopes[0] = "changed"
+
+	list, err := repo.ListByUserID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on list personal access tokens: %s", err.Error())
+	}
+
+	list[0].Scopes[0] = "changed"
+
+	assertPersonalAccessToken(t, expected, getPersonalAccessToken(t, repo, token.ID))
+}
+
+func addOneTimeToken(t *testing.T, repo models.OneTimeTokenRepository, id string, user models.User, purpose models.TokenPurpose, expiresAt time.Time) models.OneTimeToken {
+	t.Helper()
+
+	token := models.OneTimeToken{
+		ID:        id,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: "hash of " + id,
+		CreatedAt: now(),
+		ExpiresAt: expiresAt,
+	}
+
+	err := repo.Add(token)
+	if err != nil {
+		t.Fatalf("error on add one time token: %s", err.Error())
+	}
+
+	return token
+}
+
+func getOneTimeToken(t *testing.T, repo models.OneTimeTokenRepository, id string) models.OneTimeToken {
+	t.Helper()
+
+	token, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("error on get one time token by id: %s", err.Error())
+	}
+
+	return *token
+}
+
+func assertOneTimeToken(t *testing.T, expected, actual models.OneTimeToken) {
+	t.Helper()
+
+	if !expected.CreatedAt.Equal(actual.CreatedAt) || !expected.ExpiresAt.Equal(actual.ExpiresAt) {
+		t.Errorf("expected one time token times '%s' and '%s', but got '%s' and '%s'", expected.CreatedAt, expected.ExpiresAt, actual.CreatedAt, actual.ExpiresAt)
+	}
+
+	expected.CreatedAt, expected.ExpiresAt = time.Time{}, time.Time{}
+	actual.CreatedAt, actual.ExpiresAt = time.Time{}, time.Time{}
+
+	if expected != actual {
+		t.Errorf("expected one time token '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func testOneTimeTokenGet(t *testing.T, userRepo models.UserRepository, repo models.OneTimeTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addOneTimeToken(t, repo, "first", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+	addOneTimeToken(t, repo, "second", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+
+	assertOneTimeToken(t, token, getOneTimeToken(t, repo, token.ID))
+}
+
+func testOneTimeTokenNotFound(t *testing.T, _ models.UserRepository, repo models.OneTimeTokenRepository) {
+	_, err := repo.GetByID("missing")
+	assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+
+	err = repo.DeleteByID("missing")
+	assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+}
+
+func testOneTimeTokenUniqueOnAdd(t *testing.T, userRepo models.UserRepository, repo models.OneTimeTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	addOneTimeToken(t, repo, "first", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+
+	err := repo.Add(models.OneTimeToken{ID: "first", UserID: alice.ID, Purpose: models.TokenPurposePasswordReset, CreatedAt: now(), ExpiresAt: now()})
+	if err == nil {
+		t.Errorf("expected error on duplicate one time token id, but got nil")
+	}
+}
+
+func testOneTimeTokenDelete(t *testing.T, userRepo models.UserRepository, repo models.OneTimeTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	token := addOneTimeToken(t, repo, "first", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+	other := addOneTimeToken(t, repo, "second", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+
+	err := repo.DeleteByID(token.ID)
+	if err != nil {
+		t.Fatalf("error on delete one time token: %s", err.Error())
+	}
+
+	// a token can be used up once only
+	err = repo.DeleteByID(token.ID)
+	assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+
+	_, err = repo.GetByID(token.ID)
+	assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+
+	assertOneTimeToken(t, other, getOneTimeToken(t, repo, other.ID))
+}
+
+func testOneTimeTokenDeleteByUser(t *testing.T, userRepo models.UserRepository, repo models.OneTimeTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	first := addOneTimeToken(t, repo, "first", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+	second := addOneTimeToken(t, repo, "second", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+	otherPurpose := addOneTimeToken(t, repo, "other purpose", alice, "other", now().Add(time.Hour))
+	otherUser := addOneTimeToken(t, repo, "other user", bob, models.TokenPurposePasswordReset, now().Add(time.Hour))
+
+	err := repo.DeleteByUserID(alice.ID, models.TokenPurposePasswordReset)
+	if err != nil {
+		t.Fatalf("error on delete one time tokens of user: %s", err.Error())
+	}
+
+	for _, token := range []models.OneTimeToken{first, second} {
+		_, err = repo.GetByID(token.ID)
+		assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+	}
+
+	assertOneTimeToken(t, otherPurpose, getOneTimeToken(t, repo, otherPurpose.ID))
+	assertOneTimeToken(t, otherUser, getOneTimeToken(t, repo, otherUser.ID))
+}
+
+func testOneTimeTokenDeleteExpired(t *testing.T, userRepo models.UserRepository, repo models.OneTimeTokenRepository) {
+	alice := addUser(t, userRepo, "alice")
+	expired := addOneTimeToken(t, repo, "expired", alice, models.TokenPurposePasswordReset, now().Add(-time.Minute))
+	active := addOneTimeToken(t, repo, "active", alice, models.TokenPurposePasswordReset, now().Add(time.Hour))
+
+	err := repo.DeleteExpired(now())
+	if err != nil {
+		t.Fatalf("error on delete expired: %s", err.Error())
+	}
+
+	_, err = repo.GetByID(expired.ID)
+	assertError(t, err, &models.OneTimeTokenByIDNotFoundError{})
+
+	assertOneTimeToken(t, active, getOneTimeToken(t, repo, active.ID))
+}
+
+func putTwoFactor(t *testing.T, repo models.TwoFactorRepository, user models.User, secret string, codeHashes ...string) models.TwoFactor {
+	t.Helper()
+
+	twoFactor := models.TwoFactor{
+		UserID:             user.ID,
+		Secret:             secret,
+		Enabled:            true,
+		LastUsedStep:       100,
+		RecoveryCodeHashes: codeHashes,
+	}
+
+	err := repo.Put(twoFactor)
+	if err != nil {
+		t.Fatalf("error on put two factor: %s", err.Error())
+	}
+
+	return twoFactor
+}
+
+func getTwoFactor(t *testing.T, repo models.TwoFactorRepository, userID int) models.TwoFactor {
+	t.Helper()
+
+	twoFactor, err := repo.GetByUserID(userID)
+	if err != nil {
+		t.Fatalf("error on get two factor by user id: %s", err.Error())
+	}
+
+	return *twoFactor
+}
+
+func assertTwoFactor(t *testing.T, expected, actual models.TwoFactor) {
+	t.Helper()
+
+	hashes := make([]string, len(expected.RecoveryCodeHashes))
+	copy(hashes, expected.RecoveryCodeHashes)
+	sort.Strings(hashes)
+
+	expected.RecoveryCodeHashes = hashes
+
+	if len(expected.RecoveryCodeHashes) == 0 && len(actual.RecoveryCodeHashes) == 0 {
+		expected.RecoveryCodeHashes, actual.RecoveryCodeHashes = nil, nil
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected two factor '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func testTwoFactorGet(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	twoFactor := putTwoFactor(t, repo, alice, "alice secret", "c", "a", "b")
+	other := putTwoFactor(t, repo, bob, "bob secret", "a")
+
+	// recovery code hashes are in order of hash
+	assertTwoFactor(t, twoFactor, getTwoFactor(t, repo, alice.ID))
+	assertTwoFactor(t, other, getTwoFactor(t, repo, bob.ID))
+}
+
+func testTwoFactorNotFound(t *testing.T, _ models.UserRepository, repo models.TwoFactorRepository) {
+	_, err := repo.GetByUserID(1)
+	assertError(t, err, &models.TwoFactorByUserIDNotFoundError{})
+
+	err = repo.UseStepByUserID(1, 1)
+	assertError(t, err, &models.TwoFactorByUserIDNotFoundError{})
+
+	err = repo.UseRecoveryCodeByUserID(1, "a")
+	assertError(t, err, &models.TwoFactorByUserIDNotFoundError{})
+
+	err = repo.DeleteByUserID(1)
+	assertError(t, err, &models.TwoFactorByUserIDNotFoundError{})
+}
+
+func testTwoFactorReplace(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	putTwoFactor(t, repo, alice, "old secret", "a", "b")
+
+	replaced := models.TwoFactor{UserID: alice.ID, Secret: "new secret", Enabled: false, LastUsedStep: 0}
+
+	err := repo.Put(replaced)
+	if err != nil {
+		t.Fatalf("error on replace two factor: %s", err.Error())
+	}
+
+	assertTwoFactor(t, replaced, getTwoFactor(t, repo, alice.ID))
+}
+
+func testTwoFactorUseStep(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	twoFactor := putTwoFactor(t, repo, alice, "secret")
+
+	err := repo.UseStepByUserID(alice.ID, twoFactor.LastUsedStep+1)
+	if err != nil {
+		t.Fatalf("error on use step: %s", err.Error())
+	}
+
+	// the same step and earlier ones can not be used anymore
+	for _, step := range []int64{twoFactor.LastUsedStep + 1, twoFactor.LastUsedStep} {
+		err = repo.UseStepByUserID(alice.ID, step)
+		assertError(t, err, &models.TOTPStepUsedError{})
+	}
+
+	twoFactor.LastUsedStep++
+	assertTwoFactor(t, twoFactor, getTwoFactor(t, repo, alice.ID))
+}
+
+func testTwoFactorUseRecoveryCode(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	twoFactor := putTwoFactor(t, repo, alice, "secret", "a", "b")
+	other := putTwoFactor(t, repo, bob, "secret", "a")
+
+	err := repo.UseRecoveryCodeByUserID(alice.ID, "a")
+	if err != nil {
+		t.Fatalf("error on use recovery code: %s", err.Error())
+	}
+
+	// a recovery code can be used once only, and codes of others can not be used
+	err = repo.UseRecoveryCodeByUserID(alice.ID, "a")
+	assertError(t, err, &models.RecoveryCodeNotFoundError{})
+
+	err = repo.UseRecoveryCodeByUserID(alice.ID, "c")
+	assertError(t, err, &models.RecoveryCodeNotFoundError{})
+
+	twoFactor.RecoveryCodeHashes = []string{"b"}
+	assertTwoFactor(t, twoFactor, getTwoFactor(t, repo, alice.ID))
+	assertTwoFactor(t, other, getTwoFactor(t, repo, bob.ID))
+}
+
+func testTwoFactorDelete(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	putTwoFactor(t, repo, alice, "secret", "a", "b")
+	other := putTwoFactor(t, repo, bob, "secret", "a")
+
+	err := repo.DeleteByUserID(alice.ID)
+	if err != nil {
+		t.Fatalf("error on delete two factor: %s", err.Error())
+	}
+
+	_, err = repo.GetByUserID(alice.ID)
+	assertError(t, err, &models.TwoFactorByUserIDNotFoundError{})
+
+	// recovery codes are deleted too
+	enrolled := models.TwoFactor{UserID: alice.ID, Secret: "new secret"}
+
+	err = repo.Put(enrolled)
+	if err != nil {
+		t.Fatalf("error on put two factor: %s", err.Error())
+	}
+
+	assertTwoFactor(t, enrolled, getTwoFactor(t, repo, alice.ID))
+	assertTwoFactor(t, other, getTwoFactor(t, repo, bob.ID))
+}
+
+func testTwoFactorIsolation(t *testing.T, userRepo models.UserRepository, repo models.TwoFactorRepository) {
+	alice := addUser(t, userRepo, "alice")
+	twoFactor := putTwoFactor(t, repo, alice, "secret", "a", "b")
+	expected := getTwoFactor(t, repo, alice.ID)
+
+	// mutating added and returned values must not change stored two factors
+	twoFactor.RecoveryCodeHashes[0] = "changed"
+
+	res := getTwoFactor(t, repo, alice.ID)
+	res.RecoveryCodeHashes[0] = "changed"
+
+	assertTwoFactor(t, expected, getTwoFactor(t, repo, alice.ID))
+}
+
+func addFailure(t *testing.T, repo models.LoginThrottleRepository, kind models.LoginThrottleKind, subject string, at time.Time) models.LoginThrottle {
+	t.Helper()
+
+	throttle, err := repo.AddFailure(kind, subject, at, at.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("error on add failure: %s", err.Error())
+	}
+
+	return *throttle
+}
+
+func getLoginThrottle(t *testing.T, repo models.LoginThrottleRepository, kind models.LoginThrottleKind, subject string) models.LoginThrottle {
+	t.Helper()
+
+	throttle, err := repo.GetByKindAndSubject(kind, subject)
+	if err != nil {
+		t.Fatalf("error on get login throttle by kind and subject: %s", err.Error())
+	}
+
+	return *throttle
+}
+
+func assertLoginThrottle(t *testing.T, expected, actual models.LoginThrottle) {
+	t.Helper()
+
+	if actual.Kind != expected.Kind || actual.Subject != expected.Subject || actual.Failures != expected.Failures ||
+		!actual.LastFailedAt.Equal(expected.LastFailedAt) || !actual.LockedUntil.Equal(expected.LockedUntil) {
+		t.Errorf("expected login throttle '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func lock(t *testing.T, repo models.LoginThrottleRepository, id string, kind models.LoginThrottleKind, subject string, at time.Time) models.Lockout {
+	t.Helper()
+
+	lockout := models.Lockout{
+		ID:        id,
+		Kind:      kind,
+		Subject:   subject,
+		Failures:  5,
+		CreatedAt: at,
+		ExpiresAt: at.Add(time.Minute),
+	}
+
+	err := repo.Lock(lockout)
+	if err != nil {
+		t.Fatalf("error on lock: %s", err.Error())
+	}
+
+	return lockout
+}
+
+func testLoginThrottleNotFound(t *testing.T, repo models.LoginThrottleRepository) {
+	_, err := repo.GetByKindAndSubject(models.LoginThrottleKindAccount, "alice@example.com")
+	assertError(t, err, &models.LoginThrottleByKindAndSubjectNotFoundError{})
+}
+
+func testLoginThrottleAddFailure(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at)
+	res := addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at.Add(time.Second))
+
+	expected := models.LoginThrottle{
+		Kind:         models.LoginThrottleKindAccount,
+		Subject:      "alice@example.com",
+		Failures:     2,
+		LastFailedAt: at.Add(time.Second),
+	}
+
+	assertLoginThrottle(t, expected, res)
+	assertLoginThrottle(t, expected, getLoginThrottle(t, repo, models.LoginThrottleKindAccount, "alice@example.com"))
+
+	// failures are counted by kind and subject
+	res = addFailure(t, repo, models.LoginThrottleKindIP, "alice@example.com", at)
+	if res.Failures != 1 {
+		t.Errorf("expected 1 failure of ip, but got %d", res.Failures)
+	}
+
+	res = addFailure(t, repo, models.LoginThrottleKindAccount, "bob@example.com", at)
+	if res.Failures != 1 {
+		t.Errorf("expected 1 failure of bob, but got %d", res.Failures)
+	}
+}
+
+func testLoginThrottleReset(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	addFailure(t, repo, models.LoginThrottleKindIP, "127.0.0.1", at)
+	addFailure(t, repo, models.LoginThrottleKindIP, "127.0.0.1", at)
+
+	// counting starts over if the last failure is before reset time
+	res, err := repo.AddFailure(models.LoginThrottleKindIP, "127.0.0.1", at.Add(2*time.Hour), at.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("error on add failure: %s", err.Error())
+	}
+
+	assertLoginThrottle(t, models.LoginThrottle{
+		Kind:         models.LoginThrottleKindIP,
+		Subject:      "127.0.0.1",
+		Failures:     1,
+		LastFailedAt: at.Add(2 * time.Hour),
+	}, *res)
+}
+
+func testLoginThrottleLock(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	throttle := addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at)
+	lockout := lock(t, repo, "first", models.LoginThrottleKindAccount, "alice@example.com", at)
+
+	throttle.LockedUntil = lockout.ExpiresAt
+	assertLoginThrottle(t, throttle, getLoginThrottle(t, repo, models.LoginThrottleKindAccount, "alice@example.com"))
+
+	// failures are still counted while locked
+	res := addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at.Add(time.Second))
+	if res.Failures != 2 || !res.LockedUntil.Equal(lockout.ExpiresAt) {
+		t.Errorf("expected 2 failures locked until '%s', but got '%+v'", lockout.ExpiresAt, res)
+	}
+
+	// subjects without failures can be locked too
+	lockout = lock(t, repo, "second", models.LoginThrottleKindIP, "127.0.0.1", at)
+
+	res = addFailure(t, repo, models.LoginThrottleKindIP, "127.0.0.1", at.Add(time.Second))
+	if !res.LockedUntil.Equal(lockout.ExpiresAt) {
+		t.Errorf("expected ip locked until '%s', but got '%s'", lockout.ExpiresAt, res.LockedUntil)
+	}
+
+	err := repo.Lock(models.Lockout{ID: "first", Kind: models.LoginThrottleKindIP, Subject: "127.0.0.2", CreatedAt: at, ExpiresAt: at})
+	if err == nil {
+		t.Errorf("expected error on lock with existing id, but got nil")
+	}
+}
+
+func testLoginThrottleDelete(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at)
+	other := addFailure(t, repo, models.LoginThrottleKindAccount, "bob@example.com", at)
+	lock(t, repo, "lockout", models.LoginThrottleKindAccount, "alice@example.com", at)
+
+	err := repo.DeleteByKindAndSubject(models.LoginThrottleKindAccount, "alice@example.com")
+	if err != nil {
+		t.Fatalf("error on delete login throttle: %s", err.Error())
+	}
+
+	_, err = repo.GetByKindAndSubject(models.LoginThrottleKindAccount, "alice@example.com")
+	assertError(t, err, &models.LoginThrottleByKindAndSubjectNotFoundError{})
+
+	assertLoginThrottle(t, other, getLoginThrottle(t, repo, models.LoginThrottleKindAccount, "bob@example.com"))
+
+	// deleting nothing is not an error, and lockouts are kept as records
+	err = repo.DeleteByKindAndSubject(models.LoginThrottleKindAccount, "alice@example.com")
+	if err != nil {
+		t.Errorf("error on delete missing login throttle: %s", err.Error())
+	}
+
+	_, total, err := repo.ListLockouts(0, 20)
+	if err != nil {
+		t.Fatalf("error on list lockouts: %s", err.Error())
+	}
+
+	if total != 1 {
+		t.Errorf("expected 1 lockout, but got %d", total)
+	}
+}
+
+func testLoginThrottleListLockouts(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	var expected []models.Lockout
+	for i := 0; i < 5; i++ {
+		lockout := lock(t, repo, fmt.Sprintf("lockout%d", i), models.LoginThrottleKindIP, fmt.Sprintf("127.0.0.%d", i), at.Add(time.Duration(i)*time.Second))
+		expected = append([]models.Lockout{lockout}, expected...)
+	}
+
+	for _, page := range []struct{ offset, limit int }{{0, 20}, {0, 2}, {2, 2}, {4, 2}, {10, 2}} {
+		res, total, err := repo.ListLockouts(page.offset, page.limit)
+		if err != nil {
+			t.Fatalf("error on list lockouts: %s", err.Error())
+		}
+
+		if total != len(expected) {
+			t.Errorf("expected total %d, but got %d", len(expected), total)
+		}
+
+		// latest first
+		from, to := page.offset, page.offset+page.limit
+		if from > len(expected) {
+			from = len(expected)
+		}
+
+		if to > len(expected) {
+			to = len(expected)
+		}
+
+		if len(res) != to-from {
+			t.Errorf("expected %d lockouts at offset %d, but got %d", to-from, page.offset, len(res))
+			continue
+		}
+
+		for i := range res {
+			want := expected[from+i]
+			if res[i].ID != want.ID || res[i].Kind != want.Kind || res[i].Subject != want.Subject || res[i].Failures != want.Failures ||
+				!res[i].CreatedAt.Equal(want.CreatedAt) || !res[i].ExpiresAt.Equal(want.ExpiresAt) {
+				t.Errorf("expected lockout '%+v' at %d, but got '%+v'", want, from+i, res[i])
+			}
+		}
+	}
+}
+
+func testLoginThrottleUnlock(t *testing.T, repo models.LoginThrottleRepository) {
+	at := now()
+
+	addFailure(t, repo, models.LoginThrottleKindAccount, "alice@example.com", at)
+	lock(t, repo, "first", models.LoginThrottleKindAccount, "alice@example.com", at.Add(-time.Hour))
+	lock(t, repo, "second", models.LoginThrottleKindAccount, "alice@example.com", at)
+	other := lock(t, repo, "other", models.LoginThrottleKindAccount, "bob@example.com", at)
+
+	err := repo.Unlock("first", at.Add(time.Second))
+	if err != nil {
+		t.Fatalf("error on unlock: %s", err.Error())
+	}
+
+	_, err = repo.GetByKindAndSubject(models.LoginThrottleKindAccount, "alice@example.com")
+	assertError(t, err, &models.LoginThrottleByKindAndSubjectNotFoundError{})
+
+	// active lockouts of the subject end at unlock, while ended ones and ones of other subjects are kept as they are
+	res, _, err := repo.ListLockouts(0, 20)
+	if err != nil {
+		t.Fatalf("error on list lockouts: %s", err.Error())
+	}
+
+	expected := map[string]time.Time{
+		"first":  at.Add(-time.Hour).Add(time.Minute),
+		"second": at.Add(time.Second),
+		"other":  other.ExpiresAt,
+	}
+
+	for _, lockout := range res {
+		if !lockout.ExpiresAt.Equal(expected[lockout.ID]) {
+			t.Errorf("expected lockout '%s' expiring at '%s', but got '%s'", lockout.ID, expected[lockout.ID], lockout.ExpiresAt)
+		}
+	}
+
+	if throttle := getLoginThrottle(t, repo, models.LoginThrottleKindAccount, "bob@example.com"); !throttle.LockedUntil.Equal(other.ExpiresAt) {
+		t.Errorf("expected bob locked until '%s', but got '%s'", other.ExpiresAt, throttle.LockedUntil)
+	}
+
+	err = repo.Unlock("unknown", at)
+	assertError(t, err, &models.LockoutByIDNotFoundError{})
+}
+
+func addIdentity(t *testing.T, repo models.IdentityRepository, issuer, subject string, user models.User) models.Identity {
+	t.Helper()
+
+	identity := models.Identity{
+		Issuer:    issuer,
+		Subject:   subject,
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now(),
+	}
+
+	err := repo.Add(identity)
+	if err != nil {
+		t.Fatalf("error on add identity: %s", err.Error())
+	}
+
+	return identity
+}
+
+func getIdentity(t *testing.T, repo models.IdentityRepository, issuer, subject string) models.Identity {
+	t.Helper()
+
+	identity, err := repo.GetByIssuerAndSubject(issuer, subject)
+	if err != nil {
+		t.Fatalf("error on get identity by issuer and subject: %s", err.Error())
+	}
+
+	return *identity
+}
+
+func assertIdentity(t *testing.T, expected, actual models.Identity) {
+	t.Helper()
+
+	if actual.Issuer != expected.Issuer || actual.Subject != expected.Subject || actual.UserID != expected.UserID ||
+		actual.Email != expected.Email || !actual.CreatedAt.Equal(expected.CreatedAt) {
+		t.Errorf("expected identity '%+v', but got '%+v'", expected, actual)
+	}
+}
+
+func testIdentityGet(t *testing.T, userRepo models.UserRepository, repo models.IdentityRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+
+	identity := addIdentity(t, repo, "https://sso.example.com", "1", alice)
+	other := addIdentity(t, repo, "https://other.example.com", "1", bob)
+	same := addIdentity(t, repo, "https://other.example.com", "2", alice)
+
+	// subjects are unique per issuer only
+	assertIdentity(t, identity, getIdentity(t, repo, "https://sso.example.com", "1"))
+	assertIdentity(t, other, getIdentity(t, repo, "https://other.example.com", "1"))
+	assertIdentity(t, same, getIdentity(t, repo, "https://other.example.com", "2"))
+}
+
+func testIdentityNotFound(t *testing.T, userRepo models.UserRepository, repo models.IdentityRepository) {
+	alice := addUser(t, userRepo, "alice")
+	addIdentity(t, repo, "https://sso.example.com", "1", alice)
+
+	_, err := repo.GetByIssuerAndSubject("https://sso.example.com", "2")
+	assertError(t, err, &models.IdentityByIssuerAndSubjectNotFoundError{})
+
+	_, err = repo.GetByIssuerAndSubject("https://other.example.com", "1")
+	assertError(t, err, &models.IdentityByIssuerAndSubjectNotFoundError{})
+}
+
+func testIdentityUniqueOnAdd(t *testing.T, userRepo models.UserRepository, repo models.IdentityRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	identity := addIdentity(t, repo, "https://sso.example.com", "1", alice)
+
+	// a subject links to one user, and a user links to one subject of each issuer
+	for name, entity := range map[string]models.Identity{
+		"Subject": {Issuer: "https://sso.example.com", Subject: "1", UserID: bob.ID, Email: bob.Email, CreatedAt: now()},
+		"User":    {Issuer: "https://sso.example.com", Subject: "2", UserID: alice.ID, Email: alice.Email, CreatedAt: now()},
+	} {
+		err := repo.Add(entity)
+		if err == nil {
+			t.Errorf("%s: expected error on add duplicate identity, but got nil", name)
+		}
+	}
+
+	assertIdentity(t, identity, getIdentity(t, repo, "https://sso.example.com", "1"))
+}
+
+func testIdentityDeleteByUser(t *testing.T, userRepo models.UserRepository, repo models.IdentityRepository) {
+	alice := addUser(t, userRepo, "alice")
+	bob := addUser(t, userRepo, "bob")
+	addIdentity(t, repo, "https://sso.example.com", "1", alice)
+	addIdentity(t, repo, "https://other.example.com", "1", alice)
+	identity := addIdentity(t, repo, "https://sso.example.com", "2", bob)
+
+	for i := 0; i < 2; i++ {
+		err := repo.DeleteByUserID(alice.ID)
+		if err != nil {
+			t.Fatalf("error on delete identities by user: %s", err.Error())
+		}
+	}
+
+	for _, issuer := range []string{"https://sso.example.com", "https://other.example.com"} {
+		_, err := repo.GetByIssuerAndSubject(issuer, "1")
+		assertError(t, err, &models.IdentityByIssuerAndSubjectNotFoundError{})
+	}
+
+	assertIdentity(t, identity, getIdentity(t, repo, "https://sso.example.com", "2"))
+}
@@ -0,0 +1,447 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+type articleRepo struct {
+	db *sql.DB
+}
+
+func NewArticleRepository(db *sql.DB) models.ArticleRepository {
+	return &articleRepo{
+		db: db,
+	}
+}
+
+func (repo *articleRepo) List(offset, limit int, filters ...models.ArticleFilter) ([]models.Article, int, error) {
+	// filters are opaque functions, so they are applied after loading articles
+	res, err := repo.query(`1 = 1`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, filter := range filters {
+		res = filter(res)
+	}
+
+	total := len(res)
+
+	// negative offset and limit are of bad requests, so they are clamped instead of panicking on slicing
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit < 0 {
+		limit = 0
+	}
+
+	if offset > total {
+		offset = total
+	}
+
+	if offset+limit > total {
+		limit = total - offset
+	}
+
+	return res[offset : offset+limit], total, nil
+}
+
+func (repo *articleRepo) GetBySlug(slug string) (*models.Article, error) {
+	res, err := repo.query(`a.slug = ?`, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) == 0 {
+		return nil, models.ArticleBySlugNotFoundError{Slug: slug}
+	}
+
+	return &res[0], nil
+}
+
+func (repo *articleRepo) Add(entity models.Article) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRow(
+			`INSERT INTO articles (slug, title, description, body, created_at, updated_at, author_id) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			entity.Slug, entity.Title, entity.Description, entity.Body, entity.CreatedAt, entity.UpdatedAt, entity.AuthorID,
+		).Scan(&id)
+		if err != nil {
+			return articleWriteError(err, entity)
+		}
+
+		return insertArticleRelations(tx, id, entity)
+	})
+}
+
+func (repo *articleRepo) UpdateBySlug(slug string, entity models.Article) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRow(
+			`UPDATE articles SET slug = ?, title = ?, description = ?, body = ?, created_at = ?, updated_at = ?, author_id = ? WHERE slug = ? RETURNING id`,
+			entity.Slug, entity.Title, entity.Description, entity.Body, entity.CreatedAt, entity.UpdatedAt, entity.AuthorID, slug,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.ArticleBySlugNotFoundError{Slug: slug}
+			}
+
+			return articleWriteError(err, entity)
+		}
+
+		_, err = tx.Exec(`DELETE FROM article_tags WHERE article_id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("error on delete tags: %w", err)
+		}
+
+		return insertTags(tx, id, entity.Tags)
+	})
+}
+
+func (repo *articleRepo) DeleteBySlug(slug string) error {
+	res, err := repo.db.Exec(`DELETE FROM articles WHERE slug = ?`, slug)
+	if err != nil {
+		return fmt.Errorf("error on delete article: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.ArticleBySlugNotFoundError{Slug: slug}
+	}
+
+	return nil
+}
+
+func (repo *articleRepo) NewCommentID() (int, error) {
+	return nextValue(repo.db, "comments")
+}
+
+func (repo *articleRepo) AddFavoriteBySlug(slug string, userID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		id, err := articleID(tx, slug)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO favorites (article_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, userID)
+		if err != nil {
+			return fmt.Errorf("error on insert favorite: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *articleRepo) RemoveFavoriteBySlug(slug string, userID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		id, err := articleID(tx, slug)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM favorites WHERE article_id = ? AND user_id = ?`, id, userID)
+		if err != nil {
+			return fmt.Errorf("error on delete favorite: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *articleRepo) AddCommentBySlug(slug string, comment models.Comment) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		id, err := articleID(tx, slug)
+		if err != nil {
+			return err
+		}
+
+		return insertComments(tx, id, comment)
+	})
+}
+
+func (repo *articleRepo) DeleteCommentBySlug(slug string, commentID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		id, err := articleID(tx, slug)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(`DELETE FROM comments WHERE id = ? AND article_id = ?`, commentID, id)
+		if err != nil {
+			return fmt.Errorf("error on delete comment: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected == 0 {
+			return models.CommentByIDNotFoundError{ArticleSlug: slug, ID: commentID}
+		}
+
+		return nil
+	})
+}
+
+func (repo *articleRepo) GetTags() ([]string, error) {
+	rows, err := repo.db.Query(`SELECT tag FROM (SELECT tag, ROW_NUMBER() OVER (ORDER BY article_id, position) AS n FROM article_tags) AS t GROUP BY tag ORDER BY MIN(n)`)
+	if err != nil {
+		return nil, fmt.Errorf("error on query tags: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	res := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		err = rows.Scan(&tag)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan tag: %w", err)
+		}
+
+		res = append(res, tag)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate tags: %w", err)
+	}
+
+	return res, nil
+}
+
+// query loads articles matching the where clause on articles "a" with their tags, favorites and comments
+func (repo *articleRepo) query(where string, args ...interface{}) ([]models.Article, error) {
+	res := make([]models.Article, 0)
+	index := make(map[int]int)
+
+	err := repo.each(`SELECT a.id, a.slug, a.title, a.description, a.body, a.created_at, a.updated_at, a.author_id FROM articles a WHERE `+where+` ORDER BY a.id`, args, func(rows *sql.Rows) error {
+		var (
+			id      int
+			article models.Article
+		)
+
+		err := rows.Scan(&id, &article.Slug, &article.Title, &article.Description, &article.Body, &article.CreatedAt, &article.UpdatedAt, &article.AuthorID)
+		if err != nil {
+			return fmt.Errorf("error on scan article: %w", err)
+		}
+
+		article.Tags = make([]string, 0)
+		article.Favorites = make(map[int]bool)
+		article.Comments = make([]models.Comment, 0)
+
+		index[id] = len(res)
+		res = append(res, article)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) == 0 {
+		return res, nil
+	}
+
+	err = repo.each(`SELECT t.article_id, t.tag FROM article_tags t JOIN articles a ON a.id = t.article_id WHERE `+where+` ORDER BY t.article_id, t.position`, args, func(rows *sql.Rows) error {
+		var (
+			id  int
+			tag string
+		)
+
+		err := rows.Scan(&id, &tag)
+		if err != nil {
+			return fmt.Errorf("error on scan tag: %w", err)
+		}
+
+		res[index[id]].Tags = append(res[index[id]].Tags, tag)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.each(`SELECT f.article_id, f.user_id FROM favorites f JOIN articles a ON a.id = f.article_id WHERE `+where, args, func(rows *sql.Rows) error {
+		var id, userID int
+
+		err := rows.Scan(&id, &userID)
+		if err != nil {
+			return fmt.Errorf("error on scan favorite: %w", err)
+		}
+
+		res[index[id]].Favorites[userID] = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.each(`SELECT c.article_id, c.id, c.created_at, c.updated_at, c.body, c.author_id FROM comments c JOIN articles a ON a.id = c.article_id WHERE `+where+` ORDER BY c.id`, args, func(rows *sql.Rows) error {
+		var (
+			id      int
+			comment models.Comment
+		)
+
+		err := rows.Scan(&id, &comment.ID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Body, &comment.AuthorID)
+		if err != nil {
+			return fmt.Errorf("error on scan comment: %w", err)
+		}
+
+		res[index[id]].Comments = append(res[index[id]].Comments, comment)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteByAuthorID deletes comments of author, and articles of author with tags, favorites and comments of others on them
+func (repo *articleRepo) DeleteByAuthorID(authorID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM comments WHERE author_id = ?`, authorID)
+		if err != nil {
+			return fmt.Errorf("error on delete comments of author: %w", err)
+		}
+
+		// tags, favorites and comments of articles are deleted by cascade
+		_, err = tx.Exec(`DELETE FROM articles WHERE author_id = ?`, authorID)
+		if err != nil {
+			return fmt.Errorf("error on delete articles of author: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *articleRepo) TransferByAuthorID(authorID, newAuthorID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE articles SET author_id = ? WHERE author_id = ?`, newAuthorID, authorID)
+		if err != nil {
+			return fmt.Errorf("error on update author of articles: %w", err)
+		}
+
+		_, err = tx.Exec(`UPDATE comments SET author_id = ? WHERE author_id = ?`, newAuthorID, authorID)
+		if err != nil {
+			return fmt.Errorf("error on update author of comments: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *articleRepo) RemoveFavoritesByUserID(userID int) error {
+	_, err := repo.db.Exec(`DELETE FROM favorites WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete favorites of user: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *articleRepo) each(query string, args []interface{}, fn func(rows *sql.Rows) error) error {
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error on query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		err = fn(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("error on iterate rows: %w", err)
+	}
+
+	return nil
+}
+
+func insertArticleRelations(tx *sql.Tx, id int, entity models.Article) error {
+	err := insertTags(tx, id, entity.Tags)
+	if err != nil {
+		return err
+	}
+
+	for userID, favorited := range entity.Favorites {
+		if !favorited {
+			continue
+		}
+
+		_, err = tx.Exec(`INSERT INTO favorites (article_id, user_id) VALUES (?, ?)`, id, userID)
+		if err != nil {
+			return fmt.Errorf("error on insert favorite: %w", err)
+		}
+	}
+
+	return insertComments(tx, id, entity.Comments...)
+}
+
+func insertTags(tx *sql.Tx, id int, tags []string) error {
+	for i, tag := range tags {
+		_, err := tx.Exec(`INSERT INTO article_tags (article_id, position, tag) VALUES (?, ?, ?)`, id, i, tag)
+		if err != nil {
+			return fmt.Errorf("error on insert tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func insertComments(tx *sql.Tx, id int, comments ...models.Comment) error {
+	for _, comment := range comments {
+		_, err := tx.Exec(
+			`INSERT INTO comments (id, article_id, created_at, updated_at, body, author_id) VALUES (?, ?, ?, ?, ?, ?)`,
+			comment.ID, id, comment.CreatedAt, comment.UpdatedAt, comment.Body, comment.AuthorID,
+		)
+		if err != nil {
+			column, ok := uniqueViolation(err)
+			if ok && column == "comments.id" {
+				return fmt.Errorf("comment with id '%d' already exists", comment.ID)
+			}
+
+			return fmt.Errorf("error on insert comment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func articleID(tx *sql.Tx, slug string) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM articles WHERE slug = ?`, slug).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ArticleBySlugNotFoundError{Slug: slug}
+		}
+
+		return 0, fmt.Errorf("error on get article id: %w", err)
+	}
+
+	return id, nil
+}
+
+func articleWriteError(err error, entity models.Article) error {
+	column, ok := uniqueViolation(err)
+	if ok && column == "articles.slug" {
+		return fmt.Errorf("article with slug '%s' already exists", entity.Slug)
+	}
+
+	return fmt.Errorf("error on write article: %w", err)
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type identityRepo struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) models.IdentityRepository {
+	return &identityRepo{
+		db: db,
+	}
+}
+
+func (repo *identityRepo) Add(entity models.Identity) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO identities (issuer, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)`,
+		entity.Issuer, entity.Subject, entity.UserID, entity.Email, entity.CreatedAt.UnixMicro(),
+	)
+	if err != nil {
+		column, ok := uniqueViolation(err)
+		if !ok {
+			return fmt.Errorf("error on insert identity: %w", err)
+		}
+
+		// columns of a composite constraint are listed separated by comma
+		switch column {
+		case "identities.issuer,":
+			return fmt.Errorf("identity with subject '%s' of issuer '%s' already exists", entity.Subject, entity.Issuer)
+		case "identities.user_id,":
+			return fmt.Errorf("identity of user with id '%d' of issuer '%s' already exists", entity.UserID, entity.Issuer)
+		default:
+			return fmt.Errorf("error on insert identity: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (repo *identityRepo) GetByIssuerAndSubject(issuer, subject string) (*models.Identity, error) {
+	var (
+		identity  models.Identity
+		createdAt int64
+	)
+
+	err := repo.db.QueryRow(
+		`SELECT issuer, subject, user_id, email, created_at FROM identities WHERE issuer = ? AND subject = ?`, issuer, subject,
+	).Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.Email, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.IdentityByIssuerAndSubjectNotFoundError{Issuer: issuer, Subject: subject}
+		}
+
+		return nil, fmt.Errorf("error on get identity: %w", err)
+	}
+
+	identity.CreatedAt = time.UnixMicro(createdAt)
+
+	return &identity, nil
+}
+
+func (repo *identityRepo) DeleteByUserID(userID int) error {
+	_, err := repo.db.Exec(`DELETE FROM identities WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete identities of user: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type oneTimeTokenRepo struct {
+	db *sql.DB
+}
+
+func NewOneTimeTokenRepository(db *sql.DB) models.OneTimeTokenRepository {
+	return &oneTimeTokenRepo{
+		db: db,
+	}
+}
+
+func (repo *oneTimeTokenRepo) Add(entity models.OneTimeToken) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO one_time_tokens (id, user_id, purpose, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		entity.ID, entity.UserID, entity.Purpose, entity.TokenHash, entity.CreatedAt.UnixMicro(), entity.ExpiresAt.UnixMicro(),
+	)
+	if err != nil {
+		column, ok := uniqueViolation(err)
+		if ok && column == "one_time_tokens.id" {
+			return fmt.Errorf("one time token with id '%s' already exists", entity.ID)
+		}
+
+		return fmt.Errorf("error on insert one time token: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) GetByID(id string) (*models.OneTimeToken, error) {
+	var (
+		token                models.OneTimeToken
+		createdAt, expiresAt int64
+	)
+
+	err := repo.db.QueryRow(
+		`SELECT id, user_id, purpose, token_hash, created_at, expires_at FROM one_time_tokens WHERE id = ?`, id,
+	).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.OneTimeTokenByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get one time token: %w", err)
+	}
+
+	token.CreatedAt = time.UnixMicro(createdAt)
+	token.ExpiresAt = time.UnixMicro(expiresAt)
+
+	return &token, nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete one time token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.OneTimeTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteByUserID(userID int, purpose models.TokenPurpose) error {
+	_, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE user_id = ? AND purpose = ?`, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("error on delete one time tokens of user: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *oneTimeTokenRepo) DeleteExpired(now time.Time) error {
+	_, err := repo.db.Exec(`DELETE FROM one_time_tokens WHERE expires_at < ?`, now.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("error on delete expired one time tokens: %w", err)
+	}
+
+	return nil
+}
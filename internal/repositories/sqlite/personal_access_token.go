@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"strings"
+	"time"
+)
+
+type personalAccessTokenRepo struct {
+	db *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) models.PersonalAccessTokenRepository {
+	return &personalAccessTokenRepo{
+		db: db,
+	}
+}
+
+func (repo *personalAccessTokenRepo) Add(entity models.PersonalAccessToken) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO personal_access_tokens (id, user_id, name, scopes, token_hash, created_at, last_used_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entity.ID, entity.UserID, entity.Name, strings.Join(entity.Scopes, " "), entity.TokenHash, entity.CreatedAt.UnixMicro(), nullUnixMicro(entity.LastUsedAt),
+	)
+	if err != nil {
+		column, ok := uniqueViolation(err)
+		if !ok {
+			return fmt.Errorf("error on insert personal access token: %w", err)
+		}
+
+		// columns of a composite constraint are listed separated by comma
+		switch column {
+		case "personal_access_tokens.id":
+			return fmt.Errorf("personal access token with id '%s' already exists", entity.ID)
+		case "personal_access_tokens.user_id,":
+			return fmt.Errorf("personal access token with name '%s' already exists", entity.Name)
+		default:
+			return fmt.Errorf("error on insert personal access token: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (repo *personalAccessTokenRepo) GetByID(id string) (*models.PersonalAccessToken, error) {
+	token, err := scanPersonalAccessToken(repo.db.QueryRow(
+		`SELECT id, user_id, name, scopes, token_hash, created_at, last_used_at FROM personal_access_tokens WHERE id = ?`, id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.PersonalAccessTokenByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get personal access token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (repo *personalAccessTokenRepo) ListByUserID(userID int) ([]models.PersonalAccessToken, error) {
+	rows, err := repo.db.Query(
+		`SELECT id, user_id, name, scopes, token_hash, created_at, last_used_at FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at, id`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on query personal access tokens: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	res := make([]models.PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *token)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate personal access tokens: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *personalAccessTokenRepo) UpdateLastUsedAtByID(id string, lastUsedAt time.Time) error {
+	res, err := repo.db.Exec(`UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`, lastUsedAt.UnixMicro(), id)
+	if err != nil {
+		return fmt.Errorf("error on update personal access token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *personalAccessTokenRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM personal_access_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete personal access token: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.PersonalAccessTokenByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func scanPersonalAccessToken(row scanner) (*models.PersonalAccessToken, error) {
+	var (
+		token      models.PersonalAccessToken
+		scopes     string
+		createdAt  int64
+		lastUsedAt sql.NullInt64
+	)
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.TokenHash, &createdAt, &lastUsedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error on scan personal access token: %w", err)
+	}
+
+	// scopes are stored space separated, like the scope parameter of OAuth 2.0
+	token.Scopes = strings.Fields(scopes)
+	if token.Scopes == nil {
+		token.Scopes = []string{}
+	}
+
+	token.CreatedAt = time.UnixMicro(createdAt)
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = time.UnixMicro(lastUsedAt.Int64)
+	}
+
+	return &token, nil
+}
+
+func nullUnixMicro(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixMicro(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+	"time"
+)
+
+type sessionRepo struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) models.SessionRepository {
+	return &sessionRepo{
+		db: db,
+	}
+}
+
+const sessionColumns = `id, user_id, refresh_token_hash, created_at, refreshed_at, expires_at, last_seen_at, user_agent, ip`
+
+func (repo *sessionRepo) Add(entity models.Session) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entity.ID, entity.UserID, entity.RefreshTokenHash, entity.CreatedAt.UnixMicro(), entity.RefreshedAt.UnixMicro(), entity.ExpiresAt.UnixMicro(),
+		entity.LastSeenAt.UnixMicro(), entity.UserAgent, entity.IP,
+	)
+	if err != nil {
+		column, ok := uniqueViolation(err)
+		if ok && column == "sessions.id" {
+			return fmt.Errorf("session with id '%s' already exists", entity.ID)
+		}
+
+		return fmt.Errorf("error on insert session: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) GetByID(id string) (*models.Session, error) {
+	session, err := scanSession(repo.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.SessionByIDNotFoundError{ID: id}
+		}
+
+		return nil, fmt.Errorf("error on get session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (repo *sessionRepo) ListByUserID(userID int) ([]models.Session, error) {
+	rows, err := repo.db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE user_id = ? ORDER BY last_seen_at DESC, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error on query sessions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	res := make([]models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan session: %w", err)
+		}
+
+		res = append(res, *session)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate sessions: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *sessionRepo) RefreshByID(id, refreshTokenHash string, entity models.Session) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			`UPDATE sessions SET refresh_token_hash = ?, refreshed_at = ?, expires_at = ? WHERE id = ? AND refresh_token_hash = ?`,
+			entity.RefreshTokenHash, entity.RefreshedAt.UnixMicro(), entity.ExpiresAt.UnixMicro(), id, refreshTokenHash,
+		)
+		if err != nil {
+			return fmt.Errorf("error on update session: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected > 0 {
+			return nil
+		}
+
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ?)`, id).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error on check session existence: %w", err)
+		}
+
+		if !exists {
+			return models.SessionByIDNotFoundError{ID: id}
+		}
+
+		return models.RefreshTokenMismatchError{SessionID: id}
+	})
+}
+
+func (repo *sessionRepo) SeenByID(id string, lastSeenAt time.Time, userAgent, ip string) error {
+	res, err := repo.db.Exec(
+		`UPDATE sessions SET last_seen_at = ?, user_agent = ?, ip = ? WHERE id = ?`,
+		lastSeenAt.UnixMicro(), userAgent, ip, id,
+	)
+	if err != nil {
+		return fmt.Errorf("error on update session: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByID(id string) error {
+	res, err := repo.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete session: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.SessionByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteByUserID(userID int) error {
+	_, err := repo.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete sessions of user: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) DeleteExpired(now time.Time) error {
+	_, err := repo.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, now.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("error on delete expired sessions: %w", err)
+	}
+
+	_, err = repo.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < ?`, now.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("error on delete expired revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) RevokeTokenID(jti string, expiresAt time.Time) error {
+	_, err := repo.db.Exec(
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?) ON CONFLICT (jti) DO UPDATE SET expires_at = MAX(revoked_tokens.expires_at, excluded.expires_at)`,
+		jti, expiresAt.UnixMicro(),
+	)
+	if err != nil {
+		return fmt.Errorf("error on insert revoked token: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) IsTokenIDRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := repo.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("error on check revoked token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+func scanSession(row scanner) (*models.Session, error) {
+	var (
+		session                                       models.Session
+		createdAt, refreshedAt, expiresAt, lastSeenAt int64
+	)
+
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.RefreshTokenHash, &createdAt, &refreshedAt, &expiresAt,
+		&lastSeenAt, &session.UserAgent, &session.IP,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	session.CreatedAt = time.UnixMicro(createdAt)
+	session.RefreshedAt = time.UnixMicro(refreshedAt)
+	session.ExpiresAt = time.UnixMicro(expiresAt)
+	session.LastSeenAt = time.UnixMicro(lastSeenAt)
+
+	return &session, nil
+}
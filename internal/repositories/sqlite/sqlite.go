@@ -0,0 +1,280 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"net/url"
+	"strings"
+)
+
+// migrations are applied in order and each one exactly once, so existing entries must never be edited
+var migrations = []string{
+	`CREATE TABLE sequences (
+		name  TEXT    NOT NULL PRIMARY KEY,
+		value INTEGER NOT NULL
+	);
+
+	INSERT INTO sequences (name, value) VALUES ('users', 0), ('comments', 0);
+
+	CREATE TABLE users (
+		id       INTEGER NOT NULL PRIMARY KEY,
+		email    TEXT    NOT NULL UNIQUE,
+		token    TEXT    UNIQUE,
+		username TEXT    NOT NULL UNIQUE,
+		password TEXT    NOT NULL,
+		bio      TEXT    NOT NULL DEFAULT '',
+		image    TEXT    NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE follows (
+		followee_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		follower_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		PRIMARY KEY (followee_id, follower_id)
+	);
+
+	CREATE INDEX follows_follower_id_idx ON follows (follower_id);
+
+	CREATE TABLE articles (
+		id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+		slug        TEXT     NOT NULL UNIQUE,
+		title       TEXT     NOT NULL,
+		description TEXT     NOT NULL,
+		body        TEXT     NOT NULL,
+		created_at  DATETIME NOT NULL,
+		updated_at  DATETIME NOT NULL,
+		author_id   INTEGER  NOT NULL REFERENCES users (id)
+	);
+
+	CREATE TABLE article_tags (
+		article_id INTEGER NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		tag        TEXT    NOT NULL,
+		PRIMARY KEY (article_id, position)
+	);
+
+	CREATE INDEX article_tags_tag_idx ON article_tags (tag);
+
+	CREATE TABLE favorites (
+		article_id INTEGER NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		PRIMARY KEY (article_id, user_id)
+	);
+
+	CREATE TABLE comments (
+		id         INTEGER  NOT NULL PRIMARY KEY,
+		article_id INTEGER  NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		body       TEXT     NOT NULL,
+		author_id  INTEGER  NOT NULL REFERENCES users (id)
+	);
+
+	CREATE INDEX comments_article_id_idx ON comments (article_id);`,
+
+	// tokens are issued per response with an expiration instead of being stored,
+	// but SQLite cannot drop a unique column without rebuilding every table referencing users, so it is only cleared
+	`UPDATE users SET token = NULL;`,
+
+	// times are unix microseconds, since they are compared in queries and text times do not sort
+	`CREATE TABLE sessions (
+		id                 TEXT    NOT NULL PRIMARY KEY,
+		user_id            INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		refresh_token_hash TEXT    NOT NULL,
+		created_at         INTEGER NOT NULL,
+		refreshed_at       INTEGER NOT NULL,
+		expires_at         INTEGER NOT NULL
+	);
+
+	CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+	CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+	CREATE TABLE revoked_tokens (
+		jti        TEXT    NOT NULL PRIMARY KEY,
+		expires_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);`,
+
+	`CREATE TABLE personal_access_tokens (
+		id           TEXT    NOT NULL PRIMARY KEY,
+		user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		name         TEXT    NOT NULL,
+		scopes       TEXT    NOT NULL,
+		token_hash   TEXT    NOT NULL,
+		created_at   INTEGER NOT NULL,
+		last_used_at INTEGER,
+		UNIQUE (user_id, name)
+	);`,
+
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';`,
+
+	`CREATE TABLE one_time_tokens (
+		id         TEXT    NOT NULL PRIMARY KEY,
+		user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		purpose    TEXT    NOT NULL,
+		token_hash TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX one_time_tokens_user_id_idx ON one_time_tokens (user_id);
+
+	CREATE INDEX one_time_tokens_expires_at_idx ON one_time_tokens (expires_at);`,
+
+	`ALTER TABLE users ADD COLUMN verified INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE two_factors (
+		user_id        INTEGER NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+		secret         TEXT    NOT NULL,
+		enabled        INTEGER NOT NULL,
+		last_used_step INTEGER NOT NULL
+	);
+
+	CREATE TABLE recovery_codes (
+		user_id   INTEGER NOT NULL REFERENCES two_factors (user_id) ON DELETE CASCADE,
+		code_hash TEXT    NOT NULL,
+		PRIMARY KEY (user_id, code_hash)
+	);`,
+
+	`CREATE TABLE login_throttles (
+		kind           TEXT    NOT NULL,
+		subject        TEXT    NOT NULL,
+		failures       INTEGER NOT NULL,
+		last_failed_at INTEGER NOT NULL,
+		locked_until   INTEGER,
+		PRIMARY KEY (kind, subject)
+	);
+
+	CREATE TABLE lockouts (
+		id         TEXT    NOT NULL PRIMARY KEY,
+		kind       TEXT    NOT NULL,
+		subject    TEXT    NOT NULL,
+		failures   INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX lockouts_created_at_idx ON lockouts (created_at);`,
+
+	`CREATE TABLE identities (
+		issuer     TEXT    NOT NULL,
+		subject    TEXT    NOT NULL,
+		user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		email      TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (issuer, subject),
+		UNIQUE (user_id, issuer)
+	);`,
+
+	// sessions started before are last seen when refreshed, as far as it is known
+	`ALTER TABLE sessions ADD COLUMN last_seen_at INTEGER NOT NULL DEFAULT 0;
+
+	ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+	ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+
+	UPDATE sessions SET last_seen_at = refreshed_at;`,
+}
+
+// Open opens the database file at path, creating it if it does not exist
+func Open(path string) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?%s", path, query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error on open sqlite database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Migrate creates or upgrades the database schema used by the repositories of this package
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("error on create schema migrations table: %w", err)
+	}
+
+	return withTx(db, func(tx *sql.Tx) error {
+		var current int
+		err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+		if err != nil {
+			return fmt.Errorf("error on get schema version: %w", err)
+		}
+
+		for i := current; i < len(migrations); i++ {
+			_, err = tx.Exec(migrations[i])
+			if err != nil {
+				return fmt.Errorf("error on apply migration %d: %w", i+1, err)
+			}
+
+			_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1)
+			if err != nil {
+				return fmt.Errorf("error on record migration %d: %w", i+1, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error on begin transaction: %w", err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error on commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func nextValue(db *sql.DB, sequence string) (int, error) {
+	var value int
+	err := db.QueryRow(`UPDATE sequences SET value = value + 1 WHERE name = ? RETURNING value`, sequence).Scan(&value)
+	if err != nil {
+		return 0, fmt.Errorf("error on get next value of sequence '%s': %w", sequence, err)
+	}
+
+	return value, nil
+}
+
+// uniqueViolation reports the violated "table.column" of a unique or primary key constraint error
+func uniqueViolation(err error) (column string, ok bool) {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return "", false
+	}
+
+	if code := sqliteErr.Code(); code != sqlite3.SQLITE_CONSTRAINT_UNIQUE && code != sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return "", false
+	}
+
+	const prefix = "constraint failed: "
+	msg := sqliteErr.Error()
+	if i := strings.LastIndex(msg, prefix); i >= 0 {
+		column = msg[i+len(prefix):]
+		if j := strings.Index(column, " "); j >= 0 {
+			column = column[:j]
+		}
+	}
+
+	return column, true
+}
@@ -0,0 +1,50 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/repotest"
+	"github.com/nasermirzaei89/realworld-go/internal/repositories/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB creates and migrates a database file in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "realworld.db"))
+	if err != nil {
+		t.Fatalf("error on open database: %s", err.Error())
+	}
+
+	err = sqlite.Migrate(db)
+	if err != nil {
+		t.Fatalf("error on migrate database: %s", err.Error())
+	}
+
+	// migrating twice should be a no-op
+	err = sqlite.Migrate(db)
+	if err != nil {
+		t.Fatalf("error on migrate database again: %s", err.Error())
+	}
+
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		db := newTestDB(t)
+		t.Cleanup(func() { _ = db.Close() })
+
+		return repotest.Repositories{
+			Users:                sqlite.NewUserRepository(db),
+			Articles:             sqlite.NewArticleRepository(db),
+			Sessions:             sqlite.NewSessionRepository(db),
+			PersonalAccessTokens: sqlite.NewPersonalAccessTokenRepository(db),
+			OneTimeTokens:        sqlite.NewOneTimeTokenRepository(db),
+			TwoFactors:           sqlite.NewTwoFactorRepository(db),
+			LoginThrottles:       sqlite.NewLoginThrottleRepository(db),
+			Identities:           sqlite.NewIdentityRepository(db),
+		}
+	})
+}
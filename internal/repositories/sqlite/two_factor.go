@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+type twoFactorRepo struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) models.TwoFactorRepository {
+	return &twoFactorRepo{
+		db: db,
+	}
+}
+
+func (repo *twoFactorRepo) Put(entity models.TwoFactor) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			`INSERT INTO two_factors (user_id, secret, enabled, last_used_step) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, enabled = excluded.enabled, last_used_step = excluded.last_used_step`,
+			entity.UserID, entity.Secret, entity.Enabled, entity.LastUsedStep,
+		)
+		if err != nil {
+			return fmt.Errorf("error on upsert two factor: %w", err)
+		}
+
+		_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, entity.UserID)
+		if err != nil {
+			return fmt.Errorf("error on delete recovery codes: %w", err)
+		}
+
+		for _, codeHash := range entity.RecoveryCodeHashes {
+			_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?) ON CONFLICT DO NOTHING`, entity.UserID, codeHash)
+			if err != nil {
+				return fmt.Errorf("error on insert recovery code: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *twoFactorRepo) GetByUserID(userID int) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := repo.db.QueryRow(
+		`SELECT user_id, secret, enabled, last_used_step FROM two_factors WHERE user_id = ?`, userID,
+	).Scan(&twoFactor.UserID, &twoFactor.Secret, &twoFactor.Enabled, &twoFactor.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.TwoFactorByUserIDNotFoundError{UserID: userID}
+		}
+
+		return nil, fmt.Errorf("error on get two factor: %w", err)
+	}
+
+	rows, err := repo.db.Query(`SELECT code_hash FROM recovery_codes WHERE user_id = ? ORDER BY code_hash`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error on query recovery codes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	twoFactor.RecoveryCodeHashes = make([]string, 0)
+	for rows.Next() {
+		var codeHash string
+		err = rows.Scan(&codeHash)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan recovery code: %w", err)
+		}
+
+		twoFactor.RecoveryCodeHashes = append(twoFactor.RecoveryCodeHashes, codeHash)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate recovery codes: %w", err)
+	}
+
+	return &twoFactor, nil
+}
+
+func (repo *twoFactorRepo) UseStepByUserID(userID int, step int64) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE two_factors SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`, step, userID, step)
+		if err != nil {
+			return fmt.Errorf("error on update two factor: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected > 0 {
+			return nil
+		}
+
+		return repo.notFoundOr(tx, userID, models.TOTPStepUsedError{UserID: userID, Step: step})
+	})
+}
+
+func (repo *twoFactorRepo) UseRecoveryCodeByUserID(userID int, codeHash string) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`, userID, codeHash)
+		if err != nil {
+			return fmt.Errorf("error on delete recovery code: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected > 0 {
+			return nil
+		}
+
+		return repo.notFoundOr(tx, userID, models.RecoveryCodeNotFoundError{UserID: userID})
+	})
+}
+
+func (repo *twoFactorRepo) DeleteByUserID(userID int) error {
+	res, err := repo.db.Exec(`DELETE FROM two_factors WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("error on delete two factor: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	return nil
+}
+
+// notFoundOr returns not found error if user has no two factor, otherwise err
+func (repo *twoFactorRepo) notFoundOr(tx *sql.Tx, userID int, err error) error {
+	var exists bool
+	e := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM two_factors WHERE user_id = ?)`, userID).Scan(&exists)
+	if e != nil {
+		return fmt.Errorf("error on check two factor existence: %w", e)
+	}
+
+	if !exists {
+		return models.TwoFactorByUserIDNotFoundError{UserID: userID}
+	}
+
+	return err
+}
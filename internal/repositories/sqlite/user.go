@@ -0,0 +1,279 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/internal/models"
+)
+
+type userRepo struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) models.UserRepository {
+	return &userRepo{
+		db: db,
+	}
+}
+
+const userColumns = `id, email, username, password, bio, image, role, verified`
+
+func (repo *userRepo) NewID() (int, error) {
+	return nextValue(repo.db, "users")
+}
+
+func (repo *userRepo) GetByEmail(email string) (*models.User, error) {
+	user, err := repo.get(`SELECT `+userColumns+` FROM users WHERE email = ?`, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.UserByEmailNotFoundError{Email: email}
+	}
+
+	return user, err
+}
+
+func (repo *userRepo) GetByUsername(username string) (*models.User, error) {
+	user, err := repo.get(`SELECT `+userColumns+` FROM users WHERE username = ?`, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.UserByUsernameNotFoundError{Username: username}
+	}
+
+	return user, err
+}
+
+func (repo *userRepo) GetByID(id int) (*models.User, error) {
+	user, err := repo.get(`SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.UserByIDNotFoundError{ID: id}
+	}
+
+	return user, err
+}
+
+func (repo *userRepo) Add(entity models.User) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			`INSERT INTO users (id, email, username, password, bio, image, role, verified) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			entity.ID, entity.Email, entity.Username, entity.Password, entity.Bio, entity.Image, entity.Role, entity.Verified,
+		)
+		if err != nil {
+			return userWriteError(err, entity)
+		}
+
+		return insertFollowers(tx, entity)
+	})
+}
+
+func (repo *userRepo) UpdateByID(id int, entity models.User) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			`UPDATE users SET email = ?, username = ?, password = ?, bio = ?, image = ?, role = ?, verified = ? WHERE id = ?`,
+			entity.Email, entity.Username, entity.Password, entity.Bio, entity.Image, entity.Role, entity.Verified, id,
+		)
+		if err != nil {
+			return userWriteError(err, entity)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error on get affected rows: %w", err)
+		}
+
+		if affected == 0 {
+			return models.UserByIDNotFoundError{ID: id}
+		}
+
+		return nil
+	})
+}
+
+func (repo *userRepo) AddFollowerByID(id, followerID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		err := userExists(tx, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO follows (followee_id, follower_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, followerID)
+		if err != nil {
+			return fmt.Errorf("error on insert follower: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *userRepo) RemoveFollowerByID(id, followerID int) error {
+	return withTx(repo.db, func(tx *sql.Tx) error {
+		err := userExists(tx, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM follows WHERE followee_id = ? AND follower_id = ?`, id, followerID)
+		if err != nil {
+			return fmt.Errorf("error on delete follower: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *userRepo) ListByFollowedBy(userID int) ([]models.User, error) {
+	rows, err := repo.db.Query(
+		`SELECT `+userColumns+` FROM users WHERE id IN (SELECT followee_id FROM follows WHERE follower_id = ?) ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error on query users: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate users: %w", err)
+	}
+
+	for i := range res {
+		res[i].Followers, err = repo.getFollowers(res[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (repo *userRepo) DeleteByID(id int) error {
+	// follows are deleted by cascade
+	res, err := repo.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error on delete user: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error on get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return models.UserByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func (repo *userRepo) get(query string, args ...interface{}) (*models.User, error) {
+	user, err := scanUser(repo.db.QueryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	user.Followers, err = repo.getFollowers(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (repo *userRepo) getFollowers(userID int) (map[int]bool, error) {
+	rows, err := repo.db.Query(`SELECT follower_id FROM follows WHERE followee_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error on query followers: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	res := make(map[int]bool)
+	for rows.Next() {
+		var followerID int
+		err = rows.Scan(&followerID)
+		if err != nil {
+			return nil, fmt.Errorf("error on scan follower: %w", err)
+		}
+
+		res[followerID] = true
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterate followers: %w", err)
+	}
+
+	return res, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Bio, &user.Image, &user.Role, &user.Verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("error on scan user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func userExists(tx *sql.Tx, id int) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error on check user existence: %w", err)
+	}
+
+	if !exists {
+		return models.UserByIDNotFoundError{ID: id}
+	}
+
+	return nil
+}
+
+func insertFollowers(tx *sql.Tx, entity models.User) error {
+	for followerID, following := range entity.Followers {
+		if !following {
+			continue
+		}
+
+		_, err := tx.Exec(`INSERT INTO follows (followee_id, follower_id) VALUES (?, ?)`, entity.ID, followerID)
+		if err != nil {
+			return fmt.Errorf("error on insert follower: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func userWriteError(err error, entity models.User) error {
+	column, ok := uniqueViolation(err)
+	if !ok {
+		return fmt.Errorf("error on write user: %w", err)
+	}
+
+	switch column {
+	case "users.id":
+		return fmt.Errorf("user with id '%d' already exists", entity.ID)
+	case "users.email":
+		return fmt.Errorf("user with email '%s' already exists", entity.Email)
+	case "users.username":
+		return fmt.Errorf("user with username '%s' already exists", entity.Username)
+	default:
+		return fmt.Errorf("error on write user: %w", err)
+	}
+}
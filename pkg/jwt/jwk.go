@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is a public key in JWK format of RFC 7517
+type JSONWebKey struct {
+	KeyType   string    `json:"kty"`
+	KeyID     string    `json:"kid,omitempty"`
+	Use       string    `json:"use,omitempty"`
+	Algorithm Algorithm `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys in JWKS format of RFC 7517
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey returns public key of key in JWK format,
+// which is not possible for symmetric keys since they are secret
+func NewJSONWebKey(kid string, key VerificationKey) (JSONWebKey, error) {
+	res := JSONWebKey{
+		KeyID:     kid,
+		Use:       "sig",
+		Algorithm: key.Algorithm(),
+	}
+
+	switch k := key.(type) {
+	case *rsaPublicKey:
+		res.KeyType = "RSA"
+		res.N = encodeBase64(k.key.N.Bytes())
+		res.E = encodeBase64(big.NewInt(int64(k.key.E)).Bytes())
+	case *ecdsaPublicKey:
+		res.KeyType = "EC"
+		res.Curve = "P-256"
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		k.key.X.FillBytes(x)
+		k.key.Y.FillBytes(y)
+		res.X = encodeBase64(x)
+		res.Y = encodeBase64(y)
+	case *ed25519PublicKey:
+		res.KeyType = "OKP"
+		res.Curve = "Ed25519"
+		res.X = encodeBase64(k.key)
+	default:
+		return JSONWebKey{}, fmt.Errorf("%w: %s key can not be published", ErrInvalidKey, key.Algorithm())
+	}
+
+	return res, nil
+}
+
+// VerificationKey returns the key of jwk for verifying tokens
+func (k JSONWebKey) VerificationKey() (VerificationKey, error) {
+	var key VerificationKey
+
+	switch k.KeyType {
+	case "RSA":
+		n, e, err := decodeBigInts(k.N, k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: rsa exponent is too large", ErrInvalidKey)
+		}
+
+		key, err = NewRSAPublicKey(&rsa.PublicKey{N: n, E: int(e.Int64())})
+		if err != nil {
+			return nil, err
+		}
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidKey, k.Curve)
+		}
+
+		x, y, err := decodeBigInts(k.X, k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: point is not on curve", ErrInvalidKey)
+		}
+
+		key, err = NewECDSAPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+		if err != nil {
+			return nil, err
+		}
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidKey, k.Curve)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+
+		key, err = NewEd25519PublicKey(ed25519.PublicKey(x))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %s", ErrInvalidKey, k.KeyType)
+	}
+
+	if k.Algorithm != "" && k.Algorithm != key.Algorithm() {
+		return nil, fmt.Errorf("%w: algorithm %s does not match key type %s", ErrInvalidKey, k.Algorithm, k.KeyType)
+	}
+
+	return key, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of public key, which is a stable key id.
+// Symmetric keys have none, since their thumbprint would let anyone confirm guesses of the secret offline.
+func Thumbprint(key VerificationKey) (string, error) {
+	jwk, err := NewJSONWebKey("", key)
+	if err != nil {
+		return "", err
+	}
+
+	var members interface{}
+
+	// members are required ones only, in lexicographic order
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("error on marshal key: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return encodeBase64(sum[:]), nil
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBigInts(a, b string) (*big.Int, *big.Int, error) {
+	res := make([]*big.Int, 2)
+	for i, value := range []string{a, b} {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: bad key parameter", ErrInvalidKey)
+		}
+
+		res[i] = new(big.Int).SetBytes(data)
+	}
+
+	return res[0], res[1], nil
+}
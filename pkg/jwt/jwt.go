@@ -1,19 +1,21 @@
 package jwt
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 )
 
 // Header is json web token header
 type Header struct {
 	Algorithm Algorithm `json:"alg"`
 	Type      string    `json:"typ"`
+	KeyID     string    `json:"kid,omitempty"`
 }
 
 // Payload is json web token payload
@@ -21,8 +23,28 @@ type Payload map[string]interface{}
 
 // Token interface
 type Token interface {
+	SetIssuer(iss string)
+	GetIssuer() (string, error)
 	SetSubject(sub string)
 	GetSubject() (string, error)
+	SetAudience(aud ...string)
+	GetAudience() ([]string, error)
+	SetExpirationTime(exp time.Time)
+	GetExpirationTime() (time.Time, error)
+	SetNotBefore(nbf time.Time)
+	GetNotBefore() (time.Time, error)
+	SetIssuedAt(iat time.Time)
+	GetIssuedAt() (time.Time, error)
+	SetJWTID(jti string)
+	GetJWTID() (string, error)
+	// SetString sets a private claim of string type
+	SetString(name, value string)
+	// GetString returns a claim of string type
+	GetString(name string) (string, error)
+	// SetClaims sets claims from fields of v, which is encoded as a json object
+	SetClaims(v interface{}) error
+	// Claims decodes all claims into v, e.g. a struct with json tags for registered and private claims
+	Claims(v interface{}) error
 }
 
 // Algorithm type
@@ -31,18 +53,44 @@ type Algorithm string
 // Algorithms
 const (
 	HS256 Algorithm = "HS256"
+	RS256 Algorithm = "RS256"
+	ES256 Algorithm = "ES256"
+	EdDSA Algorithm = "EdDSA"
 )
 
+func (a Algorithm) supported() bool {
+	switch a {
+	case HS256, RS256, ES256, EdDSA:
+		return true
+	default:
+		return false
+	}
+}
+
 // Registered Claim Names
 const (
-	ClaimSubject = "sub"
+	ClaimIssuer         = "iss"
+	ClaimSubject        = "sub"
+	ClaimAudience       = "aud"
+	ClaimExpirationTime = "exp"
+	ClaimNotBefore      = "nbf"
+	ClaimIssuedAt       = "iat"
+	ClaimJWTID          = "jti"
 )
 
 var (
+	ErrMalformedToken        = errors.New("malformed token")
 	ErrClaimNotFound         = errors.New("claim not found")
 	ErrInvalidClaimType      = errors.New("invalid claim type")
 	ErrInvalidTokenSignature = errors.New("invalid token signature")
 	ErrUnsupportedAlgorithm  = errors.New("unsupported algorithm")
+	ErrAlgorithmMismatch     = errors.New("token algorithm does not match key")
+	ErrInvalidKey            = errors.New("invalid key")
+	ErrKeyNotFound           = errors.New("key not found")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrInvalidIssuer         = errors.New("invalid token issuer")
+	ErrInvalidAudience       = errors.New("invalid token audience")
 )
 
 type token struct {
@@ -58,22 +106,184 @@ func (t *token) GetPayload() Payload {
 	return t.payload
 }
 
+func (t *token) setKeyID(kid string) {
+	t.header.KeyID = kid
+}
+
+func (t *token) SetIssuer(iss string) {
+	t.payload[ClaimIssuer] = iss
+}
+
+func (t *token) GetIssuer() (string, error) {
+	return t.getString(ClaimIssuer)
+}
+
 func (t *token) SetSubject(sub string) {
 	t.payload[ClaimSubject] = sub
 }
 
 func (t *token) GetSubject() (string, error) {
-	value, ok := t.payload[ClaimSubject]
+	return t.getString(ClaimSubject)
+}
+
+// SetAudience sets a single audience as string and multiple ones as array
+func (t *token) SetAudience(aud ...string) {
+	if len(aud) == 1 {
+		t.payload[ClaimAudience] = aud[0]
+		return
+	}
+
+	t.payload[ClaimAudience] = aud
+}
+
+// GetAudience returns audience of the token which may be a string or an array of strings
+func (t *token) GetAudience() ([]string, error) {
+	value, ok := t.payload[ClaimAudience]
+	if !ok {
+		return nil, ErrClaimNotFound
+	}
+
+	switch aud := value.(type) {
+	case string:
+		return []string{aud}, nil
+	case []string:
+		return aud, nil
+	case []interface{}:
+		res := make([]string, len(aud))
+		for i := range aud {
+			s, ok := aud[i].(string)
+			if !ok {
+				return nil, ErrInvalidClaimType
+			}
+
+			res[i] = s
+		}
+
+		return res, nil
+	default:
+		return nil, ErrInvalidClaimType
+	}
+}
+
+func (t *token) SetExpirationTime(exp time.Time) {
+	t.payload[ClaimExpirationTime] = exp.Unix()
+}
+
+func (t *token) GetExpirationTime() (time.Time, error) {
+	return t.getTime(ClaimExpirationTime)
+}
+
+func (t *token) SetNotBefore(nbf time.Time) {
+	t.payload[ClaimNotBefore] = nbf.Unix()
+}
+
+func (t *token) GetNotBefore() (time.Time, error) {
+	return t.getTime(ClaimNotBefore)
+}
+
+func (t *token) SetIssuedAt(iat time.Time) {
+	t.payload[ClaimIssuedAt] = iat.Unix()
+}
+
+func (t *token) GetIssuedAt() (time.Time, error) {
+	return t.getTime(ClaimIssuedAt)
+}
+
+func (t *token) SetJWTID(jti string) {
+	t.payload[ClaimJWTID] = jti
+}
+
+func (t *token) GetJWTID() (string, error) {
+	return t.getString(ClaimJWTID)
+}
+
+func (t *token) SetString(name, value string) {
+	t.payload[name] = value
+}
+
+func (t *token) GetString(name string) (string, error) {
+	return t.getString(name)
+}
+
+func (t *token) SetClaims(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error on marshal claims: %w", err)
+	}
+
+	var claims Payload
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	err = dec.Decode(&claims)
+	if err != nil {
+		return fmt.Errorf("error on unmarshal claims: %w", err)
+	}
+
+	for name, value := range claims {
+		t.payload[name] = value
+	}
+
+	return nil
+}
+
+func (t *token) Claims(v interface{}) error {
+	data, err := json.Marshal(t.payload)
+	if err != nil {
+		return fmt.Errorf("error on marshal claims: %w", err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("error on unmarshal claims: %w", err)
+	}
+
+	return nil
+}
+
+func (t *token) getString(name string) (string, error) {
+	value, ok := t.payload[name]
 	if !ok {
 		return "", ErrClaimNotFound
 	}
 
-	sub, ok := value.(string)
+	res, ok := value.(string)
 	if !ok {
 		return "", ErrInvalidClaimType
 	}
 
-	return sub, nil
+	return res, nil
+}
+
+// getTime returns a NumericDate claim, which is seconds since epoch
+func (t *token) getTime(name string) (time.Time, error) {
+	value, ok := t.payload[name]
+	if !ok {
+		return time.Time{}, ErrClaimNotFound
+	}
+
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	case json.Number:
+		if sec, err := v.Int64(); err == nil {
+			return time.Unix(sec, 0), nil
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, ErrInvalidClaimType
+		}
+
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	default:
+		return time.Time{}, ErrInvalidClaimType
+	}
 }
 
 // New returns new json web token
@@ -87,9 +297,10 @@ func New() Token {
 	}
 }
 
-// Sign the token with secret key
-func Sign(t Token, key []byte) (string, error) {
+// Sign the token with key, setting the algorithm of header to the one of key
+func Sign(t Token, key SigningKey) (string, error) {
 	h := t.(interface{ GetHeader() Header }).GetHeader()
+	h.Algorithm = key.Algorithm()
 	header, err := json.Marshal(h)
 	if err != nil {
 		return "", fmt.Errorf("error on marshal header: %s", err.Error())
@@ -102,85 +313,271 @@ func Sign(t Token, key []byte) (string, error) {
 
 	unsignedToken := fmt.Sprintf("%s.%s", base64.RawURLEncoding.EncodeToString(header), base64.RawURLEncoding.EncodeToString(payload))
 
-	switch h.Algorithm {
-	case HS256:
-		mac := hmac.New(sha256.New, key)
-		_, _ = mac.Write([]byte(unsignedToken))
-		return fmt.Sprintf("%s.%s", unsignedToken, base64.RawURLEncoding.EncodeToString(mac.Sum(nil))), nil
-	default:
-		return "", ErrUnsupportedAlgorithm
+	sig, err := key.Sign([]byte(unsignedToken))
+	if err != nil {
+		return "", fmt.Errorf("error on sign token: %w", err)
 	}
+
+	return fmt.Sprintf("%s.%s", unsignedToken, base64.RawURLEncoding.EncodeToString(sig)), nil
 }
 
-// Verify token string with secret key
-func Verify(t string, key []byte) error {
-	arr := strings.Split(t, ".")
-	if len(arr) != 3 {
-		return errors.New("invalid token provided")
+// ParseAndVerify parses token string, verifies its signature with key and validates its registered claims,
+// so the returned token is always trusted. Errors can be checked with errors.Is against ErrMalformedToken,
+// ErrUnsupportedAlgorithm, ErrAlgorithmMismatch, ErrInvalidTokenSignature, ErrTokenExpired, ErrTokenNotValidYet and the like.
+func ParseAndVerify(t string, key VerificationKey, opts ...ValidateOption) (Token, error) {
+	tok, err := decode(t)
+	if err != nil {
+		return nil, err
 	}
 
-	tok := token{}
+	err = tok.verify(key)
+	if err != nil {
+		return nil, err
+	}
 
-	header, err := base64.RawURLEncoding.DecodeString(arr[0])
+	err = Validate(tok.token, opts...)
 	if err != nil {
-		return fmt.Errorf("invalid token header encoding: %s", err.Error())
+		return nil, err
 	}
 
-	err = json.Unmarshal(header, &tok.header)
+	return tok.token, nil
+}
+
+// KeyFunc returns the key to verify a token with by its header, e.g. by its kid from keys of another issuer
+type KeyFunc func(header Header) (VerificationKey, error)
+
+// ParseAndVerifyFunc is like ParseAndVerify, but verifies the token with the key keyFunc returns for its header
+func ParseAndVerifyFunc(t string, keyFunc KeyFunc, opts ...ValidateOption) (Token, error) {
+	tok, err := decode(t)
 	if err != nil {
-		return fmt.Errorf("invalid token header: %s", err.Error())
+		return nil, err
 	}
 
-	if typ := tok.header.Type; typ != "JWT" {
-		return fmt.Errorf("unsupported token type: %s", typ)
+	key, err := keyFunc(tok.header)
+	if err != nil {
+		return nil, err
 	}
 
-	switch tok.header.Algorithm {
-	case HS256:
-		mac := hmac.New(sha256.New, key)
-		_, _ = mac.Write([]byte(fmt.Sprintf("%s.%s", arr[0], arr[1])))
-		sig, err := base64.RawURLEncoding.DecodeString(arr[2])
-		if err != nil {
-			return fmt.Errorf("invalid token signature encoding: %s", err.Error())
-		}
-		if !hmac.Equal(mac.Sum(nil), sig) {
-			return ErrInvalidTokenSignature
-		}
+	err = tok.verify(key)
+	if err != nil {
+		return nil, err
+	}
 
-		return nil
-	default:
-		return ErrUnsupportedAlgorithm
+	err = Validate(tok.token, opts...)
+	if err != nil {
+		return nil, err
 	}
+
+	return tok.token, nil
+}
+
+// Verify token string with key, which only accepts tokens of its own algorithm
+//
+// Deprecated: Verify does not return the verified token, so it has to be parsed again; use ParseAndVerify instead.
+func Verify(t string, key VerificationKey) error {
+	tok, err := decode(t)
+	if err != nil {
+		return err
+	}
+
+	return tok.verify(key)
 }
 
 // Parse token string without verifying
+//
+// Deprecated: the returned token can not be trusted; use ParseAndVerify instead.
 func Parse(t string) (Token, error) {
+	tok, err := decode(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return tok.token, nil
+}
+
+// decodedToken is a parsed token which keeps its encoded parts for verifying the signature
+type decodedToken struct {
+	*token
+	signingInput []byte
+	signature    []byte
+}
+
+// decode token string once, both header and payload, without verifying it
+func decode(t string) (*decodedToken, error) {
 	arr := strings.Split(t, ".")
 	if len(arr) != 3 {
-		return nil, errors.New("invalid token provided")
+		return nil, fmt.Errorf("%w: token should have 3 parts", ErrMalformedToken)
 	}
 
 	tok := token{}
 
 	header, err := base64.RawURLEncoding.DecodeString(arr[0])
 	if err != nil {
-		return nil, fmt.Errorf("invalid token header encoding: %s", err.Error())
+		return nil, fmt.Errorf("%w: invalid token header encoding: %s", ErrMalformedToken, err.Error())
 	}
 
 	err = json.Unmarshal(header, &tok.header)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token header: %s", err.Error())
+		return nil, fmt.Errorf("%w: invalid token header: %s", ErrMalformedToken, err.Error())
 	}
 
 	payload, err := base64.RawURLEncoding.DecodeString(arr[1])
 	if err != nil {
-		return nil, fmt.Errorf("invalid token payload encoding: %s", err.Error())
+		return nil, fmt.Errorf("%w: invalid token payload encoding: %s", ErrMalformedToken, err.Error())
 	}
 
-	err = json.Unmarshal(payload, &tok.payload)
+	// numbers are kept as written, so Claims decodes them without losing precision
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+
+	err = dec.Decode(&tok.payload)
+	if err != nil || tok.payload == nil {
+		return nil, fmt.Errorf("%w: invalid token payload", ErrMalformedToken)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(arr[2])
 	if err != nil {
-		return nil, fmt.Errorf("invalid token payload: %s", err.Error())
+		return nil, fmt.Errorf("%w: invalid token signature encoding: %s", ErrMalformedToken, err.Error())
+	}
+
+	return &decodedToken{
+		token:        &tok,
+		signingInput: []byte(arr[0] + "." + arr[1]),
+		signature:    sig,
+	}, nil
+}
+
+func (t *decodedToken) verify(key VerificationKey) error {
+	// typ is optional, and other issuers may leave it out
+	if typ := t.header.Type; typ != "JWT" && typ != "" {
+		return fmt.Errorf("%w: unsupported token type: %s", ErrMalformedToken, typ)
+	}
+
+	if !t.header.Algorithm.supported() {
+		return ErrUnsupportedAlgorithm
+	}
+
+	// the header must not choose how the key is used, e.g. a public key as hmac secret
+	if t.header.Algorithm != key.Algorithm() {
+		return ErrAlgorithmMismatch
+	}
+
+	return key.Verify(t.signingInput, t.signature)
+}
+
+// ValidateOption configures Validate
+type ValidateOption func(v *validator)
+
+type validator struct {
+	now      time.Time
+	leeway   time.Duration
+	issuer   string
+	audience string
+	required []string
+}
+
+// WithTime sets the time to validate the token at instead of now
+func WithTime(now time.Time) ValidateOption {
+	return func(v *validator) {
+		v.now = now
+	}
+}
+
+// WithLeeway allows clock skew between issuer and validator
+func WithLeeway(leeway time.Duration) ValidateOption {
+	return func(v *validator) {
+		v.leeway = leeway
+	}
+}
+
+// WithIssuer requires the token to be issued by iss
+func WithIssuer(iss string) ValidateOption {
+	return func(v *validator) {
+		v.issuer = iss
+	}
+}
+
+// WithAudience requires aud to be one of the token audiences
+func WithAudience(aud string) ValidateOption {
+	return func(v *validator) {
+		v.audience = aud
+	}
+}
+
+// WithRequiredClaims requires the token to have claims of names, e.g. ClaimExpirationTime so it does not live forever
+func WithRequiredClaims(names ...string) ValidateOption {
+	return func(v *validator) {
+		v.required = append(v.required, names...)
+	}
+}
+
+// Validate registered claims of a verified token,
+// rejecting expired and not yet valid tokens with ErrTokenExpired and ErrTokenNotValidYet
+func Validate(t Token, opts ...ValidateOption) error {
+	v := validator{now: time.Now()}
+	for _, opt := range opts {
+		opt(&v)
+	}
+
+	payload := t.(interface{ GetPayload() Payload }).GetPayload()
+	for _, name := range v.required {
+		if _, ok := payload[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrClaimNotFound, name)
+		}
+	}
+
+	exp, err := t.GetExpirationTime()
+	if err != nil && !errors.Is(err, ErrClaimNotFound) {
+		return fmt.Errorf("invalid expiration time: %w", err)
+	}
+
+	if err == nil && !v.now.Before(exp.Add(v.leeway)) {
+		return ErrTokenExpired
+	}
+
+	nbf, err := t.GetNotBefore()
+	if err != nil && !errors.Is(err, ErrClaimNotFound) {
+		return fmt.Errorf("invalid not before: %w", err)
+	}
+
+	if err == nil && v.now.Add(v.leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+
+	iat, err := t.GetIssuedAt()
+	if err != nil && !errors.Is(err, ErrClaimNotFound) {
+		return fmt.Errorf("invalid issued at: %w", err)
+	}
+
+	if err == nil && v.now.Add(v.leeway).Before(iat) {
+		return ErrTokenNotValidYet
+	}
+
+	if v.issuer != "" {
+		iss, err := t.GetIssuer()
+		if err != nil || iss != v.issuer {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if v.audience != "" {
+		aud, err := t.GetAudience()
+		if err != nil {
+			return ErrInvalidAudience
+		}
+
+		found := false
+		for i := range aud {
+			if aud[i] == v.audience {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ErrInvalidAudience
+		}
 	}
 
-	return &tok, nil
+	return nil
 }
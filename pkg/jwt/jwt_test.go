@@ -0,0 +1,630 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTrip signs, verifies and parses token, so claims are read back as decoded from json
+func roundTrip(t *testing.T, token jwt.Token) jwt.Token {
+	t.Helper()
+
+	key := jwt.NewHMACKey([]byte("secret"))
+
+	tokenStr, err := jwt.Sign(token, key)
+	if err != nil {
+		t.Fatalf("error on sign token: %s", err.Error())
+	}
+
+	err = jwt.Verify(tokenStr, key.VerificationKey())
+	if err != nil {
+		t.Fatalf("error on verify token: %s", err.Error())
+	}
+
+	res, err := jwt.Parse(tokenStr)
+	if err != nil {
+		t.Fatalf("error on parse token: %s", err.Error())
+	}
+
+	return res
+}
+
+func TestClaims(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	token := jwt.New()
+	token.SetIssuer("realworld")
+	token.SetSubject("1")
+	token.SetAudience("web", "mobile")
+	token.SetExpirationTime(now.Add(time.Hour))
+	token.SetNotBefore(now)
+	token.SetIssuedAt(now)
+	token.SetJWTID("abc")
+	token.SetString("sid", "xyz")
+
+	for name, tok := range map[string]jwt.Token{"Before Sign": token, "After Parse": roundTrip(t, token)} {
+		if iss, err := tok.GetIssuer(); err != nil || iss != "realworld" {
+			t.Errorf("%s: expected issuer 'realworld', but got '%s' and '%v'", name, iss, err)
+		}
+
+		if sub, err := tok.GetSubject(); err != nil || sub != "1" {
+			t.Errorf("%s: expected subject '1', but got '%s' and '%v'", name, sub, err)
+		}
+
+		if aud, err := tok.GetAudience(); err != nil || !reflect.DeepEqual(aud, []string{"web", "mobile"}) {
+			t.Errorf("%s: expected audience 'web' and 'mobile', but got '%v' and '%v'", name, aud, err)
+		}
+
+		if exp, err := tok.GetExpirationTime(); err != nil || !exp.Equal(now.Add(time.Hour)) {
+			t.Errorf("%s: expected expiration time '%s', but got '%s' and '%v'", name, now.Add(time.Hour), exp, err)
+		}
+
+		if nbf, err := tok.GetNotBefore(); err != nil || !nbf.Equal(now) {
+			t.Errorf("%s: expected not before '%s', but got '%s' and '%v'", name, now, nbf, err)
+		}
+
+		if iat, err := tok.GetIssuedAt(); err != nil || !iat.Equal(now) {
+			t.Errorf("%s: expected issued at '%s', but got '%s' and '%v'", name, now, iat, err)
+		}
+
+		if jti, err := tok.GetJWTID(); err != nil || jti != "abc" {
+			t.Errorf("%s: expected jwt id 'abc', but got '%s' and '%v'", name, jti, err)
+		}
+
+		if sid, err := tok.GetString("sid"); err != nil || sid != "xyz" {
+			t.Errorf("%s: expected sid 'xyz', but got '%s' and '%v'", name, sid, err)
+		}
+	}
+
+	t.Run("Single Audience", func(t *testing.T) {
+		token := jwt.New()
+		token.SetAudience("web")
+
+		aud, err := roundTrip(t, token).GetAudience()
+		if err != nil || !reflect.DeepEqual(aud, []string{"web"}) {
+			t.Errorf("expected audience 'web', but got '%v' and '%v'", aud, err)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := jwt.New().GetExpirationTime()
+		if !errors.Is(err, jwt.ErrClaimNotFound) {
+			t.Errorf("expected error '%v', but got '%v'", jwt.ErrClaimNotFound, err)
+		}
+	})
+
+	t.Run("Invalid Type", func(t *testing.T) {
+		_, err := token.GetString(jwt.ClaimExpirationTime)
+		if !errors.Is(err, jwt.ErrInvalidClaimType) {
+			t.Errorf("expected error '%v', but got '%v'", jwt.ErrInvalidClaimType, err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	token := jwt.New()
+	token.SetIssuer("realworld")
+	token.SetAudience("web")
+	token.SetIssuedAt(now)
+	token.SetNotBefore(now)
+	token.SetExpirationTime(now.Add(time.Hour))
+	token = roundTrip(t, token)
+
+	tt := map[string]struct {
+		opts     []jwt.ValidateOption
+		expected error
+	}{
+		"Valid":              {[]jwt.ValidateOption{jwt.WithTime(now.Add(time.Minute))}, nil},
+		"At Not Before":      {[]jwt.ValidateOption{jwt.WithTime(now)}, nil},
+		"Not Valid Yet":      {[]jwt.ValidateOption{jwt.WithTime(now.Add(-time.Minute))}, jwt.ErrTokenNotValidYet},
+		"Not Valid Yet Skew": {[]jwt.ValidateOption{jwt.WithTime(now.Add(-time.Minute)), jwt.WithLeeway(2 * time.Minute)}, nil},
+		"At Expiration":      {[]jwt.ValidateOption{jwt.WithTime(now.Add(time.Hour))}, jwt.ErrTokenExpired},
+		"Expired":            {[]jwt.ValidateOption{jwt.WithTime(now.Add(2 * time.Hour))}, jwt.ErrTokenExpired},
+		"Expired Skew":       {[]jwt.ValidateOption{jwt.WithTime(now.Add(time.Hour)), jwt.WithLeeway(time.Minute)}, nil},
+		"Issuer":             {[]jwt.ValidateOption{jwt.WithTime(now), jwt.WithIssuer("realworld")}, nil},
+		"Wrong Issuer":       {[]jwt.ValidateOption{jwt.WithTime(now), jwt.WithIssuer("other")}, jwt.ErrInvalidIssuer},
+		"Audience":           {[]jwt.ValidateOption{jwt.WithTime(now), jwt.WithAudience("web")}, nil},
+		"Wrong Audience":     {[]jwt.ValidateOption{jwt.WithTime(now), jwt.WithAudience("mobile")}, jwt.ErrInvalidAudience},
+	}
+
+	for name, tc := range tt {
+		err := jwt.Validate(token, tc.opts...)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected error '%v', but got '%v'", name, tc.expected, err)
+		}
+	}
+
+	t.Run("Without Claims", func(t *testing.T) {
+		err := jwt.Validate(jwt.New())
+		if err != nil {
+			t.Errorf("expected token without time claims to be valid, but got '%v'", err)
+		}
+	})
+}
+
+func newKeys(t *testing.T) map[jwt.Algorithm]jwt.SigningKey {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error on generate rsa key: %s", err.Error())
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error on generate ecdsa key: %s", err.Error())
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error on generate ed25519 key: %s", err.Error())
+	}
+
+	res := map[jwt.Algorithm]jwt.SigningKey{jwt.HS256: jwt.NewHMACKey([]byte("secret"))}
+
+	for _, newKey := range []func() (jwt.SigningKey, error){
+		func() (jwt.SigningKey, error) { return jwt.NewRSAPrivateKey(rsaKey) },
+		func() (jwt.SigningKey, error) { return jwt.NewECDSAPrivateKey(ecdsaKey) },
+		func() (jwt.SigningKey, error) { return jwt.NewEd25519PrivateKey(ed25519Key) },
+	} {
+		key, err := newKey()
+		if err != nil {
+			t.Fatalf("error on new key: %s", err.Error())
+		}
+
+		res[key.Algorithm()] = key
+	}
+
+	return res
+}
+
+func TestParseAndVerify(t *testing.T) {
+	key := jwt.NewHMACKey([]byte("secret"))
+	now := time.Unix(1600000000, 0)
+
+	sign := func(t *testing.T, token jwt.Token) string {
+		t.Helper()
+
+		tokenStr, err := jwt.Sign(token, key)
+		if err != nil {
+			t.Fatalf("error on sign token: %s", err.Error())
+		}
+
+		return tokenStr
+	}
+
+	type Claims struct {
+		Subject   string   `json:"sub"`
+		Audience  []string `json:"aud"`
+		ExpiresAt int64    `json:"exp,omitempty"`
+		SessionID string   `json:"sid"`
+		Roles     []string `json:"roles"`
+		Counter   int64    `json:"counter"`
+	}
+
+	t.Run("Typed Claims", func(t *testing.T) {
+		token := jwt.New()
+		token.SetExpirationTime(now.Add(time.Hour))
+
+		err := token.SetClaims(Claims{
+			Subject:   "1",
+			Audience:  []string{"web"},
+			SessionID: "abc",
+			Roles:     []string{"admin"},
+			Counter:   1<<53 + 1,
+		})
+		if err != nil {
+			t.Fatalf("error on set claims: %s", err.Error())
+		}
+
+		res, err := jwt.ParseAndVerify(sign(t, token), key.VerificationKey(), jwt.WithTime(now))
+		if err != nil {
+			t.Fatalf("error on parse and verify token: %s", err.Error())
+		}
+
+		var claims Claims
+		err = res.Claims(&claims)
+		if err != nil {
+			t.Fatalf("error on decode claims: %s", err.Error())
+		}
+
+		// large numbers must not lose precision on the way
+		expected := Claims{
+			Subject:   "1",
+			Audience:  []string{"web"},
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			SessionID: "abc",
+			Roles:     []string{"admin"},
+			Counter:   1<<53 + 1,
+		}
+
+		if !reflect.DeepEqual(claims, expected) {
+			t.Errorf("expected claims '%+v', but got '%+v'", expected, claims)
+		}
+
+		if exp, err := res.GetExpirationTime(); err != nil || !exp.Equal(now.Add(time.Hour)) {
+			t.Errorf("expected expiration time '%s', but got '%s' and '%v'", now.Add(time.Hour), exp, err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		valid := jwt.New()
+		valid.SetSubject("1")
+		validStr := sign(t, valid)
+		parts := strings.Split(validStr, ".")
+
+		expired := jwt.New()
+		expired.SetExpirationTime(now.Add(-time.Hour))
+
+		notValidYet := jwt.New()
+		notValidYet.SetNotBefore(now.Add(time.Hour))
+
+		encode := func(s string) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(s))
+		}
+
+		for name, tc := range map[string]struct {
+			token    string
+			opts     []jwt.ValidateOption
+			expected error
+		}{
+			"Empty":               {"", nil, jwt.ErrMalformedToken},
+			"Two Parts":           {parts[0] + "." + parts[1], nil, jwt.ErrMalformedToken},
+			"Bad Header Encoding": {"!." + parts[1] + "." + parts[2], nil, jwt.ErrMalformedToken},
+			"Bad Header":          {encode("[]") + "." + parts[1] + "." + parts[2], nil, jwt.ErrMalformedToken},
+			"Bad Payload":         {parts[0] + "." + encode("null") + "." + parts[2], nil, jwt.ErrMalformedToken},
+			"Bad Signature":       {parts[0] + "." + parts[1] + ".!", nil, jwt.ErrMalformedToken},
+			"Other Type":          {encode(`{"alg":"HS256","typ":"JWE"}`) + "." + parts[1] + "." + parts[2], nil, jwt.ErrMalformedToken},
+			"Unsupported Alg":     {encode(`{"alg":"none","typ":"JWT"}`) + "." + parts[1] + ".", nil, jwt.ErrUnsupportedAlgorithm},
+			"Tampered":            {parts[0] + "." + encode(`{"sub":"2"}`) + "." + parts[2], nil, jwt.ErrInvalidTokenSignature},
+			"Expired":             {sign(t, expired), []jwt.ValidateOption{jwt.WithTime(now)}, jwt.ErrTokenExpired},
+			"Not Valid Yet":       {sign(t, notValidYet), []jwt.ValidateOption{jwt.WithTime(now)}, jwt.ErrTokenNotValidYet},
+			"Required Claim":      {validStr, []jwt.ValidateOption{jwt.WithRequiredClaims(jwt.ClaimSubject, jwt.ClaimExpirationTime)}, jwt.ErrClaimNotFound},
+		} {
+			res, err := jwt.ParseAndVerify(tc.token, key.VerificationKey(), tc.opts...)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("%s: expected error '%v', but got '%v'", name, tc.expected, err)
+			}
+
+			if res != nil {
+				t.Errorf("%s: expected no token, but got one", name)
+			}
+		}
+	})
+
+	t.Run("Key Func", func(t *testing.T) {
+		encode := func(s string) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(s))
+		}
+
+		// tokens of other issuers may have no typ header
+		unsigned := encode(`{"alg":"HS256","kid":"a"}`) + "." + encode(`{"sub":"1"}`)
+
+		sig, err := key.Sign([]byte(unsigned))
+		if err != nil {
+			t.Fatalf("error on sign token: %s", err.Error())
+		}
+
+		tokenStr := unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+		keyFunc := func(kid string) jwt.KeyFunc {
+			return func(header jwt.Header) (jwt.VerificationKey, error) {
+				if header.KeyID != kid {
+					return nil, jwt.ErrKeyNotFound
+				}
+
+				return key.VerificationKey(), nil
+			}
+		}
+
+		res, err := jwt.ParseAndVerifyFunc(tokenStr, keyFunc("a"))
+		if err != nil {
+			t.Fatalf("error on parse and verify token: %s", err.Error())
+		}
+
+		if sub, err := res.GetSubject(); err != nil || sub != "1" {
+			t.Errorf("expected subject '1', but got '%s' and '%v'", sub, err)
+		}
+
+		_, err = jwt.ParseAndVerifyFunc(tokenStr, keyFunc("b"))
+		if !errors.Is(err, jwt.ErrKeyNotFound) {
+			t.Errorf("expected error '%v', but got '%v'", jwt.ErrKeyNotFound, err)
+		}
+	})
+}
+
+func TestAlgorithms(t *testing.T) {
+	keys := newKeys(t)
+
+	for alg, key := range keys {
+		token := jwt.New()
+		token.SetSubject("1")
+
+		tokenStr, err := jwt.Sign(token, key)
+		if err != nil {
+			t.Fatalf("%s: error on sign token: %s", alg, err.Error())
+		}
+
+		res, err := jwt.Parse(tokenStr)
+		if err != nil {
+			t.Fatalf("%s: error on parse token: %s", alg, err.Error())
+		}
+
+		if header := res.(interface{ GetHeader() jwt.Header }).GetHeader(); header.Algorithm != alg {
+			t.Errorf("%s: expected header algorithm '%s', but got '%s'", alg, alg, header.Algorithm)
+		}
+
+		err = jwt.Verify(tokenStr, key.VerificationKey())
+		if err != nil {
+			t.Errorf("%s: error on verify token: %s", alg, err.Error())
+		}
+
+		// tampered payload
+		parts := strings.Split(tokenStr, ".")
+		tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"2"}`)) + "." + parts[2]
+
+		err = jwt.Verify(tampered, key.VerificationKey())
+		if !errors.Is(err, jwt.ErrInvalidTokenSignature) {
+			t.Errorf("%s: expected error '%v', but got '%v'", alg, jwt.ErrInvalidTokenSignature, err)
+		}
+
+		// keys of other algorithms
+		for other, otherKey := range keys {
+			if other == alg {
+				continue
+			}
+
+			err = jwt.Verify(tokenStr, otherKey.VerificationKey())
+			if !errors.Is(err, jwt.ErrAlgorithmMismatch) {
+				t.Errorf("%s: expected error '%v' verifying with %s key, but got '%v'", alg, jwt.ErrAlgorithmMismatch, other, err)
+			}
+		}
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	rsaKey := newKeys(t)[jwt.RS256]
+
+	// an attacker signs with the public key, which is no secret, as hmac secret
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.VerificationKey().(interface{ PublicKey() crypto.PublicKey }).PublicKey())
+	if err != nil {
+		t.Fatalf("error on marshal public key: %s", err.Error())
+	}
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	token := jwt.New()
+	token.SetSubject("1")
+
+	forged, err := jwt.Sign(token, jwt.NewHMACKey(publicPEM))
+	if err != nil {
+		t.Fatalf("error on sign token: %s", err.Error())
+	}
+
+	publicKey, err := jwt.ParsePublicKeyPEM(publicPEM)
+	if err != nil {
+		t.Fatalf("error on parse public key: %s", err.Error())
+	}
+
+	err = jwt.Verify(forged, publicKey)
+	if !errors.Is(err, jwt.ErrAlgorithmMismatch) {
+		t.Errorf("expected error '%v', but got '%v'", jwt.ErrAlgorithmMismatch, err)
+	}
+
+	// unsigned tokens
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1"}`)) + "."
+
+	err = jwt.Verify(unsigned, publicKey)
+	if !errors.Is(err, jwt.ErrUnsupportedAlgorithm) {
+		t.Errorf("expected error '%v', but got '%v'", jwt.ErrUnsupportedAlgorithm, err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("Weak RSA", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatalf("error on generate rsa key: %s", err.Error())
+		}
+
+		_, err = jwt.NewRSAPrivateKey(key)
+		if !errors.Is(err, jwt.ErrInvalidKey) {
+			t.Errorf("expected error '%v', but got '%v'", jwt.ErrInvalidKey, err)
+		}
+	})
+
+	t.Run("Other Curve", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		if err != nil {
+			t.Fatalf("error on generate ecdsa key: %s", err.Error())
+		}
+
+		_, err = jwt.NewECDSAPrivateKey(key)
+		if !errors.Is(err, jwt.ErrInvalidKey) {
+			t.Errorf("expected error '%v', but got '%v'", jwt.ErrInvalidKey, err)
+		}
+	})
+
+	t.Run("PEM", func(t *testing.T) {
+		for alg, key := range newKeys(t) {
+			if alg == jwt.HS256 {
+				continue
+			}
+
+			private := key.(interface{ PrivateKey() crypto.PrivateKey }).PrivateKey()
+
+			der, err := x509.MarshalPKCS8PrivateKey(private)
+			if err != nil {
+				t.Fatalf("%s: error on marshal private key: %s", alg, err.Error())
+			}
+
+			res, err := jwt.ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+			if err != nil {
+				t.Fatalf("%s: error on parse private key: %s", alg, err.Error())
+			}
+
+			if res.Algorithm() != alg {
+				t.Errorf("expected algorithm '%s', but got '%s'", alg, res.Algorithm())
+			}
+		}
+	})
+}
+
+func TestKeySet(t *testing.T) {
+	keys := newKeys(t)
+
+	old := jwt.NewKeySet("old", keys[jwt.HS256])
+
+	token := jwt.New()
+	token.SetSubject("1")
+
+	oldToken, err := old.Sign(token)
+	if err != nil {
+		t.Fatalf("error on sign token: %s", err.Error())
+	}
+
+	// rotate to a new signing key, keeping the old one for verification
+	keySet := jwt.NewKeySet("new", keys[jwt.ES256])
+
+	err = keySet.Add("old", keys[jwt.HS256].VerificationKey())
+	if err != nil {
+		t.Fatalf("error on add key: %s", err.Error())
+	}
+
+	err = keySet.Add("new", keys[jwt.RS256].VerificationKey())
+	if err == nil {
+		t.Error("expected error on add key with duplicate id")
+	}
+
+	newToken, err := keySet.Sign(token)
+	if err != nil {
+		t.Fatalf("error on sign token: %s", err.Error())
+	}
+
+	res, err := jwt.Parse(newToken)
+	if err != nil {
+		t.Fatalf("error on parse token: %s", err.Error())
+	}
+
+	if header := res.(interface{ GetHeader() jwt.Header }).GetHeader(); header.KeyID != "new" || header.Algorithm != jwt.ES256 {
+		t.Errorf("expected kid 'new' and algorithm '%s', but got '%s' and '%s'", jwt.ES256, header.KeyID, header.Algorithm)
+	}
+
+	for name, tokenStr := range map[string]string{"Old": oldToken, "New": newToken} {
+		_, err = keySet.ParseAndVerify(tokenStr)
+		if err != nil {
+			t.Errorf("%s: error on parse and verify token: %s", name, err.Error())
+		}
+	}
+
+	_, err = jwt.NewKeySet("other", keys[jwt.ES256]).ParseAndVerify(oldToken)
+	if !errors.Is(err, jwt.ErrKeyNotFound) {
+		t.Errorf("expected error '%v', but got '%v'", jwt.ErrKeyNotFound, err)
+	}
+
+	t.Run("JWKS", func(t *testing.T) {
+		err := keySet.Add("rsa", keys[jwt.RS256].VerificationKey())
+		if err != nil {
+			t.Fatalf("error on add key: %s", err.Error())
+		}
+
+		err = keySet.Add("ed25519", keys[jwt.EdDSA].VerificationKey())
+		if err != nil {
+			t.Fatalf("error on add key: %s", err.Error())
+		}
+
+		data, err := json.Marshal(keySet.JWKS())
+		if err != nil {
+			t.Fatalf("error on marshal jwks: %s", err.Error())
+		}
+
+		var jwks jwt.JSONWebKeySet
+		err = json.Unmarshal(data, &jwks)
+		if err != nil {
+			t.Fatalf("error on unmarshal jwks: %s", err.Error())
+		}
+
+		// hmac keys are secret, so they are never published
+		kids := make([]string, len(jwks.Keys))
+		for i := range jwks.Keys {
+			kids[i] = jwks.Keys[i].KeyID
+		}
+
+		if !reflect.DeepEqual(kids, []string{"new", "rsa", "ed25519"}) {
+			t.Errorf("expected keys 'new', 'rsa' and 'ed25519', but got '%v'", kids)
+		}
+
+		if strings.Contains(string(data), `"oct"`) || strings.Contains(string(data), `"k"`) {
+			t.Errorf("expected no symmetric keys published, but got %s", data)
+		}
+
+		for _, jwk := range jwks.Keys {
+			key, err := jwk.VerificationKey()
+			if err != nil {
+				t.Fatalf("%s: error on verification key: %s", jwk.KeyID, err.Error())
+			}
+
+			expected, _ := keySet.VerificationKey(jwk.KeyID)
+
+			if !reflect.DeepEqual(key, expected) {
+				t.Errorf("%s: expected key to round trip, but got '%#v'", jwk.KeyID, key)
+			}
+		}
+
+		// downstream services verify with published keys only
+		key, err := jwks.Keys[0].VerificationKey()
+		if err != nil {
+			t.Fatalf("error on verification key: %s", err.Error())
+		}
+
+		err = jwt.Verify(newToken, key)
+		if err != nil {
+			t.Errorf("error on verify token with published key: %s", err.Error())
+		}
+	})
+}
+
+func TestThumbprint(t *testing.T) {
+	// example of RFC 7638 section 3.1
+	jwk := jwt.JSONWebKey{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+	}
+
+	key, err := jwk.VerificationKey()
+	if err != nil {
+		t.Fatalf("error on verification key: %s", err.Error())
+	}
+
+	res, err := jwt.Thumbprint(key)
+	if err != nil {
+		t.Fatalf("error on thumbprint: %s", err.Error())
+	}
+
+	if expected := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; res != expected {
+		t.Errorf("expected '%s', but got '%s'", expected, res)
+	}
+
+	// a thumbprint of a secret would let anyone confirm guesses of it offline
+	_, err = jwt.Thumbprint(jwt.NewHMACKey([]byte("secret")).VerificationKey())
+	if !errors.Is(err, jwt.ErrInvalidKey) {
+		t.Errorf("expected error '%v', but got '%v'", jwt.ErrInvalidKey, err)
+	}
+}
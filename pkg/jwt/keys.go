@@ -0,0 +1,333 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// SigningKey signs tokens with exactly one algorithm
+type SigningKey interface {
+	Algorithm() Algorithm
+	Sign(data []byte) ([]byte, error)
+	// VerificationKey returns the key verifying signatures of this key, which is public for asymmetric algorithms
+	VerificationKey() VerificationKey
+}
+
+// VerificationKey verifies token signatures of exactly one algorithm,
+// so a token can never be verified with an algorithm chosen by its header
+type VerificationKey interface {
+	Algorithm() Algorithm
+	Verify(data, signature []byte) error
+}
+
+const rsaMinBits = 2048
+
+type hmacKey struct {
+	secret []byte
+}
+
+// NewHMACKey returns HS256 key of secret, which both signs and verifies
+func NewHMACKey(secret []byte) SigningKey {
+	return &hmacKey{secret: secret}
+}
+
+func (k *hmacKey) Algorithm() Algorithm {
+	return HS256
+}
+
+func (k *hmacKey) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	_, _ = mac.Write(data)
+
+	return mac.Sum(nil), nil
+}
+
+func (k *hmacKey) Verify(data, signature []byte) error {
+	expected, _ := k.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidTokenSignature
+	}
+
+	return nil
+}
+
+func (k *hmacKey) VerificationKey() VerificationKey {
+	return k
+}
+
+type rsaPrivateKey struct {
+	key *rsa.PrivateKey
+}
+
+type rsaPublicKey struct {
+	key *rsa.PublicKey
+}
+
+// NewRSAPrivateKey returns RS256 signing key, rejecting keys shorter than 2048 bits
+func NewRSAPrivateKey(key *rsa.PrivateKey) (SigningKey, error) {
+	if key.N.BitLen() < rsaMinBits {
+		return nil, fmt.Errorf("%w: rsa key should be at least %d bits", ErrInvalidKey, rsaMinBits)
+	}
+
+	return &rsaPrivateKey{key: key}, nil
+}
+
+// NewRSAPublicKey returns RS256 verification key, rejecting keys shorter than 2048 bits
+func NewRSAPublicKey(key *rsa.PublicKey) (VerificationKey, error) {
+	if key.N.BitLen() < rsaMinBits {
+		return nil, fmt.Errorf("%w: rsa key should be at least %d bits", ErrInvalidKey, rsaMinBits)
+	}
+
+	return &rsaPublicKey{key: key}, nil
+}
+
+func (k *rsaPrivateKey) Algorithm() Algorithm {
+	return RS256
+}
+
+func (k *rsaPrivateKey) Sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+
+	return rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+}
+
+// PrivateKey returns the underlying private key
+func (k *rsaPrivateKey) PrivateKey() crypto.PrivateKey {
+	return k.key
+}
+
+func (k *rsaPrivateKey) VerificationKey() VerificationKey {
+	return &rsaPublicKey{key: &k.key.PublicKey}
+}
+
+func (k *rsaPublicKey) Algorithm() Algorithm {
+	return RS256
+}
+
+func (k *rsaPublicKey) Verify(data, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	err := rsa.VerifyPKCS1v15(k.key, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return ErrInvalidTokenSignature
+	}
+
+	return nil
+}
+
+// PublicKey returns the underlying public key
+func (k *rsaPublicKey) PublicKey() crypto.PublicKey {
+	return k.key
+}
+
+type ecdsaPrivateKey struct {
+	key *ecdsa.PrivateKey
+}
+
+type ecdsaPublicKey struct {
+	key *ecdsa.PublicKey
+}
+
+// NewECDSAPrivateKey returns ES256 signing key of a P-256 key
+func NewECDSAPrivateKey(key *ecdsa.PrivateKey) (SigningKey, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ecdsa key should be on curve P-256", ErrInvalidKey)
+	}
+
+	return &ecdsaPrivateKey{key: key}, nil
+}
+
+// NewECDSAPublicKey returns ES256 verification key of a P-256 key
+func NewECDSAPublicKey(key *ecdsa.PublicKey) (VerificationKey, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ecdsa key should be on curve P-256", ErrInvalidKey)
+	}
+
+	return &ecdsaPublicKey{key: key}, nil
+}
+
+func (k *ecdsaPrivateKey) Algorithm() Algorithm {
+	return ES256
+}
+
+// Sign returns the signature as fixed size R || S as required by JWS, instead of ASN.1
+func (k *ecdsaPrivateKey) Sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+
+	r, s, err := ecdsa.Sign(rand.Reader, k.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signature, nil
+}
+
+// PrivateKey returns the underlying private key
+func (k *ecdsaPrivateKey) PrivateKey() crypto.PrivateKey {
+	return k.key
+}
+
+func (k *ecdsaPrivateKey) VerificationKey() VerificationKey {
+	return &ecdsaPublicKey{key: &k.key.PublicKey}
+}
+
+func (k *ecdsaPublicKey) Algorithm() Algorithm {
+	return ES256
+}
+
+func (k *ecdsaPublicKey) Verify(data, signature []byte) error {
+	if len(signature) != 64 {
+		return ErrInvalidTokenSignature
+	}
+
+	digest := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+
+	if !ecdsa.Verify(k.key, digest[:], r, s) {
+		return ErrInvalidTokenSignature
+	}
+
+	return nil
+}
+
+// PublicKey returns the underlying public key
+func (k *ecdsaPublicKey) PublicKey() crypto.PublicKey {
+	return k.key
+}
+
+type ed25519PrivateKey struct {
+	key ed25519.PrivateKey
+}
+
+type ed25519PublicKey struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519PrivateKey returns EdDSA signing key
+func NewEd25519PrivateKey(key ed25519.PrivateKey) (SigningKey, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: ed25519 private key should be %d bytes", ErrInvalidKey, ed25519.PrivateKeySize)
+	}
+
+	return &ed25519PrivateKey{key: key}, nil
+}
+
+// NewEd25519PublicKey returns EdDSA verification key
+func NewEd25519PublicKey(key ed25519.PublicKey) (VerificationKey, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: ed25519 public key should be %d bytes", ErrInvalidKey, ed25519.PublicKeySize)
+	}
+
+	return &ed25519PublicKey{key: key}, nil
+}
+
+func (k *ed25519PrivateKey) Algorithm() Algorithm {
+	return EdDSA
+}
+
+func (k *ed25519PrivateKey) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(k.key, data), nil
+}
+
+// PrivateKey returns the underlying private key
+func (k *ed25519PrivateKey) PrivateKey() crypto.PrivateKey {
+	return k.key
+}
+
+func (k *ed25519PrivateKey) VerificationKey() VerificationKey {
+	return &ed25519PublicKey{key: k.key.Public().(ed25519.PublicKey)}
+}
+
+func (k *ed25519PublicKey) Algorithm() Algorithm {
+	return EdDSA
+}
+
+func (k *ed25519PublicKey) Verify(data, signature []byte) error {
+	if !ed25519.Verify(k.key, data, signature) {
+		return ErrInvalidTokenSignature
+	}
+
+	return nil
+}
+
+// PublicKey returns the underlying public key
+func (k *ed25519PublicKey) PublicKey() crypto.PublicKey {
+	return k.key
+}
+
+// ParsePrivateKeyPEM returns signing key of a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key,
+// with algorithm chosen by its type
+func ParsePrivateKeyPEM(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no pem block found", ErrInvalidKey)
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAPrivateKey(key)
+	case *ecdsa.PrivateKey:
+		return NewECDSAPrivateKey(key)
+	case ed25519.PrivateKey:
+		return NewEd25519PrivateKey(key)
+	default:
+		return nil, fmt.Errorf("%w: unsupported private key type %T", ErrInvalidKey, key)
+	}
+}
+
+// ParsePublicKeyPEM returns verification key of a PEM encoded PKIX public key, with algorithm chosen by its type
+func ParsePublicKeyPEM(data []byte) (VerificationKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no pem block found", ErrInvalidKey)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return NewRSAPublicKey(key)
+	case *ecdsa.PublicKey:
+		return NewECDSAPublicKey(key)
+	case ed25519.PublicKey:
+		return NewEd25519PublicKey(key)
+	default:
+		return nil, fmt.Errorf("%w: unsupported public key type %T", ErrInvalidKey, key)
+	}
+}
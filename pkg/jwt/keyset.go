@@ -0,0 +1,85 @@
+package jwt
+
+import "fmt"
+
+// KeySet signs tokens with its signing key and verifies them with any of its keys chosen by kid header,
+// so keys can be rotated without invalidating tokens signed by previous ones
+type KeySet struct {
+	signingID string
+	signing   SigningKey
+	ids       []string
+	keys      map[string]VerificationKey
+}
+
+// NewKeySet returns a key set with key as signing key, identified by kid
+func NewKeySet(kid string, key SigningKey) *KeySet {
+	return &KeySet{
+		signingID: kid,
+		signing:   key,
+		ids:       []string{kid},
+		keys:      map[string]VerificationKey{kid: key.VerificationKey()},
+	}
+}
+
+// Add a verification key, e.g. a previous signing key, identified by kid
+func (s *KeySet) Add(kid string, key VerificationKey) error {
+	if _, ok := s.keys[kid]; ok {
+		return fmt.Errorf("key with id '%s' already exists", kid)
+	}
+
+	s.ids = append(s.ids, kid)
+	s.keys[kid] = key
+
+	return nil
+}
+
+// SigningKey returns the signing key and its id
+func (s *KeySet) SigningKey() (string, SigningKey) {
+	return s.signingID, s.signing
+}
+
+// VerificationKey returns the verification key identified by kid
+func (s *KeySet) VerificationKey(kid string) (VerificationKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// Sign the token with the signing key, setting kid header to its id
+func (s *KeySet) Sign(t Token) (string, error) {
+	t.(interface{ setKeyID(kid string) }).setKeyID(s.signingID)
+
+	return Sign(t, s.signing)
+}
+
+// ParseAndVerify parses token string and verifies it with the key of its kid header,
+// or the signing key if it has none, then validates its registered claims like ParseAndVerify
+func (s *KeySet) ParseAndVerify(t string, opts ...ValidateOption) (Token, error) {
+	return ParseAndVerifyFunc(t, s.keyOf, opts...)
+}
+
+func (s *KeySet) keyOf(header Header) (VerificationKey, error) {
+	if header.KeyID == "" {
+		return s.signing.VerificationKey(), nil
+	}
+
+	return s.VerificationKey(header.KeyID)
+}
+
+// JWKS returns public keys of the set in order they were added, skipping symmetric keys since they are secret
+func (s *KeySet) JWKS() JSONWebKeySet {
+	res := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(s.ids))}
+	for _, kid := range s.ids {
+		jwk, err := NewJSONWebKey(kid, s.keys[kid])
+		if err != nil {
+			continue
+		}
+
+		res.Keys = append(res.Keys, jwk)
+	}
+
+	return res
+}
@@ -0,0 +1,141 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netMail "net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// SMTP returns a mailer sending messages from from through the server at addr, using STARTTLS if server supports it.
+// auth can be nil for servers accepting mail without authentication.
+func SMTP(addr, from string, auth smtp.Auth) Mailer {
+	return &smtpMailer{
+		addr: addr,
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	from, err := netMail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("%w: bad sender: %s", ErrInvalidMessage, err.Error())
+	}
+
+	to, _ := netMail.ParseAddress(msg.To)
+
+	err = smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, data)
+	if err != nil {
+		return fmt.Errorf("error on send mail: %w", err)
+	}
+
+	return nil
+}
+
+type writerMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// Writer returns a mailer writing messages from from to w in internet message format, separated by a blank line,
+// so they can be read in a file or stdout while developing and testing
+func Writer(w io.Writer, from string) Mailer {
+	return &writerMailer{
+		w:    w,
+		from: from,
+	}
+}
+
+func (m *writerMailer) Send(msg Message) error {
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		data = append(data, "\r\n"...)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = m.w.Write(append(data, "\r\n"...))
+	if err != nil {
+		return fmt.Errorf("error on write mail: %w", err)
+	}
+
+	return nil
+}
+
+// encode formats msg with its headers, rejecting line breaks in them so they can not inject other headers
+func encode(from string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(from+msg.To+msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("%w: line break in header", ErrInvalidMessage)
+	}
+
+	to, err := netMail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad recipient: %s", ErrInvalidMessage, err.Error())
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + to.String() + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+
+	_, err = qp.Write([]byte(body))
+	if err != nil {
+		return nil, fmt.Errorf("error on encode body: %w", err)
+	}
+
+	err = qp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error on encode body: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
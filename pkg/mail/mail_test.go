@@ -0,0 +1,177 @@
+package mail_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/nasermirzaei89/realworld-go/pkg/mail"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netMail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+var message = mail.Message{
+	To:      "Alice <alice@example.com>",
+	Subject: "Réinitialisation",
+	Body:    "Hello Alice,\nyour token is " + strings.Repeat("x", 100) + "\n",
+}
+
+// assertMessage parses data and checks it is message sent from from
+func assertMessage(t *testing.T, data []byte, from string, msg mail.Message) {
+	t.Helper()
+
+	parsed, err := netMail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error on read message: %s", err.Error())
+	}
+
+	if actual := parsed.Header.Get("From"); actual != from {
+		t.Errorf("expected from '%s', but got '%s'", from, actual)
+	}
+
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != "alice@example.com" {
+		t.Errorf("expected recipient 'alice@example.com', but got '%v' with error '%v'", to, err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("error on decode subject: %s", err.Error())
+	}
+
+	if subject != msg.Subject {
+		t.Errorf("expected subject '%s', but got '%s'", msg.Subject, subject)
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatalf("error on decode body: %s", err.Error())
+	}
+
+	// line breaks are sent as CRLF, and trailing ones may separate messages
+	expected := strings.TrimRight(msg.Body, "\n")
+	if actual := strings.TrimRight(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n"); actual != expected {
+		t.Errorf("expected body '%q', but got '%q'", expected, actual)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := mail.Writer(&buf, "noreply@example.com").Send(message)
+	if err != nil {
+		t.Fatalf("error on send message: %s", err.Error())
+	}
+
+	assertMessage(t, buf.Bytes(), "noreply@example.com", message)
+
+	t.Run("Header Injection", func(t *testing.T) {
+		for name, msg := range map[string]mail.Message{
+			"To":      {To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Hi"},
+			"Subject": {To: "alice@example.com", Subject: "Hi\nBcc: eve@example.com"},
+			"Bad To":  {To: "not an address", Subject: "Hi"},
+		} {
+			var buf bytes.Buffer
+
+			err := mail.Writer(&buf, "noreply@example.com").Send(msg)
+			if !errors.Is(err, mail.ErrInvalidMessage) {
+				t.Errorf("expected error '%v' for %s, but got '%v'", mail.ErrInvalidMessage, name, err)
+			}
+
+			if buf.Len() != 0 {
+				t.Errorf("expected nothing written for %s, but got '%s'", name, buf.String())
+			}
+		}
+	})
+}
+
+// serveSMTP accepts one connection on l, speaking just enough SMTP to receive one message,
+// and sends the envelope and data it received on the returned channel
+func serveSMTP(t *testing.T, l net.Listener) <-chan []string {
+	t.Helper()
+
+	res := make(chan []string, 1)
+
+	go func() {
+		defer close(res)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		tp := textproto.NewConn(conn)
+
+		var received []string
+
+		_ = tp.PrintfLine("220 localhost ESMTP")
+
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				received = append(received, line)
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+
+				data, err := io.ReadAll(tp.DotReader())
+				if err != nil {
+					return
+				}
+
+				received = append(received, string(data))
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				res <- received
+				return
+			default:
+				_ = tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	return res
+}
+
+func TestSMTP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error on listen: %s", err.Error())
+	}
+	defer func() { _ = l.Close() }()
+
+	received := serveSMTP(t, l)
+
+	err = mail.SMTP(l.Addr().String(), "RealWorld <noreply@example.com>", nil).Send(message)
+	if err != nil {
+		t.Fatalf("error on send message: %s", err.Error())
+	}
+
+	res := <-received
+	if len(res) != 3 {
+		t.Fatalf("expected envelope and data, but got '%v'", res)
+	}
+
+	if res[0] != "MAIL FROM:<noreply@example.com>" && !strings.HasPrefix(res[0], "MAIL FROM:<noreply@example.com> ") {
+		t.Errorf("expected sender 'noreply@example.com', but got '%s'", res[0])
+	}
+
+	if res[1] != "RCPT TO:<alice@example.com>" {
+		t.Errorf("expected recipient 'alice@example.com', but got '%s'", res[1])
+	}
+
+	assertMessage(t, []byte(res[2]), "RealWorld <noreply@example.com>", message)
+}
@@ -0,0 +1,335 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDiscovery      = errors.New("discovery failed")
+	ErrTokenExchange  = errors.New("token exchange failed")
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrInvalidGrant is of token exchanges the provider rejects the code or its verifier of,
+	// which is the client's fault rather than the provider's
+	ErrInvalidGrant = fmt.Errorf("%w: invalid grant", ErrTokenExchange)
+)
+
+// Config of a client of an OpenID provider
+type Config struct {
+	// Issuer is the issuer url of the provider, which its discovery document is fetched from
+	Issuer   string
+	ClientID string
+	// ClientSecret is sent by HTTP basic authentication if set, otherwise the client is public and relies on PKCE only
+	ClientSecret string
+	// RedirectURL is where the provider sends users back with the authorization code
+	RedirectURL string
+	// Scopes default to openid, email and profile
+	Scopes []string
+	// HTTPClient defaults to a client with a 10 seconds timeout
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the discovery document of a provider used by clients
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of an ID token used to identify users
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+}
+
+// leeway allows clock skew between provider and client
+const leeway = time.Minute
+
+// keysRefreshInterval limits refetching keys of the provider on unknown key ids, which happens on key rotation
+const keysRefreshInterval = 10 * time.Second
+
+// Provider is an OpenID provider, which users are sent to log in and which verifies their identity
+type Provider struct {
+	config   Config
+	metadata Metadata
+
+	mu          sync.Mutex
+	keys        map[string]jwt.VerificationKey
+	refreshedAt time.Time
+}
+
+// Discover fetches the discovery document of the provider of config
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := Provider{config: config}
+
+	err := p.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", &p.metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscovery, err.Error())
+	}
+
+	// the issuer must be the one configured, so another provider can not issue tokens in its name
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: issuer '%s' does not match '%s'", ErrDiscovery, p.metadata.Issuer, config.Issuer)
+	}
+
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: endpoints are missing", ErrDiscovery)
+	}
+
+	return &p, nil
+}
+
+// Metadata returns the discovery document of the provider
+func (p *Provider) Metadata() Metadata {
+	return p.metadata
+}
+
+// RedirectURL returns the redirect url of the client
+func (p *Provider) RedirectURL() string {
+	return p.config.RedirectURL
+}
+
+// NewVerifier returns a random PKCE code verifier of RFC 7636, also good for state and nonce
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error on read random bytes: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the S256 code challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the url users are sent to for logging in, asking for an authorization code
+// bound to the code challenge of verifier, state and nonce
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.metadata.AuthorizationEndpoint + sep + query.Encode()
+}
+
+// Exchange redeems code with its verifier, and returns claims of the ID token once it is verified,
+// including that it is issued for nonce
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error on create token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenExchange, err.Error())
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid response with status %d: %s", ErrTokenExchange, res.StatusCode, err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK && body.Error == "invalid_grant" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGrant, body.ErrorDescription)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: %s", ErrTokenExchange, body.Error, body.ErrorDescription)
+	}
+
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id token in response", ErrTokenExchange)
+	}
+
+	return p.Verify(ctx, body.IDToken, nonce)
+}
+
+// Verify verifies the signature and claims of an ID token issued for nonce, and returns its claims
+func (p *Provider) Verify(ctx context.Context, idToken, nonce string) (*Claims, error) {
+	token, err := jwt.ParseAndVerifyFunc(idToken, func(header jwt.Header) (jwt.VerificationKey, error) {
+		return p.key(ctx, header.KeyID)
+	},
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithRequiredClaims(jwt.ClaimSubject, jwt.ClaimExpirationTime),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err.Error())
+	}
+
+	var claims Claims
+
+	err = token.Claims(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err.Error())
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to another party", ErrInvalidIDToken)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: empty subject", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+// key returns the key of the provider with kid, refetching keys if it is unknown, since the provider may have rotated them.
+// Tokens without kid can be verified only if the provider has one key.
+func (p *Provider) key(ctx context.Context, kid string) (jwt.VerificationKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keyOf(kid)
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(p.refreshedAt) < keysRefreshInterval {
+		return nil, jwt.ErrKeyNotFound
+	}
+
+	var jwks jwt.JSONWebKeySet
+
+	err := p.getJSON(ctx, p.metadata.JWKSURI, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("error on get keys: %w", err)
+	}
+
+	p.refreshedAt = time.Now()
+	p.keys = make(map[string]jwt.VerificationKey, len(jwks.Keys))
+
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.VerificationKey()
+		if err != nil {
+			// keys of unsupported types are skipped, since tokens can still be signed by others
+			continue
+		}
+
+		p.keys[jwk.KeyID] = key
+	}
+
+	key, ok = p.keyOf(kid)
+	if !ok {
+		return nil, jwt.ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (p *Provider) keyOf(kid string) (jwt.VerificationKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("error on create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error on get %s: %w", u, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d of %s", res.StatusCode, u)
+	}
+
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+	if err != nil {
+		return fmt.Errorf("error on decode %s: %w", u, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nasermirzaei89/realworld-go/pkg/jwt"
+	"github.com/nasermirzaei89/realworld-go/pkg/oidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	clientID     = "realworld"
+	clientSecret = "secret"
+	redirectURL  = "http://localhost:8080/users/oidc/callback"
+)
+
+// authorization is what the mock provider remembers of an authorization code
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// mockProvider is a local OpenID provider, which logs in alice without asking and issues ID tokens for her
+type mockProvider struct {
+	*httptest.Server
+	t      *testing.T
+	keys   *jwt.KeySet
+	issuer string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error on generate rsa key: %s", err.Error())
+	}
+
+	key, err := jwt.NewRSAPrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("error on create signing key: %s", err.Error())
+	}
+
+	p := &mockProvider{
+		t:     t,
+		keys:  jwt.NewKeySet("key1", key),
+		codes: make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+
+	p.Server = httptest.NewServer(mux)
+	p.issuer = p.URL
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *mockProvider) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(oidc.Metadata{
+		Issuer:                p.issuer,
+		AuthorizationEndpoint: p.URL + "/authorize",
+		TokenEndpoint:         p.URL + "/token",
+		JWKSURI:               p.URL + "/jwks",
+	})
+}
+
+func (p *mockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+		return
+	}
+
+	code := fmt.Sprintf("code%d", time.Now().UnixNano())
+
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		clientID:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+	}
+	p.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (p *mockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	// codes can be used once
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != auth.redirectURI {
+		tokenError("invalid_grant")
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != auth.clientID || subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) != 1 {
+		tokenError("invalid_client")
+		return
+	}
+
+	if oidc.Challenge(r.PostFormValue("code_verifier")) != auth.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     p.idToken(p.claims(auth.nonce)),
+	})
+}
+
+func (p *mockProvider) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(p.keys.JWKS())
+}
+
+func (p *mockProvider) claims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                p.issuer,
+		"sub":                "alice-id",
+		"aud":                clientID,
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+}
+
+func (p *mockProvider) idToken(claims map[string]interface{}) string {
+	p.t.Helper()
+
+	token := jwt.New()
+
+	err := token.SetClaims(claims)
+	if err != nil {
+		p.t.Fatalf("error on set claims: %s", err.Error())
+	}
+
+	tokenStr, err := p.keys.Sign(token)
+	if err != nil {
+		p.t.Fatalf("error on sign id token: %s", err.Error())
+	}
+
+	return tokenStr
+}
+
+func discover(t *testing.T, p *mockProvider) *oidc.Provider {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       p.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("error on discover: %s", err.Error())
+	}
+
+	return provider
+}
+
+// authorize follows the auth code url like a browser, and returns code and state the provider redirects back with
+func authorize(t *testing.T, provider *oidc.Provider, state, nonce, verifier string) (code, returnedState string) {
+	t.Helper()
+
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(provider.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatalf("error on authorize: %s", err.Error())
+	}
+	_ = res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		t.Fatalf("error on get redirect location: %s", err.Error())
+	}
+
+	if location.Scheme+"://"+location.Host+location.Path != redirectURL {
+		t.Fatalf("expected redirect to '%s', but got '%s'", redirectURL, location)
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestLogin(t *testing.T) {
+	p := newMockProvider(t)
+	provider := discover(t, p)
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		t.Fatalf("error on new verifier: %s", err.Error())
+	}
+
+	code, state := authorize(t, provider, "state", "nonce", verifier)
+	if state != "state" {
+		t.Errorf("expected state 'state', but got '%s'", state)
+	}
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("error on exchange: %s", err.Error())
+	}
+
+	expected := oidc.Claims{
+		Issuer:            p.URL,
+		Subject:           "alice-id",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+		Nonce:             "nonce",
+	}
+
+	if *claims != expected {
+		t.Errorf("expected claims '%+v', but got '%+v'", expected, *claims)
+	}
+
+	// codes can be used once
+	_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+	if !errors.Is(err, oidc.ErrTokenExchange) {
+		t.Errorf("expected error '%v', but got '%v'", oidc.ErrTokenExchange, err)
+	}
+}
+
+func TestExchangeErrors(t *testing.T) {
+	p := newMockProvider(t)
+	provider := discover(t, p)
+
+	t.Run("Wrong Verifier", func(t *testing.T) {
+		code, _ := authorize(t, provider, "state", "nonce", "verifier")
+
+		_, err := provider.Exchange(context.Background(), code, "other verifier", "nonce")
+		if !errors.Is(err, oidc.ErrInvalidGrant) || !errors.Is(err, oidc.ErrTokenExchange) {
+			t.Errorf("expected error '%v', but got '%v'", oidc.ErrInvalidGrant, err)
+		}
+	})
+
+	t.Run("Wrong Nonce", func(t *testing.T) {
+		code, _ := authorize(t, provider, "state", "nonce", "verifier")
+
+		_, err := provider.Exchange(context.Background(), code, "verifier", "other nonce")
+		if !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("expected error '%v', but got '%v'", oidc.ErrInvalidIDToken, err)
+		}
+	})
+
+	t.Run("Wrong Client Secret", func(t *testing.T) {
+		other, err := oidc.Discover(context.Background(), oidc.Config{
+			Issuer:       p.URL,
+			ClientID:     clientID,
+			ClientSecret: "wrong",
+			RedirectURL:  redirectURL,
+		})
+		if err != nil {
+			t.Fatalf("error on discover: %s", err.Error())
+		}
+
+		code, _ := authorize(t, other, "state", "nonce", "verifier")
+
+		_, err = other.Exchange(context.Background(), code, "verifier", "nonce")
+		if !errors.Is(err, oidc.ErrTokenExchange) {
+			t.Errorf("expected error '%v', but got '%v'", oidc.ErrTokenExchange, err)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	p := newMockProvider(t)
+	provider := discover(t, p)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error on generate rsa key: %s", err.Error())
+	}
+
+	otherKey, err := jwt.NewRSAPrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("error on create signing key: %s", err.Error())
+	}
+
+	with := func(name string, value interface{}) string {
+		claims := p.claims("nonce")
+		claims[name] = value
+
+		return p.idToken(claims)
+	}
+
+	forged := jwt.New()
+	_ = forged.SetClaims(p.claims("nonce"))
+	forgedStr, _ := jwt.NewKeySet("key1", otherKey).Sign(forged)
+
+	valid := p.idToken(p.claims("nonce"))
+
+	_, err = provider.Verify(context.Background(), valid, "nonce")
+	if err != nil {
+		t.Fatalf("error on verify valid id token: %s", err.Error())
+	}
+
+	for name, idToken := range map[string]string{
+		"Forged":          forgedStr,
+		"Expired":         with("exp", time.Now().Add(-time.Hour).Unix()),
+		"Other Issuer":    with("iss", "http://evil.example.com"),
+		"Other Audience":  with("aud", "other"),
+		"Other Party":     with("azp", "other"),
+		"Empty Subject":   with("sub", ""),
+		"Tampered":        valid[:len(valid)-4] + "AAAA",
+		"Malformed Token": "not a token",
+	} {
+		_, err := provider.Verify(context.Background(), idToken, "nonce")
+		if !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("%s: expected error '%v', but got '%v'", name, oidc.ErrInvalidIDToken, err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	p := newMockProvider(t)
+
+	// discovery documents of other issuers are rejected
+	p.issuer = "http://evil.example.com"
+
+	_, err := oidc.Discover(context.Background(), oidc.Config{Issuer: p.URL, ClientID: clientID})
+	if !errors.Is(err, oidc.ErrDiscovery) {
+		t.Errorf("expected error '%v', but got '%v'", oidc.ErrDiscovery, err)
+	}
+
+	_, err = oidc.Discover(context.Background(), oidc.Config{Issuer: p.URL + "/missing", ClientID: clientID})
+	if !errors.Is(err, oidc.ErrDiscovery) {
+		t.Errorf("expected error '%v', but got '%v'", oidc.ErrDiscovery, err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// example of appendix B of RFC 7636
+	res := oidc.Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if expected := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; res != expected {
+		t.Errorf("expected challenge '%s', but got '%s'", expected, res)
+	}
+}
@@ -0,0 +1,133 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// Argon2idParams are argon2id cost parameters
+type Argon2idParams struct {
+	// Time is the number of passes over memory
+	Time uint32
+	// Memory is the amount of memory in KiB
+	Memory uint32
+	// Threads is the degree of parallelism
+	Threads uint8
+}
+
+// DefaultArgon2idParams follows the second recommended option of RFC 9106
+var DefaultArgon2idParams = Argon2idParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// Argon2id returns an argon2id hasher with the given parameters
+func Argon2id(params Argon2idParams) (Hasher, error) {
+	if params.Time < 1 {
+		return nil, fmt.Errorf("argon2id time should be at least 1")
+	}
+
+	if params.Threads < 1 {
+		return nil, fmt.Errorf("argon2id threads should be at least 1")
+	}
+
+	if params.Memory < 8*uint32(params.Threads) {
+		return nil, fmt.Errorf("argon2id memory should be at least %d KiB for %d threads", 8*uint32(params.Threads), params.Threads)
+	}
+
+	return &argon2idHasher{params: params}, nil
+}
+
+// Hash returns the hash in PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=4$salt$key
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("error on generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, argon2idKeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Time, h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(hash, password string) (bool, error) {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return false, ErrUnknownHash
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return true
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+
+	return err != nil || params != h.params || len(salt) != argon2idSaltLength || len(key) != argon2idKeyLength
+}
+
+func decodeArgon2id(hash string) (params Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("%w: wrong number of parts", ErrInvalidHash)
+	}
+
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: bad version: %s", ErrInvalidHash, err.Error())
+	}
+
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHash, version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: bad parameters: %s", ErrInvalidHash, err.Error())
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: bad salt: %s", ErrInvalidHash, err.Error())
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("%w: bad key: %s", ErrInvalidHash, err.Error())
+	}
+
+	if params.Time < 1 || params.Threads < 1 || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: bad parameters", ErrInvalidHash)
+	}
+
+	return params, salt, key, nil
+}
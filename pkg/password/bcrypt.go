@@ -0,0 +1,72 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Bcrypt costs
+const (
+	BcryptMinCost     = bcrypt.MinCost
+	BcryptMaxCost     = bcrypt.MaxCost
+	BcryptDefaultCost = bcrypt.DefaultCost
+)
+
+type bcryptHasher struct {
+	cost int
+}
+
+// Bcrypt returns a bcrypt hasher with the given cost
+func Bcrypt(cost int) (Hasher, error) {
+	if cost < BcryptMinCost || cost > BcryptMaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d is out of range [%d, %d]", cost, BcryptMinCost, BcryptMaxCost)
+	}
+
+	return &bcryptHasher{cost: cost}, nil
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return "", ErrTooLong
+		}
+
+		return "", fmt.Errorf("error on generate bcrypt hash: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(hash, password string) (bool, error) {
+	if !isBcrypt(hash) {
+		return false, ErrUnknownHash
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%w: %s", ErrInvalidHash, err.Error())
+	}
+
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return err != nil || cost != h.cost
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
@@ -0,0 +1,79 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+)
+
+// Hasher hashes passwords and verifies them against hashes
+type Hasher interface {
+	// Hash returns an encoded hash of password including its algorithm and parameters
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash, or returns ErrUnknownHash if hash is not of this hasher
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash was not produced by this hasher with its current parameters
+	NeedsRehash(hash string) bool
+}
+
+var (
+	ErrUnknownHash = errors.New("unknown hash")
+	ErrInvalidHash = errors.New("invalid hash")
+	ErrTooLong     = errors.New("password is too long")
+)
+
+type chain struct {
+	hashers []Hasher
+}
+
+// Chain returns a hasher that hashes with current and also verifies hashes of legacy hashers,
+// so users of legacy hashes can log in and get rehashed
+func Chain(current Hasher, legacy ...Hasher) Hasher {
+	return &chain{hashers: append([]Hasher{current}, legacy...)}
+}
+
+func (c *chain) Hash(password string) (string, error) {
+	return c.hashers[0].Hash(password)
+}
+
+func (c *chain) Verify(hash, password string) (bool, error) {
+	for _, hasher := range c.hashers {
+		ok, err := hasher.Verify(hash, password)
+		if errors.Is(err, ErrUnknownHash) {
+			continue
+		}
+
+		return ok, err
+	}
+
+	return false, ErrUnknownHash
+}
+
+func (c *chain) NeedsRehash(hash string) bool {
+	return c.hashers[0].NeedsRehash(hash)
+}
+
+type plaintext struct{}
+
+// Plaintext returns a hasher for passwords stored without hashing,
+// which only makes sense as a legacy hasher of Chain
+func Plaintext() Hasher {
+	return plaintext{}
+}
+
+func (plaintext) Hash(password string) (string, error) {
+	return password, nil
+}
+
+// Verify accepts any value not in modular crypt format, since hashes of all other hashers start with "$"
+func (plaintext) Verify(hash, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$") {
+		return false, ErrUnknownHash
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, nil
+}
+
+func (plaintext) NeedsRehash(hash string) bool {
+	return strings.HasPrefix(hash, "$")
+}
@@ -0,0 +1,192 @@
+package password_test
+
+import (
+	"errors"
+	"github.com/nasermirzaei89/realworld-go/pkg/password"
+	"strings"
+	"testing"
+)
+
+func newBcrypt(t *testing.T, cost int) password.Hasher {
+	t.Helper()
+
+	hasher, err := password.Bcrypt(cost)
+	if err != nil {
+		t.Fatalf("error on new bcrypt hasher: %s", err.Error())
+	}
+
+	return hasher
+}
+
+func newArgon2id(t *testing.T, params password.Argon2idParams) password.Hasher {
+	t.Helper()
+
+	hasher, err := password.Argon2id(params)
+	if err != nil {
+		t.Fatalf("error on new argon2id hasher: %s", err.Error())
+	}
+
+	return hasher
+}
+
+func hash(t *testing.T, hasher password.Hasher, pass string) string {
+	t.Helper()
+
+	res, err := hasher.Hash(pass)
+	if err != nil {
+		t.Fatalf("error on hash password: %s", err.Error())
+	}
+
+	return res
+}
+
+func TestHashers(t *testing.T) {
+	for name, hasher := range map[string]password.Hasher{
+		"Bcrypt":   newBcrypt(t, password.BcryptMinCost),
+		"Argon2id": newArgon2id(t, password.Argon2idParams{Time: 1, Memory: 64, Threads: 1}),
+	} {
+		hasher := hasher
+		t.Run(name, func(t *testing.T) {
+			res := hash(t, hasher, "secret")
+
+			if res == "secret" || !strings.HasPrefix(res, "$") {
+				t.Errorf("expected hash in modular crypt format, but got '%s'", res)
+			}
+
+			if other := hash(t, hasher, "secret"); other == res {
+				t.Error("expected hashes of the same password to be salted differently")
+			}
+
+			ok, err := hasher.Verify(res, "secret")
+			if err != nil {
+				t.Fatalf("error on verify password: %s", err.Error())
+			}
+
+			if !ok {
+				t.Error("expected password to match its hash")
+			}
+
+			ok, err = hasher.Verify(res, "wrong")
+			if err != nil {
+				t.Fatalf("error on verify wrong password: %s", err.Error())
+			}
+
+			if ok {
+				t.Error("expected wrong password not to match")
+			}
+
+			if hasher.NeedsRehash(res) {
+				t.Error("expected fresh hash not to need rehash")
+			}
+
+			_, err = hasher.Verify("secret", "secret")
+			if !errors.Is(err, password.ErrUnknownHash) {
+				t.Errorf("expected error '%v' for plaintext, but got '%v'", password.ErrUnknownHash, err)
+			}
+
+			if !hasher.NeedsRehash("secret") {
+				t.Error("expected plaintext to need rehash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	t.Run("Bcrypt Cost", func(t *testing.T) {
+		res := hash(t, newBcrypt(t, password.BcryptMinCost), "secret")
+
+		if !newBcrypt(t, password.BcryptMinCost+1).NeedsRehash(res) {
+			t.Error("expected hash with another cost to need rehash")
+		}
+	})
+
+	t.Run("Argon2id Params", func(t *testing.T) {
+		params := password.Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+		res := hash(t, newArgon2id(t, params), "secret")
+
+		params.Time = 2
+		other := newArgon2id(t, params)
+
+		if !other.NeedsRehash(res) {
+			t.Error("expected hash with other parameters to need rehash")
+		}
+
+		// hashes with old parameters still verify
+		ok, err := other.Verify(res, "secret")
+		if err != nil || !ok {
+			t.Errorf("expected hash with old parameters to verify, but got '%v' and '%v'", ok, err)
+		}
+	})
+
+	t.Run("Algorithm", func(t *testing.T) {
+		res := hash(t, newBcrypt(t, password.BcryptMinCost), "secret")
+
+		if !newArgon2id(t, password.DefaultArgon2idParams).NeedsRehash(res) {
+			t.Error("expected hash of another algorithm to need rehash")
+		}
+	})
+}
+
+func TestChain(t *testing.T) {
+	bcrypt := newBcrypt(t, password.BcryptMinCost)
+	argon2id := newArgon2id(t, password.Argon2idParams{Time: 1, Memory: 64, Threads: 1})
+	hasher := password.Chain(argon2id, bcrypt, password.Plaintext())
+
+	if res := hash(t, hasher, "secret"); !strings.HasPrefix(res, "$argon2id$") {
+		t.Errorf("expected chain to hash with current hasher, but got '%s'", res)
+	}
+
+	for name, stored := range map[string]string{
+		"Current":   hash(t, argon2id, "secret"),
+		"Legacy":    hash(t, bcrypt, "secret"),
+		"Plaintext": "secret",
+	} {
+		ok, err := hasher.Verify(stored, "secret")
+		if err != nil || !ok {
+			t.Errorf("%s: expected password to match, but got '%v' and '%v'", name, ok, err)
+		}
+
+		ok, err = hasher.Verify(stored, "wrong")
+		if err != nil || ok {
+			t.Errorf("%s: expected wrong password not to match, but got '%v' and '%v'", name, ok, err)
+		}
+
+		if needsRehash := hasher.NeedsRehash(stored); needsRehash != (name != "Current") {
+			t.Errorf("%s: expected needs rehash to be '%v', but got '%v'", name, name != "Current", needsRehash)
+		}
+	}
+
+	// a hash must never be accepted as its own plaintext password
+	stored := hash(t, bcrypt, "secret")
+	ok, _ := hasher.Verify(stored, stored)
+	if ok {
+		t.Error("expected hash not to match itself as password")
+	}
+
+	_, err := password.Chain(argon2id).Verify("secret", "secret")
+	if !errors.Is(err, password.ErrUnknownHash) {
+		t.Errorf("expected error '%v', but got '%v'", password.ErrUnknownHash, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := newBcrypt(t, password.BcryptMinCost).Hash(strings.Repeat("a", 73))
+	if !errors.Is(err, password.ErrTooLong) {
+		t.Errorf("expected error '%v', but got '%v'", password.ErrTooLong, err)
+	}
+
+	_, err = password.Bcrypt(password.BcryptMaxCost + 1)
+	if err == nil {
+		t.Error("expected error on out of range bcrypt cost")
+	}
+
+	_, err = password.Argon2id(password.Argon2idParams{Time: 1, Memory: 64})
+	if err == nil {
+		t.Error("expected error on zero argon2id threads")
+	}
+
+	_, err = newArgon2id(t, password.DefaultArgon2idParams).Verify("$argon2id$v=19$m=64,t=1$salt", "secret")
+	if !errors.Is(err, password.ErrInvalidHash) {
+		t.Errorf("expected error '%v', but got '%v'", password.ErrInvalidHash, err)
+	}
+}
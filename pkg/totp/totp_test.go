@@ -0,0 +1,113 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"errors"
+	"github.com/nasermirzaei89/realworld-go/pkg/totp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// secret of RFC 6238 test vectors for SHA1
+var secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// last 6 digits of RFC 6238 test vectors
+	for unix, expected := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := totp.Code(secret, totp.Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("error on code: %s", err.Error())
+		}
+
+		if code != expected {
+			t.Errorf("expected code '%s' at %d, but got '%s'", expected, unix, code)
+		}
+	}
+
+	_, err := totp.Code("not base32!", 1)
+	if !errors.Is(err, totp.ErrInvalidSecret) {
+		t.Errorf("expected error '%v', but got '%v'", totp.ErrInvalidSecret, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	for name, tc := range map[string]struct {
+		at       time.Time
+		ok       bool
+		expected int64
+	}{
+		"Current Step":  {now, true, totp.Step(now)},
+		"Previous Step": {now.Add(-totp.Period), true, totp.Step(now) - 1},
+		"Next Step":     {now.Add(totp.Period), true, totp.Step(now) + 1},
+		"Too Old":       {now.Add(-2 * totp.Period), false, 0},
+		"Too New":       {now.Add(2 * totp.Period), false, 0},
+	} {
+		code, err := totp.Code(secret, totp.Step(tc.at))
+		if err != nil {
+			t.Fatalf("error on code: %s", err.Error())
+		}
+
+		step, ok, err := totp.Verify(secret, code, now)
+		if err != nil {
+			t.Fatalf("error on verify: %s", err.Error())
+		}
+
+		if ok != tc.ok || step != tc.expected {
+			t.Errorf("expected %t and step %d for %s, but got %t and step %d", tc.ok, tc.expected, name, ok, step)
+		}
+	}
+
+	_, ok, err := totp.Verify(secret, "", now)
+	if err != nil || ok {
+		t.Errorf("expected empty code to be rejected, but got %t with error '%v'", ok, err)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("error on new secret: %s", err.Error())
+	}
+
+	second, err := totp.NewSecret()
+	if err != nil {
+		t.Fatalf("error on new secret: %s", err.Error())
+	}
+
+	if first == second {
+		t.Errorf("expected different secrets, but got '%s' twice", first)
+	}
+
+	_, err = totp.Code(first, 1)
+	if err != nil {
+		t.Errorf("expected new secret to be usable, but got error '%s'", err.Error())
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(totp.URI(secret, "RealWorld", "alice@example.com"))
+	if err != nil {
+		t.Fatalf("error on parse uri: %s", err.Error())
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/RealWorld:alice@example.com" {
+		t.Errorf("expected otpauth://totp/RealWorld:alice@example.com, but got '%s'", u.String())
+	}
+
+	query := u.Query()
+	for name, expected := range map[string]string{"secret": secret, "issuer": "RealWorld", "digits": "6", "period": "30"} {
+		if actual := query.Get(name); actual != expected {
+			t.Errorf("expected %s '%s', but got '%s'", name, expected, actual)
+		}
+	}
+}